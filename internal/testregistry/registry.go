@@ -0,0 +1,281 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package testregistry runs an in-memory OCI registry loaded with the e2e
+// test fixtures so that the OCI tests can run under plain `go test`.
+package testregistry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/openvex/go-vex/pkg/vex"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/oci/mutate"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	"github.com/sigstore/cosign/v2/pkg/oci/static"
+	"github.com/sigstore/cosign/v2/pkg/types"
+)
+
+const (
+	// AlpineIndexDigest is the digest of the alpine-cves multiarch index
+	AlpineIndexDigest = "sha256:eece025e432126ce23f223450a0326fbebde39cdf496a85d8c016293fc851978"
+
+	// AlpineAmd64Digest is the digest of the linux/amd64 alpine-cves image
+	AlpineAmd64Digest = "sha256:48d9183eb12a05c99bcc0bf44a003607b8e941e1d4f41f9ad12bdcc4b5672f86"
+)
+
+// Fixture is an OCI layout from the testdata directory and the reference
+// where it gets pushed in the test registry.
+type Fixture struct {
+	// Layout is the path of the OCI layout, relative to test/e2e/testdata
+	Layout string
+
+	// Reference is the repository and tag, without the registry host
+	Reference string
+
+	// Digest optionally selects an image fronted by an index in the layout.
+	// When empty, the first manifest in the layout is pushed.
+	Digest string
+}
+
+// Fixtures is the list of OCI layouts pushed to the registry. The layouts
+// are documented in test/e2e/fixtures.md
+var Fixtures = []Fixture{
+	{"alpine-cves", "alpine-cves:latest", ""},
+	{"alpine-cves.att", "alpine-cves:sha256-eece025e432126ce23f223450a0326fbebde39cdf496a85d8c016293fc851978.att", ""},
+	{"alpine-cves", "alpine-cves-amd64:latest", AlpineAmd64Digest},
+	{"alpine-cves", "notsigned:latest", ""},
+}
+
+// Registry is an in-memory registry serving the test fixtures
+type Registry struct {
+	// Host is the host:port string where the registry listens
+	Host string
+}
+
+// New starts a new in-memory registry, loads the fixtures into it and
+// registers a cleanup function to shut it down when the test finishes.
+func New(t testing.TB) *Registry {
+	t.Helper()
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(server.Close)
+
+	reg := &Registry{
+		Host: strings.TrimPrefix(server.URL, "http://"),
+	}
+
+	for _, f := range Fixtures {
+		if err := reg.Load(filepath.Join(TestDataPath(), f.Layout), f.Reference, f.Digest); err != nil {
+			t.Fatalf("loading fixture %s: %v", f.Layout, err)
+		}
+	}
+	return reg
+}
+
+// Ref returns a reference string pointing to the test registry
+func (reg *Registry) Ref(ref string) string {
+	return reg.Host + "/" + ref
+}
+
+// Load pushes a manifest of an OCI layout to the test registry under the
+// specified repository and tag. If digest is empty, the first manifest in the
+// layout is pushed. Otherwise, the image with the digest is looked up in the
+// layout and in the indexes it contains.
+func (reg *Registry) Load(layoutPath, ref, digest string) error {
+	lp, err := layout.FromPath(layoutPath)
+	if err != nil {
+		return fmt.Errorf("opening layout: %w", err)
+	}
+
+	idx, err := lp.ImageIndex()
+	if err != nil {
+		return fmt.Errorf("reading layout index: %w", err)
+	}
+
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("reading layout index manifest: %w", err)
+	}
+
+	if len(im.Manifests) == 0 {
+		return fmt.Errorf("layout %s has no manifests", layoutPath)
+	}
+
+	tag, err := name.NewTag(reg.Ref(ref))
+	if err != nil {
+		return fmt.Errorf("parsing reference: %w", err)
+	}
+
+	if digest != "" {
+		img, err := findImage(idx, digest)
+		if err != nil {
+			return err
+		}
+		return remote.Write(tag, img)
+	}
+
+	desc := im.Manifests[0]
+	if desc.MediaType.IsIndex() {
+		child, err := idx.ImageIndex(desc.Digest)
+		if err != nil {
+			return fmt.Errorf("reading index %s: %w", desc.Digest, err)
+		}
+		return remote.WriteIndex(tag, child)
+	}
+
+	img, err := idx.Image(desc.Digest)
+	if err != nil {
+		return fmt.Errorf("reading image %s: %w", desc.Digest, err)
+	}
+	return remote.Write(tag, img)
+}
+
+// Attest attaches an unsigned OpenVEX attestation to the image or index at
+// ref. The attestation subject is the digest of the entity.
+func (reg *Registry) Attest(ref string, doc *vex.VEX) error {
+	return reg.AttestSubject(ref, "", doc)
+}
+
+// AttestSubject attaches an unsigned OpenVEX attestation to the image or
+// index at ref with subject as the digest of its subject. If subject is
+// empty, the digest of the entity is used.
+func (reg *Registry) AttestSubject(ref, subject string, doc *vex.VEX) error {
+	return reg.AttestPredicate(ref, subject, vex.Context, *doc)
+}
+
+// AttestPredicate attaches an unsigned attestation of any predicate type to
+// the image or index at ref, with subject as the digest of its subject. If
+// subject is empty, the digest of the entity is used.
+func (reg *Registry) AttestPredicate(ref, subject, predicateType string, predicate any) error {
+	return reg.attest(ref, subject, predicateType, predicate, nil)
+}
+
+// AttestSigned attaches an OpenVEX attestation signed by signer to the image
+// or index at ref. The attestation subject is the digest of the entity.
+func (reg *Registry) AttestSigned(ref string, doc *vex.VEX, signer *Signer) error {
+	return reg.attest(ref, "", vex.Context, *doc, signer)
+}
+
+// AttestSignedSubject attaches an OpenVEX attestation signed by signer to the
+// image or index at ref with subject as the digest of its subject. If
+// subject is empty, the digest of the entity is used.
+func (reg *Registry) AttestSignedSubject(ref, subject string, doc *vex.VEX, signer *Signer) error {
+	return reg.attest(ref, subject, vex.Context, *doc, signer)
+}
+
+// attest builds an attestation and attaches it to the entity at ref. When
+// signer is nil, the attestation envelope has no signatures.
+func (reg *Registry) attest(ref, subject, predicateType string, predicate any, signer *Signer) error {
+	r, err := name.ParseReference(reg.Ref(ref))
+	if err != nil {
+		return fmt.Errorf("parsing reference: %w", err)
+	}
+
+	digest, err := ociremote.ResolveDigest(r)
+	if err != nil {
+		return fmt.Errorf("resolving digest: %w", err)
+	}
+
+	se, err := ociremote.SignedEntity(digest)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", digest, err)
+	}
+
+	if subject == "" {
+		subject = digest.DigestStr()
+	}
+
+	att := intoto.Statement{
+		StatementHeader: intoto.StatementHeader{
+			Type:          intoto.StatementInTotoV01,
+			PredicateType: predicateType,
+			Subject: []intoto.Subject{{
+				Name:   digest.Context().String(),
+				Digest: map[string]string{"sha256": strings.TrimPrefix(subject, "sha256:")},
+			}},
+		},
+		Predicate: predicate,
+	}
+
+	payload, err := json.Marshal(att)
+	if err != nil {
+		return fmt.Errorf("marshaling attestation: %w", err)
+	}
+
+	var envelope []byte
+	sigOpts := []static.Option{static.WithLayerMediaType(types.DssePayloadType)}
+	if signer != nil {
+		envelope, err = signer.sign(payload)
+		if err != nil {
+			return err
+		}
+		sigOpts = append(sigOpts, static.WithCertChain(signer.certPEM, signer.rootPEM))
+	} else {
+		envelope, err = json.Marshal(cosign.AttestationPayload{
+			PayloadType: types.IntotoPayloadType,
+			PayLoad:     base64.StdEncoding.EncodeToString(payload),
+			Signatures:  []cosign.Signatures{},
+		})
+		if err != nil {
+			return fmt.Errorf("marshaling envelope: %w", err)
+		}
+	}
+
+	sig, err := static.NewAttestation(envelope, sigOpts...)
+	if err != nil {
+		return fmt.Errorf("creating attestation: %w", err)
+	}
+
+	se, err = mutate.AttachAttestationToEntity(se, sig)
+	if err != nil {
+		return fmt.Errorf("attaching attestation: %w", err)
+	}
+
+	return ociremote.WriteAttestations(digest.Repository, se)
+}
+
+// findImage looks for an image by digest in an index and its children
+func findImage(idx v1.ImageIndex, digest string) (v1.Image, error) {
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("reading index manifest: %w", err)
+	}
+
+	for _, desc := range im.Manifests {
+		if desc.Digest.String() == digest && desc.MediaType.IsImage() {
+			return idx.Image(desc.Digest)
+		}
+		if !desc.MediaType.IsIndex() {
+			continue
+		}
+		child, err := idx.ImageIndex(desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("reading index %s: %w", desc.Digest, err)
+		}
+		if img, err := findImage(child, digest); err == nil {
+			return img, nil
+		}
+	}
+	return nil, fmt.Errorf("image %s not found in layout", digest)
+}
+
+// TestDataPath returns the path to the e2e test data directory
+func TestDataPath() string {
+	_, file, _, _ := runtime.Caller(0) //nolint: dogsled
+	return filepath.Join(filepath.Dir(file), "..", "..", "test", "e2e", "testdata")
+}
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package testregistry
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+
+	"github.com/sigstore/cosign/v2/pkg/types"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/dsse"
+)
+
+// oidcIssuerOID is the Fulcio certificate extension holding the OIDC issuer
+var oidcIssuerOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+
+// Signer signs attestations with a keyless style certificate issued by a
+// test certificate authority. The certificate has the signer identity as
+// its URI SAN and the OIDC issuer extension set by Fulcio.
+type Signer struct {
+	// Roots has the certificate of the test CA to verify the signatures
+	Roots *x509.CertPool
+
+	signer  signature.Signer
+	certPEM []byte
+	rootPEM []byte
+}
+
+// NewSigner creates a test CA and a certificate for identity and issuer
+func NewSigner(identity, issuer string) (*Signer, error) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating CA key: %w", err)
+	}
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "testregistry CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, fmt.Errorf("creating CA certificate: %w", err)
+	}
+	root, err := x509.ParseCertificate(rootDER)
+	if err != nil {
+		return nil, fmt.Errorf("parsing CA certificate: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating signer key: %w", err)
+	}
+	uri, err := url.Parse(identity)
+	if err != nil {
+		return nil, fmt.Errorf("parsing identity: %w", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:            []*url.URL{uri},
+		ExtraExtensions: []pkix.Extension{{Id: oidcIssuerOID, Value: []byte(issuer)}},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, root, &key.PublicKey, rootKey)
+	if err != nil {
+		return nil, fmt.Errorf("creating signer certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("parsing signer certificate: %w", err)
+	}
+
+	signer, err := signature.LoadECDSASigner(key, crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("loading signer: %w", err)
+	}
+
+	certPEM, err := cryptoutils.MarshalCertificateToPEM(cert)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling certificate: %w", err)
+	}
+	rootPEM, err := cryptoutils.MarshalCertificateToPEM(root)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling CA certificate: %w", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return &Signer{Roots: roots, signer: signer, certPEM: certPEM, rootPEM: rootPEM}, nil
+}
+
+// sign wraps an in-toto statement in a signed DSSE envelope
+func (s *Signer) sign(statement []byte) ([]byte, error) {
+	envelope, err := dsse.WrapSigner(s.signer, types.IntotoPayloadType).SignMessage(bytes.NewReader(statement))
+	if err != nil {
+		return nil, fmt.Errorf("signing statement: %w", err)
+	}
+	return envelope, nil
+}
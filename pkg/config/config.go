@@ -0,0 +1,348 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	purl "github.com/package-url/packageurl-go"
+	"gopkg.in/yaml.v3"
+
+	"github.com/openvex/discovery/pkg/discovery"
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/validation"
+	doci "github.com/openvex/discovery/pkg/oci"
+	"github.com/openvex/discovery/pkg/probers/oci"
+	"github.com/openvex/discovery/pkg/trust"
+)
+
+// Environment variables that override the configuration values
+const (
+	EnvPluginDirs         = "OPENVEX_DISCOVERY_PLUGIN_DIRS"
+	EnvTrustPolicy        = "OPENVEX_DISCOVERY_TRUST_POLICY"
+	EnvPlatform           = "OPENVEX_DISCOVERY_PLATFORM"
+	EnvTagPrefix          = "OPENVEX_DISCOVERY_TAG_PREFIX"
+	EnvCacheDir           = "OPENVEX_DISCOVERY_CACHE_DIR"
+	EnvRepositoryOverride = "COSIGN_REPOSITORY"
+)
+
+// Config captures the settings of the discovery agent and its probers. It
+// can be read from a YAML or JSON file:
+//
+//	pluginDirs:
+//	  - /usr/libexec/vex-probes
+//	probers:
+//	  oci:
+//	    policy: query-all
+//	    drivers:
+//	      - name: plugin:vex-probe-oci
+//	      - name: oci
+//	trustPolicyFile: policy.yaml
+//	cacheDir: /var/cache/vex-discovery
+//	oci:
+//	  platform: linux/amd64
+//	  mirrors:
+//	    - prefix: docker.io
+//	      mirror: mirror.example.com/dockerhub
+//	registries:
+//	  ghcr.io:
+//	    usernameEnv: GHCR_USER
+//	    passwordEnv: GHCR_TOKEN
+type Config struct {
+	// PluginDirs is a list of directories to register prober plugins from
+	PluginDirs []string `yaml:"pluginDirs" json:"pluginDirs"`
+
+	// Probers configures the chains of probers, keyed by purl type
+	Probers map[string]ChainConfig `yaml:"probers" json:"probers"`
+
+	// TrustPolicy is a trust policy to apply to the discovered documents
+	TrustPolicy *trust.Policy `yaml:"trustPolicy" json:"trustPolicy"`
+
+	// TrustPolicyFile is the path to a trust policy file. Relative paths are
+	// resolved from the directory of the configuration file.
+	TrustPolicyFile string `yaml:"trustPolicyFile" json:"trustPolicyFile"`
+
+	// CacheDir is a directory where the probers cache the data they
+	// download. Relative paths are resolved from the directory of the
+	// configuration file. When empty, nothing is cached.
+	CacheDir string `yaml:"cacheDir" json:"cacheDir"`
+
+	// OCI holds the defaults of the OCI prober
+	OCI OCIConfig `yaml:"oci" json:"oci"`
+
+	// Registries holds references to the credentials of container
+	// registries, keyed by registry host. Hosts are normalized when the
+	// configuration is parsed, docker.io becomes index.docker.io.
+	Registries map[string]RegistryConfig `yaml:"registries" json:"registries"`
+}
+
+// ChainConfig configures the probers of a purl type
+type ChainConfig struct {
+	// Policy is the chain policy: first-hit or query-all
+	Policy discovery.ChainPolicy `yaml:"policy" json:"policy"`
+
+	// Drivers lists the probers in the order they should be queried.
+	// Registered probers not listed are queried after them.
+	Drivers []DriverConfig `yaml:"drivers" json:"drivers"`
+}
+
+// DriverConfig configures a registered prober
+type DriverConfig struct {
+	// Name is the name the prober is registered with
+	Name string `yaml:"name" json:"name"`
+
+	// Enabled turns the prober on or off, defaults to true
+	Enabled *bool `yaml:"enabled" json:"enabled"`
+}
+
+// OCIConfig holds the settings of the OCI prober
+type OCIConfig struct {
+	Platform           string `yaml:"platform" json:"platform"`
+	AllPlatforms       bool   `yaml:"allPlatforms" json:"allPlatforms"`
+	TagPrefix          string `yaml:"tagPrefix" json:"tagPrefix"`
+	RepositoryOverride string `yaml:"repositoryOverride" json:"repositoryOverride"`
+
+	// Mirrors are ordered registry rewrite rules, see oci.Mirror
+	Mirrors []doci.Mirror `yaml:"mirrors" json:"mirrors"`
+
+	// Retry is the policy to retry registry requests. Durations are
+	// written as strings, eg "500ms". Fields not set take the values of
+	// oci.DefaultRetryPolicy.
+	Retry *doci.RetryPolicy `yaml:"retry" json:"retry"`
+
+	// Limits caps the size and shape of the downloaded documents
+	Limits *validation.Limits `yaml:"limits" json:"limits"`
+
+	// SubjectPolicy is what to do with attestations whose subjects don't
+	// match the probed image: reject (the default) or warn
+	SubjectPolicy oci.SubjectPolicy `yaml:"subjectPolicy" json:"subjectPolicy"`
+}
+
+// RegistryConfig references the credentials used to access a registry. The
+// configuration only holds the names of the environment variables with the
+// secrets, never the secrets themselves.
+type RegistryConfig struct {
+	UsernameEnv string `yaml:"usernameEnv" json:"usernameEnv"`
+	PasswordEnv string `yaml:"passwordEnv" json:"passwordEnv"`
+	TokenEnv    string `yaml:"tokenEnv" json:"tokenEnv"`
+}
+
+// Load reads the configuration from a YAML (or JSON) file and applies the
+// overrides from the environment.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading configuration file: %w", err)
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.TrustPolicyFile != "" && !filepath.IsAbs(c.TrustPolicyFile) {
+		c.TrustPolicyFile = filepath.Join(filepath.Dir(path), c.TrustPolicyFile)
+	}
+	if c.CacheDir != "" && !filepath.IsAbs(c.CacheDir) {
+		c.CacheDir = filepath.Join(filepath.Dir(path), c.CacheDir)
+	}
+
+	c.ApplyEnvironment()
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("validating configuration with environment overrides: %w", err)
+	}
+	return c, nil
+}
+
+// Parse reads the configuration from YAML (or JSON) data and validates it.
+// Environment overrides are not applied.
+func Parse(data []byte) (*Config, error) {
+	c := &Config{}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("parsing configuration: %w", err)
+	}
+	if c.Registries != nil {
+		registries, err := normalizeRegistries(c.Registries)
+		if err != nil {
+			return nil, fmt.Errorf("validating configuration: %w", err)
+		}
+		c.Registries = registries
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("validating configuration: %w", err)
+	}
+	return c, nil
+}
+
+// ApplyEnvironment overrides the configuration values with those set in the
+// environment.
+func (c *Config) ApplyEnvironment() {
+	if v, ok := os.LookupEnv(EnvPluginDirs); ok {
+		c.PluginDirs = filepath.SplitList(v)
+	}
+	if v, ok := os.LookupEnv(EnvTrustPolicy); ok {
+		c.TrustPolicy = nil
+		c.TrustPolicyFile = v
+	}
+	if v, ok := os.LookupEnv(EnvCacheDir); ok {
+		c.CacheDir = v
+	}
+	if v, ok := os.LookupEnv(EnvPlatform); ok {
+		c.OCI.Platform = v
+	}
+	if v, ok := os.LookupEnv(EnvTagPrefix); ok {
+		c.OCI.TagPrefix = v
+	}
+	if v, ok := os.LookupEnv(EnvRepositoryOverride); ok {
+		c.OCI.RepositoryOverride = v
+	}
+}
+
+// Validate checks the configuration and returns an error if it is not
+// well formed.
+func (c *Config) Validate() error {
+	errs := []error{}
+	for purlType, cc := range c.Probers {
+		switch cc.Policy {
+		case "", discovery.FirstHit, discovery.QueryAll:
+		default:
+			errs = append(errs, fmt.Errorf("invalid chain policy %q for purl type %s", cc.Policy, purlType))
+		}
+		for i, d := range cc.Drivers {
+			if d.Name == "" {
+				errs = append(errs, fmt.Errorf("driver #%d of purl type %s has no name", i, purlType))
+			}
+		}
+	}
+
+	if c.TrustPolicy != nil && c.TrustPolicyFile != "" {
+		errs = append(errs, errors.New("trustPolicy and trustPolicyFile are mutually exclusive"))
+	}
+	if c.TrustPolicy != nil {
+		if err := c.TrustPolicy.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid trust policy: %w", err))
+		}
+	}
+
+	ociOpts := c.ociOptions()
+	if err := ociOpts.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for host, rc := range c.Registries {
+		if rc.TokenEnv != "" && (rc.UsernameEnv != "" || rc.PasswordEnv != "") {
+			errs = append(errs, fmt.Errorf("registry %s: token and username/password are mutually exclusive", host))
+		}
+		if (rc.UsernameEnv == "") != (rc.PasswordEnv == "") {
+			errs = append(errs, fmt.Errorf("registry %s: username and password must be set together", host))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ociOptions returns the OCI prober options in the configuration
+func (c *Config) ociOptions() oci.Options {
+	return oci.Options{
+		Platform:           c.OCI.Platform,
+		AllPlatforms:       c.OCI.AllPlatforms,
+		TagPrefix:          c.OCI.TagPrefix,
+		RepositoryOverride: c.OCI.RepositoryOverride,
+		Mirrors:            c.OCI.Mirrors,
+		RetryPolicy:        c.OCI.Retry,
+		Limits:             c.OCI.Limits,
+		SubjectPolicy:      c.OCI.SubjectPolicy,
+		CacheDir:           c.CacheDir,
+	}
+}
+
+// Options returns the discovery options defined in the configuration
+func (c *Config) Options() (options.Options, error) {
+	ociOpts := c.ociOptions()
+	if len(c.Registries) > 0 {
+		kc, err := newKeychain(c.Registries)
+		if err != nil {
+			return options.Options{}, fmt.Errorf("reading registry credentials: %w", err)
+		}
+		ociOpts.Keychain = kc
+	}
+	return options.New().WithProberOptions(purl.TypeOCI, ociOpts), nil
+}
+
+// NewAgent returns a discovery agent configured with the settings in the
+// configuration. The agent starts with a copy of the default registry.
+func (c *Config) NewAgent() (*discovery.Agent, error) {
+	agent := discovery.NewAgent()
+
+	opts, err := c.Options()
+	if err != nil {
+		return nil, err
+	}
+	agent.Options = opts
+
+	for _, dir := range c.PluginDirs {
+		if err := agent.Registry.RegisterPlugins(dir); err != nil {
+			return nil, fmt.Errorf("registering plugins from %s: %w", dir, err)
+		}
+	}
+
+	if err := c.configureRegistry(agent.Registry); err != nil {
+		return nil, err
+	}
+
+	if agent.TrustPolicy, err = c.trustPolicy(); err != nil {
+		return nil, err
+	}
+
+	return agent, nil
+}
+
+// configureRegistry sets the order, policy and state of the probers
+func (c *Config) configureRegistry(reg *discovery.Registry) error {
+	for purlType, cc := range c.Probers {
+		if cc.Policy != "" {
+			if err := reg.SetChainPolicy(purlType, cc.Policy); err != nil {
+				return fmt.Errorf("setting chain policy of %s: %w", purlType, err)
+			}
+		}
+
+		if len(cc.Drivers) == 0 {
+			continue
+		}
+
+		names := []string{}
+		for _, d := range cc.Drivers {
+			names = append(names, d.Name)
+		}
+		if err := reg.SetDriverOrder(purlType, names); err != nil {
+			return fmt.Errorf("ordering %s probers: %w", purlType, err)
+		}
+
+		for _, d := range cc.Drivers {
+			var err error
+			if d.Enabled == nil || *d.Enabled {
+				err = reg.EnableDriver(purlType, d.Name)
+			} else {
+				err = reg.DisableDriver(purlType, d.Name)
+			}
+			if err != nil {
+				return fmt.Errorf("configuring %s prober %s: %w", purlType, d.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// trustPolicy returns the trust policy set in the configuration, if any
+func (c *Config) trustPolicy() (*trust.Policy, error) {
+	if c.TrustPolicyFile != "" {
+		p, err := trust.Load(c.TrustPolicyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading trust policy: %w", err)
+		}
+		return p, nil
+	}
+	return c.TrustPolicy, nil
+}
@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	purl "github.com/package-url/packageurl-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/pkg/discovery"
+	"github.com/openvex/discovery/pkg/discovery/validation"
+	doci "github.com/openvex/discovery/pkg/oci"
+	"github.com/openvex/discovery/pkg/probers/oci"
+	"github.com/openvex/discovery/pkg/trust"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		data    string
+		mustErr bool
+	}{
+		{"empty", "", false},
+		{"json", `{"oci": {"platform": "linux/arm64"}, "probers": {"oci": {"policy": "first-hit"}}}`, false},
+		{"json retry durations", `{"oci": {"retry": {"maxRetries": 2, "initialBackoff": "500ms"}}}`, false},
+		{"invalid yaml", "probers: [", true},
+		{"invalid chain policy", "probers:\n  oci:\n    policy: random\n", true},
+		{"driver without name", "probers:\n  oci:\n    drivers:\n      - enabled: false\n", true},
+		{"invalid platform", "oci:\n  platform: linux/arm64/v8/extra\n", true},
+		{"invalid retry policy", "oci:\n  retry:\n    maxRetries: -1\n", true},
+		{"invalid mirror", "oci:\n  mirrors:\n    - prefix: docker.io\n", true},
+		{"invalid limits", "oci:\n  limits:\n    maxStatements: -1\n", true},
+		{"invalid subject policy", "oci:\n  subjectPolicy: ignore\n", true},
+		{"invalid trust policy", "trustPolicy:\n  default: maybe\n", true},
+		{"both trust policies", "trustPolicy:\n  default: accept\ntrustPolicyFile: policy.yaml\n", true},
+		{"token and password", "registries:\n  ghcr.io:\n    tokenEnv: A\n    usernameEnv: B\n    passwordEnv: C\n", true},
+		{"username without password", "registries:\n  ghcr.io:\n    usernameEnv: B\n", true},
+		{"invalid registry", "registries:\n  \"ghcr.io/\":\n    tokenEnv: A\n", true},
+		{"registry aliases", "registries:\n  docker.io:\n    tokenEnv: A\n  index.docker.io:\n    tokenEnv: B\n", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.data))
+			if tc.mustErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	c, err := Load("testdata/config.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "testdata/policy.yaml", c.TrustPolicyFile)
+	require.Equal(t, "testdata/cache", c.CacheDir)
+	require.Equal(t, "linux/amd64", c.OCI.Platform)
+
+	t.Setenv(EnvPlatform, "linux/arm64")
+	t.Setenv(EnvRepositoryOverride, "example.com/attestations")
+	t.Setenv(EnvCacheDir, "/tmp/vex-cache")
+	c, err = Load("testdata/config.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "/tmp/vex-cache", c.CacheDir)
+	require.Equal(t, "linux/arm64", c.OCI.Platform)
+	require.Equal(t, "vex", c.OCI.TagPrefix)
+	require.Equal(t, "example.com/attestations", c.OCI.RepositoryOverride)
+
+	t.Setenv(EnvPlatform, "not/a/valid/platform")
+	_, err = Load("testdata/config.yaml")
+	require.Error(t, err)
+}
+
+func TestNewAgent(t *testing.T) {
+	c, err := Load("testdata/config.yaml")
+	require.NoError(t, err)
+
+	// Credentials referenced in the config must be set
+	_, err = c.NewAgent()
+	require.Error(t, err)
+
+	t.Setenv("TEST_REGISTRY_USER", "user")
+	t.Setenv("TEST_REGISTRY_PASSWORD", "secret")
+	agent, err := c.NewAgent()
+	require.NoError(t, err)
+
+	require.Equal(t, []discovery.ProberInfo{{Name: discovery.OCIDriverName, Enabled: false}}, agent.Registry.Drivers(purl.TypeOCI))
+	require.Equal(t, []discovery.ProberInfo{{Name: discovery.OCIDriverName, Enabled: true}}, discovery.Drivers(purl.TypeOCI))
+
+	require.NotNil(t, agent.TrustPolicy)
+	require.Equal(t, trust.ModeWarn, agent.TrustPolicy.Mode)
+
+	ociOpts, err := oci.GetOptions(agent.Options)
+	require.NoError(t, err)
+	require.Equal(t, "linux/amd64", ociOpts.Platform)
+	require.Equal(t, "vex", ociOpts.TagPrefix)
+	require.Equal(t, "testdata/cache", ociOpts.CacheDir)
+	require.Len(t, ociOpts.Mirrors, 1)
+	require.Equal(t, &doci.RetryPolicy{
+		MaxRetries: 5, InitialBackoff: 500 * time.Millisecond, MaxBackoff: time.Minute, Jitter: doci.DefaultRetryPolicy.Jitter,
+	}, ociOpts.RetryPolicy)
+	require.Equal(t, &validation.Limits{MaxPayloadSize: 1048576, MaxStatements: 500}, ociOpts.Limits)
+	require.NotNil(t, ociOpts.Keychain)
+
+	auth, err := ociOpts.Keychain.Resolve(name.MustParseReference("registry.example.com/image").Context())
+	require.NoError(t, err)
+	ac, err := auth.Authorization()
+	require.NoError(t, err)
+	require.Equal(t, &authn.AuthConfig{Username: "user", Password: "secret"}, ac)
+
+	// Unknown probers in the config are an error
+	c.Probers["oci"] = ChainConfig{Drivers: []DriverConfig{{Name: "missing"}}}
+	_, err = c.NewAgent()
+	require.Error(t, err)
+}
+
+func TestKeychainDockerHub(t *testing.T) {
+	t.Setenv("TEST_HUB_USER", "user")
+	t.Setenv("TEST_HUB_PASSWORD", "secret")
+	c, err := Parse([]byte("registries:\n  docker.io:\n    usernameEnv: TEST_HUB_USER\n    passwordEnv: TEST_HUB_PASSWORD\n"))
+	require.NoError(t, err)
+	require.Contains(t, c.Registries, "index.docker.io")
+
+	for _, registries := range []map[string]RegistryConfig{
+		c.Registries,
+		// Configurations built in code are normalized too
+		{"docker.io": {UsernameEnv: "TEST_HUB_USER", PasswordEnv: "TEST_HUB_PASSWORD"}},
+	} {
+		kc, err := newKeychain(registries)
+		require.NoError(t, err)
+		auth, err := kc.Resolve(name.MustParseReference("alpine:latest").Context())
+		require.NoError(t, err)
+		ac, err := auth.Authorization()
+		require.NoError(t, err)
+		require.Equal(t, &authn.AuthConfig{Username: "user", Password: "secret"}, ac)
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// envKeychain resolves registry credentials from the environment variables
+// referenced in the configuration.
+type envKeychain map[string]authn.AuthConfig
+
+// registryHost returns the name of a registry as reported by the keychain
+// resources, for example index.docker.io for docker.io.
+func registryHost(host string) (string, error) {
+	reg, err := name.NewRegistry(host)
+	if err != nil {
+		return "", fmt.Errorf("invalid registry %q: %w", host, err)
+	}
+	return reg.RegistryStr(), nil
+}
+
+// normalizeRegistries rekeys the registries by their normalized host. Hosts
+// that are the same registry once normalized are an error.
+func normalizeRegistries(registries map[string]RegistryConfig) (map[string]RegistryConfig, error) {
+	ret := make(map[string]RegistryConfig, len(registries))
+	for host, rc := range registries {
+		key, err := registryHost(host)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := ret[key]; ok {
+			return nil, fmt.Errorf("registry %s is configured more than once", key)
+		}
+		ret[key] = rc
+	}
+	return ret, nil
+}
+
+// newKeychain reads the credentials of the configured registries and returns
+// a keychain that falls back to the default docker keychain for the rest.
+func newKeychain(registries map[string]RegistryConfig) (authn.Keychain, error) {
+	registries, err := normalizeRegistries(registries)
+	if err != nil {
+		return nil, err
+	}
+	kc := envKeychain{}
+	for host, rc := range registries {
+		ac := authn.AuthConfig{}
+		if rc.TokenEnv != "" {
+			if ac.RegistryToken, err = readEnv(rc.TokenEnv); err != nil {
+				return nil, fmt.Errorf("registry %s: %w", host, err)
+			}
+		}
+		if rc.UsernameEnv != "" {
+			if ac.Username, err = readEnv(rc.UsernameEnv); err != nil {
+				return nil, fmt.Errorf("registry %s: %w", host, err)
+			}
+			if ac.Password, err = readEnv(rc.PasswordEnv); err != nil {
+				return nil, fmt.Errorf("registry %s: %w", host, err)
+			}
+		}
+		if ac == (authn.AuthConfig{}) {
+			continue
+		}
+		kc[host] = ac
+	}
+	return authn.NewMultiKeychain(kc, authn.DefaultKeychain), nil
+}
+
+// readEnv returns the value of a referenced environment variable, it fails
+// if the variable is not set.
+func readEnv(name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return v, nil
+}
+
+// Resolve returns the credentials of the registry if the configuration has
+// them. Otherwise it returns anonymous so the next keychain is tried.
+func (kc envKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if ac, ok := kc[target.RegistryStr()]; ok {
+		return authn.FromConfig(ac), nil
+	}
+	return authn.Anonymous, nil
+}
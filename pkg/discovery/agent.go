@@ -6,60 +6,63 @@ package discovery
 //go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -generate
 
 import (
+	"errors"
 	"fmt"
-	"sync"
+	"net/http"
 
 	"github.com/openvex/go-vex/pkg/vex"
 	purl "github.com/package-url/packageurl-go"
 
-	"github.com/openvex/discovery/pkg/probers/oci"
-
 	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/discovery/telemetry"
+	"github.com/openvex/discovery/pkg/errdefs"
+	doci "github.com/openvex/discovery/pkg/oci"
+	"github.com/openvex/discovery/pkg/sbom"
+	"github.com/openvex/discovery/pkg/trust"
 )
 
-var (
-	regMtx  sync.RWMutex
-	probers = map[string]VexProbe{}
-)
-
-func init() {
-	RegisterBuiltInDrivers()
-}
-
-// RegisterBuiltInDrivers adds all the built in backend drivers to the
-// VexProbers collection.
-func RegisterBuiltInDrivers() {
-	RegisterDriver(purl.TypeOCI, oci.New())
-}
-
-// RegisterDriver adds a new VexProbe to the drivers collection.
-func RegisterDriver(purlType string, probe VexProbe) {
-	regMtx.Lock()
-	probers[purlType] = probe
-	regMtx.Unlock()
-}
-
-// UnregisterDrivers removes all registered backend drivers from the probers
-// collection. This is useful when you want to enable only specific drivers
-// or register custom ones.
-func UnregisterDrivers() {
-	regMtx.Lock()
-	probers = map[string]VexProbe{}
-	regMtx.Unlock()
-}
-
 // Probe is the main object that inspects repositories and looks for security
 // documents. To create a new Probe use the `NewProbe` function
 type Agent struct {
 	impl    agentImplementation
 	Options options.Options
+
+	// Registry holds the probers used by the agent. New agents get a copy
+	// of the default registry, changes to it only affect this agent.
+	Registry *Registry
+
+	// TrustPolicy decides which of the discovered documents are accepted.
+	// When nil, all documents are returned.
+	TrustPolicy *trust.Policy
+
+	// MaxReferenceDepth is the number of links ProbePurlWithReferences
+	// follows from the probed purl. Zero disables following references.
+	MaxReferenceDepth int
+
+	// FollowURLs lets ProbePurlWithReferences download the documents linked
+	// by HTTPS URLs. It is off by default, only links to purls are followed.
+	FollowURLs bool
+
+	// FollowReferencesExtension lets ProbePurlWithReferences follow the links
+	// in the top level references list of OpenVEX documents. The list is an
+	// extension of this module, not part of the OpenVEX spec, so it is off
+	// by default and only the links in SBOMs are followed.
+	FollowReferencesExtension bool
+
+	// HTTPClient is the client used to download linked documents. New
+	// agents get one from NewHTTPClient with the default retry policy.
+	HTTPClient *http.Client
 }
 
 // NewAgent creates a new discovery agent
 func NewAgent() *Agent {
 	return &Agent{
-		impl:    &defaultAgentImplementation{},
-		Options: options.Default,
+		impl:              &defaultAgentImplementation{},
+		Options:           options.New(),
+		Registry:          defaultRegistry.Clone(),
+		MaxReferenceDepth: DefaultMaxReferenceDepth,
+		HTTPClient:        NewHTTPClient(doci.DefaultRetryPolicy),
 	}
 }
 
@@ -70,23 +73,222 @@ func (agent *Agent) SetImplementation(impl agentImplementation) {
 // ProbePURL examines an PackageURL and retrieves all the OpenVEX documents
 // it can find by testing known locations of its identifiers and type.
 func (agent *Agent) ProbePurl(purlString string) ([]*vex.VEX, error) {
+	docs, err := agent.ProbePurlWithProvenance(purlString)
+	if err != nil {
+		return nil, err
+	}
+	return results.ToVEX(docs), nil
+}
+
+// ProbePurlWithProvenance examines a PackageURL just as ProbePurl but returns
+// the documents along with the data about where they were found. If the agent
+// has a trust policy, each document records the policy decision.
+func (agent *Agent) ProbePurlWithProvenance(purlString string) ([]*results.Document, error) {
+	opts, span := telemetry.Start(agent.Options, "ProbePurl", telemetry.AttrPurl.String(purlString))
+	docs, err := agent.probePurl(opts, purlString)
+	telemetry.End(span, err)
+	return docs, err
+}
+
+// ProbeImageReference probes a container image reference, for example
+// ghcr.io/openvex/discovery:v1.0.0, without building its purl by hand.
+func (agent *Agent) ProbeImageReference(refString string) ([]*vex.VEX, error) {
+	docs, err := agent.ProbeImageReferenceWithProvenance(refString)
+	if err != nil {
+		return nil, err
+	}
+	return results.ToVEX(docs), nil
+}
+
+// ProbeImageReferenceWithProvenance converts a container image reference to
+// its oci purl and probes it just as ProbePurlWithProvenance. If the OCI
+// prober options select a platform, the purl gets its os and arch.
+func (agent *Agent) ProbeImageReferenceWithProvenance(refString string) ([]*results.Document, error) {
+	p, err := agent.impl.ReferenceToPurl(agent.Options, refString)
+	if err != nil {
+		return nil, errdefs.New(errdefs.ErrParse, "converting image reference to purl: %w", err)
+	}
+	return agent.ProbePurlWithProvenance(p.String())
+}
+
+// ProbeImageSBOM probes a container image reference and the components
+// listed in the SBOMs attested to it, see ProbeImageSBOMWithProvenance.
+func (agent *Agent) ProbeImageSBOM(refString string) ([]*vex.VEX, error) {
+	docs, err := agent.ProbeImageSBOMWithProvenance(refString)
+	if err != nil {
+		return nil, err
+	}
+	return results.ToVEX(docs), nil
+}
+
+// ProbeImageSBOMWithProvenance probes a container image and then reads the
+// SBOM attestations of the image and probes each of the component purls
+// listed in them with the registered probers. Components that are images are
+// probed in the same way, following their own SBOMs. The returned documents
+// cover the image and its contents, their provenance records the purl of the
+// component where they were found.
+//
+// Components of types without a registered prober and components not found
+// are skipped. Components that fail to be probed are returned as a rejected
+// document with the error as its problem, the probe goes on with the rest.
+// Errors probing the image itself stop the probe.
+func (agent *Agent) ProbeImageSBOMWithProvenance(refString string) ([]*results.Document, error) {
+	p, err := agent.impl.ReferenceToPurl(agent.Options, refString)
+	if err != nil {
+		return nil, errdefs.New(errdefs.ErrParse, "converting image reference to purl: %w", err)
+	}
+
+	docs := []*results.Document{}
+	opts, span := telemetry.Start(agent.Options, "ProbeImageSBOM", telemetry.AttrReference.String(refString))
+	err = agent.probeSBOM(opts, p.String(), map[string]struct{}{}, collect(&docs))
+	telemetry.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// probeSBOM probes an image purl and the components in its SBOMs, passing
+// the documents found at each purl to emit. Seen records the purls already
+// probed to avoid probing them twice.
+func (agent *Agent) probeSBOM(opts options.Options, purlString string, seen map[string]struct{}, emit emitFunc) error {
+	p, err := agent.impl.ParsePurl(purlString)
+	if err != nil {
+		return errdefs.New(errdefs.ErrParse, "parsing purl: %w", err)
+	}
+	seen[p.String()] = struct{}{}
+
+	docs, err := agent.probePurl(opts, purlString)
+	if err != nil {
+		return err
+	}
+	if err := emit(docs); err != nil {
+		return err
+	}
+
+	contents, err := agent.findSBOM(opts, p)
+	if err != nil {
+		return fmt.Errorf("reading sbom of %s: %w", purlString, err)
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = options.Default.Logger
+	}
+
+	for _, c := range contents.Purls {
+		cp, err := agent.impl.ParsePurl(c)
+		if err != nil {
+			logger.DebugContext(opts.Context, "skipping invalid sbom purl", "purl", c, "error", err)
+			continue
+		}
+		if _, ok := seen[cp.String()]; ok {
+			continue
+		}
+		seen[cp.String()] = struct{}{}
+
+		if cp.Type == purl.TypeOCI {
+			err = agent.probeSBOM(opts, c, seen, emit)
+		} else {
+			var cdocs []*results.Document
+			if cdocs, err = agent.probePurl(opts, c); err == nil {
+				if err := emit(cdocs); err != nil {
+					return err
+				}
+			}
+		}
+		if errors.Is(err, errdefs.ErrUnsupportedPurlType) || errors.Is(err, errdefs.ErrNotFound) {
+			logger.DebugContext(opts.Context, "skipping sbom component", "purl", c, "error", err)
+			continue
+		}
+		if err != nil {
+			// Canceled probes stop instead of failing each component
+			if opts.Context != nil && opts.Context.Err() != nil {
+				return err
+			}
+			logger.WarnContext(opts.Context, "probing sbom component failed", "purl", c, "error", err)
+			if err := emit([]*results.Document{{
+				Provenance: results.Provenance{Purl: cp.String()},
+				Problems:   []string{fmt.Sprintf("probing sbom component: %s", err)},
+			}}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// findSBOM reads the SBOMs of a purl with the probers registered for its type
+func (agent *Agent) findSBOM(opts options.Options, p purl.PackageURL) (*sbom.Contents, error) {
+	pkgProbe, err := agent.impl.GetPackageProbe(agent.Registry, opts, p)
+	if err != nil {
+		return nil, fmt.Errorf("getting package probe for purl type %s: %w", p.Type, err)
+	}
+	return agent.impl.FindSBOM(opts, pkgProbe, p)
+}
+
+// probePurl implements ProbePurlWithProvenance using the options passed
+func (agent *Agent) probePurl(opts options.Options, purlString string) ([]*results.Document, error) {
 	p, err := agent.impl.ParsePurl(purlString)
 	if err != nil {
-		return nil, fmt.Errorf("parsing purl: %w", err)
+		return nil, errdefs.New(errdefs.ErrParse, "parsing purl: %w", err)
 	}
 
-	pkgProbe, err := agent.impl.GetPackageProbe(agent.Options, p)
+	// Probers keep the options they get here, so they are passed without
+	// the context of the GetPackageProbe span which ends right away.
+	_, span := telemetry.Start(opts, "GetPackageProbe", telemetry.AttrPurlType.String(p.Type))
+	pkgProbe, err := agent.impl.GetPackageProbe(agent.Registry, opts, p)
+	telemetry.End(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("getting package probe for purl type %s: %w", p.Type, err)
 	}
 
-	docs, err := agent.impl.FindDocumentsFromPurl(agent.Options, pkgProbe, p)
+	docs, err := agent.impl.FindDocumentsFromPurl(opts, pkgProbe, p)
 	if err != nil {
 		return nil, fmt.Errorf("fetching documents: %w", err)
 	}
 
+	docs, err = agent.impl.ApplyTrustPolicy(agent.TrustPolicy, p, docs)
+	if err != nil {
+		return nil, fmt.Errorf("applying trust policy: %w", err)
+	}
+
 	return docs, nil
 }
 
-// TODO(puerco): ProbeSBOM
+// QueryVulnerability probes a package URL and returns the effective status of
+// a vulnerability in it. The vulnerability can be referenced by its name or
+// any of its aliases. The statements of all documents found are merged and
+// the latest one matching the purl determines the status. Documents the trust
+// policy did not trust are left out even when the policy only warns.
+//
+// If none of the discovered documents has data about the vulnerability, the
+// returned status will be nil.
+func (agent *Agent) QueryVulnerability(purlString, vulnID string) (*VulnerabilityStatus, error) {
+	if vulnID == "" {
+		return nil, fmt.Errorf("vulnerability identifier not specified")
+	}
+
+	found, err := agent.ProbePurlWithProvenance(purlString)
+	if err != nil {
+		return nil, err
+	}
+
+	trusted := make([]*results.Document, 0, len(found))
+	for _, d := range found {
+		if d.Trust != nil && !d.Trust.Trusted {
+			continue
+		}
+		trusted = append(trusted, d)
+	}
+	docs := results.ToVEX(trusted)
+
+	status, err := agent.impl.FindEffectiveStatus(docs, purlString, vulnID)
+	if err != nil {
+		return nil, fmt.Errorf("computing effective status: %w", err)
+	}
+
+	return status, nil
+}
+
 // TODO(puerco): ProbeHash
@@ -9,11 +9,24 @@ import (
 
 	"github.com/openvex/discovery/pkg/discovery"
 	"github.com/openvex/discovery/pkg/discovery/discoveryfakes"
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/errdefs"
+	"github.com/openvex/discovery/pkg/sbom"
+	"github.com/openvex/discovery/pkg/trust"
 	"github.com/openvex/go-vex/pkg/vex"
 	"github.com/package-url/packageurl-go"
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// passTrustPolicy is an ApplyTrustPolicy stub returning the documents as is
+func passTrustPolicy(_ *trust.Policy, _ packageurl.PackageURL, docs []*results.Document) ([]*results.Document, error) {
+	return docs, nil
+}
+
 func TestProbePurl(t *testing.T) {
 	syntErr := fmt.Errorf("synthetic error")
 	for _, tc := range []struct {
@@ -25,7 +38,8 @@ func TestProbePurl(t *testing.T) {
 			name: "success",
 			prepare: func(a *discovery.Agent) {
 				impl := &discoveryfakes.FakeAgentImplementation{}
-				impl.FindDocumentsFromPurlReturns([]*vex.VEX{{}}, nil)
+				impl.FindDocumentsFromPurlReturns([]*results.Document{{VEX: &vex.VEX{}}}, nil)
+				impl.ApplyTrustPolicyStub = passTrustPolicy
 				a.SetImplementation(impl)
 			},
 			mustErr: false,
@@ -59,6 +73,15 @@ func TestProbePurl(t *testing.T) {
 			},
 			mustErr: true,
 		},
+		{
+			name: "ApplyTrustPolicy fails",
+			prepare: func(a *discovery.Agent) {
+				impl := &discoveryfakes.FakeAgentImplementation{}
+				impl.ApplyTrustPolicyReturns(nil, syntErr)
+				a.SetImplementation(impl)
+			},
+			mustErr: true,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			agent := discovery.NewAgent()
@@ -69,7 +92,229 @@ func TestProbePurl(t *testing.T) {
 				return
 			}
 			require.NoError(t, err)
-			require.NotNil(t, docs)
+			require.Len(t, docs, 1)
+		})
+	}
+}
+
+func TestProbeImageReference(t *testing.T) {
+	agent := discovery.NewAgent()
+	impl := &discoveryfakes.FakeAgentImplementation{}
+	p := packageurl.NewPackageURL("oci", "", "discovery", "", nil, "")
+	impl.ReferenceToPurlReturns(*p, nil)
+	found := []*results.Document{{VEX: &vex.VEX{}}}
+	impl.FindDocumentsFromPurlReturns(found, nil)
+	impl.ApplyTrustPolicyReturns(found, nil)
+	agent.SetImplementation(impl)
+
+	docs, err := agent.ProbeImageReference("ghcr.io/openvex/discovery:latest")
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	_, ref := impl.ReferenceToPurlArgsForCall(0)
+	require.Equal(t, "ghcr.io/openvex/discovery:latest", ref)
+	require.Equal(t, p.String(), impl.ParsePurlArgsForCall(0))
+
+	impl.ReferenceToPurlReturns(packageurl.PackageURL{}, fmt.Errorf("synthetic error"))
+	_, err = agent.ProbeImageReference("ghcr.io/openvex/discovery:latest")
+	require.ErrorIs(t, err, errdefs.ErrParse)
+}
+
+func TestProbeImageSBOM(t *testing.T) {
+	image := "pkg:oci/wolfi-base?repository_url=cgr.dev%2Fchainguard&tag=latest"
+	child := "pkg:oci/busybox@sha256%3Aabc?repository_url=cgr.dev%2Fchainguard"
+	apk := "pkg:apk/wolfi/busybox@1.36.1-r0?arch=x86_64"
+	for _, tc := range []struct {
+		name     string
+		probeErr error
+		expected []string
+		rejected []string
+	}{
+		{"unsupported components skipped", errdefs.New(errdefs.ErrUnsupportedPurlType, "no prober"), []string{image, child}, []string{}},
+		{"components not found skipped", errdefs.New(errdefs.ErrNotFound, "not found"), []string{image, child}, []string{}},
+		{"component fails", fmt.Errorf("synthetic error"), []string{image, apk, child}, []string{apk}},
+		{"invalid argument", errdefs.New(errdefs.ErrInvalidArgument, "bad platform"), []string{image, apk, child}, []string{apk}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			agent := discovery.NewAgent()
+			impl := &discoveryfakes.FakeAgentImplementation{}
+			p, err := packageurl.FromString(image)
+			require.NoError(t, err)
+			impl.ReferenceToPurlReturns(p, nil)
+			impl.ParsePurlStub = packageurl.FromString
+			impl.FindSBOMStub = func(_ options.Options, _ discovery.VexProbe, p packageurl.PackageURL) (*sbom.Contents, error) {
+				if p.Name == "wolfi-base" {
+					return &sbom.Contents{Purls: []string{apk, child, image}}, nil
+				}
+				return &sbom.Contents{Purls: []string{apk}}, nil
+			}
+			impl.GetPackageProbeStub = func(_ *discovery.Registry, _ options.Options, p packageurl.PackageURL) (discovery.VexProbe, error) {
+				if p.Type == "apk" {
+					return nil, tc.probeErr
+				}
+				return &discoveryfakes.FakeVexProbe{}, nil
+			}
+			impl.FindDocumentsFromPurlStub = func(_ options.Options, _ discovery.VexProbe, p packageurl.PackageURL) ([]*results.Document, error) {
+				return []*results.Document{{VEX: &vex.VEX{}, Provenance: results.Provenance{Purl: p.String()}}}, nil
+			}
+			impl.ApplyTrustPolicyStub = func(_ *trust.Policy, _ packageurl.PackageURL, docs []*results.Document) ([]*results.Document, error) {
+				return docs, nil
+			}
+			agent.SetImplementation(impl)
+
+			docs, err := agent.ProbeImageSBOMWithProvenance("cgr.dev/chainguard/wolfi-base:latest")
+			require.NoError(t, err)
+			purls := []string{}
+			rejected := []string{}
+			for _, d := range docs {
+				purls = append(purls, d.Provenance.Purl)
+				if d.Rejected() {
+					rejected = append(rejected, d.Provenance.Purl)
+				}
+			}
+			require.Equal(t, tc.expected, purls)
+			require.Equal(t, tc.rejected, rejected)
+			require.Equal(t, 2, impl.FindSBOMCallCount())
+			require.Equal(t, 5, impl.GetPackageProbeCallCount())
+		})
+	}
+}
+
+func TestProbePurlErrorKinds(t *testing.T) {
+	agent := discovery.NewAgent()
+	for _, tc := range []struct {
+		name string
+		purl string
+		kind error
+	}{
+		{"invalid purl", "not a purl", errdefs.ErrParse},
+		{"unsupported purl type", "pkg:nothandled/test@1.0.0", errdefs.ErrUnsupportedPurlType},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := agent.ProbePurl(tc.purl)
+			require.ErrorIs(t, err, tc.kind)
+		})
+	}
+}
+
+func TestProbePurlTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	agent := discovery.NewAgent()
+	agent.Options.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	impl := &discoveryfakes.FakeAgentImplementation{}
+	impl.FindDocumentsFromPurlReturns([]*results.Document{{VEX: &vex.VEX{}}}, nil)
+	impl.ApplyTrustPolicyStub = passTrustPolicy
+	agent.SetImplementation(impl)
+
+	docs, err := agent.ProbePurl("pkg:oci/scratch")
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, "GetPackageProbe", spans[0].Name())
+	require.Equal(t, "ProbePurl", spans[1].Name())
+	require.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+
+	// The probers get the context of the ProbePurl span
+	opts, _, _ := impl.FindDocumentsFromPurlArgsForCall(0)
+	require.Equal(t, spans[1].SpanContext().SpanID(), trace.SpanContextFromContext(opts.Context).SpanID())
+}
+
+func TestQueryVulnerability(t *testing.T) {
+	syntErr := fmt.Errorf("synthetic error")
+	for _, tc := range []struct {
+		name     string
+		vulnID   string
+		prepare  func(*discovery.Agent)
+		mustErr  bool
+		noStatus bool
+	}{
+		{
+			name:   "success",
+			vulnID: "CVE-2023-5363",
+			prepare: func(a *discovery.Agent) {
+				impl := &discoveryfakes.FakeAgentImplementation{}
+				impl.FindDocumentsFromPurlReturns([]*results.Document{{VEX: &vex.VEX{}}}, nil)
+				impl.ApplyTrustPolicyStub = passTrustPolicy
+				impl.FindEffectiveStatusStub = func(docs []*vex.VEX, _, _ string) (*discovery.VulnerabilityStatus, error) {
+					if len(docs) != 1 {
+						return nil, nil
+					}
+					return &discovery.VulnerabilityStatus{Status: vex.StatusNotAffected}, nil
+				}
+				a.SetImplementation(impl)
+			},
+			mustErr: false,
+		},
+		{
+			name:   "untrusted documents ignored",
+			vulnID: "CVE-2023-5363",
+			prepare: func(a *discovery.Agent) {
+				impl := &discoveryfakes.FakeAgentImplementation{}
+				impl.FindDocumentsFromPurlReturns([]*results.Document{{VEX: &vex.VEX{}}}, nil)
+				// A policy in warn mode returns the untrusted documents
+				impl.ApplyTrustPolicyStub = func(_ *trust.Policy, _ packageurl.PackageURL, docs []*results.Document) ([]*results.Document, error) {
+					for _, d := range docs {
+						d.Trust = &results.TrustDecision{Trusted: false, Reason: "no signature"}
+					}
+					return docs, nil
+				}
+				impl.FindEffectiveStatusStub = func(docs []*vex.VEX, _, _ string) (*discovery.VulnerabilityStatus, error) {
+					if len(docs) == 0 {
+						return nil, nil
+					}
+					return &discovery.VulnerabilityStatus{Status: vex.StatusNotAffected}, nil
+				}
+				a.SetImplementation(impl)
+			},
+			noStatus: true,
+		},
+		{
+			name:   "no vulnerability",
+			vulnID: "",
+			prepare: func(a *discovery.Agent) {
+				impl := &discoveryfakes.FakeAgentImplementation{}
+				a.SetImplementation(impl)
+			},
+			mustErr: true,
+		},
+		{
+			name:   "probing fails",
+			vulnID: "CVE-2023-5363",
+			prepare: func(a *discovery.Agent) {
+				impl := &discoveryfakes.FakeAgentImplementation{}
+				impl.FindDocumentsFromPurlReturns(nil, syntErr)
+				a.SetImplementation(impl)
+			},
+			mustErr: true,
+		},
+		{
+			name:   "FindEffectiveStatus fails",
+			vulnID: "CVE-2023-5363",
+			prepare: func(a *discovery.Agent) {
+				impl := &discoveryfakes.FakeAgentImplementation{}
+				impl.FindEffectiveStatusReturns(nil, syntErr)
+				a.SetImplementation(impl)
+			},
+			mustErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			agent := discovery.NewAgent()
+			tc.prepare(agent)
+			status, err := agent.QueryVulnerability(
+				"pkg:oci/scratch@sha256%3A0000000000000000000000000000000000000000000000000000000000000000", tc.vulnID,
+			)
+			if tc.mustErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tc.noStatus {
+				require.Nil(t, status)
+				return
+			}
+			require.NotNil(t, status)
 		})
 	}
 }
@@ -0,0 +1,248 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/openvex/go-vex/pkg/vex"
+	purl "github.com/package-url/packageurl-go"
+
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/discovery/telemetry"
+	"github.com/openvex/discovery/pkg/errdefs"
+	"github.com/openvex/discovery/pkg/sbom"
+)
+
+// ChainPolicy defines how the probers registered for a purl type are queried
+type ChainPolicy string
+
+const (
+	// FirstHit queries the probers in order and stops at the first one that
+	// returns documents. Probers that fail are skipped, their errors are
+	// returned if no prober finds documents. This is the default.
+	FirstHit ChainPolicy = "first-hit"
+
+	// QueryAll queries all the probers in the chain and returns the documents
+	// found by all of them. It fails if probers fail and none finds documents.
+	QueryAll ChainPolicy = "query-all"
+)
+
+// ProberInfo describes a prober registered in a chain
+type ProberInfo struct {
+	Name    string
+	Enabled bool
+}
+
+// chainEntry is a prober registered in a chain. Entries have either a shared
+// probe or a factory to create a new probe each time the chain is used.
+type chainEntry struct {
+	name    string
+	probe   VexProbe
+	factory ProberFactory
+	enabled bool
+
+	// perCall is true when the probe was created by the factory for this chain
+	perCall bool
+}
+
+// Chain is an ordered list of probers registered for a purl type. Chains
+// implement VexProbe so the agent can use them as any other prober.
+type Chain struct {
+	Policy  ChainPolicy
+	entries []*chainEntry
+}
+
+// add registers a prober at the end of the chain. If a prober with the same
+// name is already registered, it is replaced in place.
+func (chain *Chain) add(entry *chainEntry) {
+	for i, e := range chain.entries {
+		if e.name == entry.name {
+			entry.enabled = e.enabled
+			chain.entries[i] = entry
+			return
+		}
+	}
+	chain.entries = append(chain.entries, entry)
+}
+
+// setEnabled enables or disables a prober in the chain
+func (chain *Chain) setEnabled(name string, enabled bool) error {
+	for _, e := range chain.entries {
+		if e.name == name {
+			e.enabled = enabled
+			return nil
+		}
+	}
+	return fmt.Errorf("prober %q not registered", name)
+}
+
+// reorder moves the named probers to the front of the chain in the order
+// listed. Probers not listed keep their relative order after them.
+func (chain *Chain) reorder(names []string) error {
+	entries := make([]*chainEntry, 0, len(chain.entries))
+	seen := map[string]struct{}{}
+	for _, name := range names {
+		if _, ok := seen[name]; ok {
+			return fmt.Errorf("prober %q listed more than once", name)
+		}
+		found := false
+		for _, e := range chain.entries {
+			if e.name == name {
+				entries = append(entries, e)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("prober %q not registered", name)
+		}
+		seen[name] = struct{}{}
+	}
+	for _, e := range chain.entries {
+		if _, ok := seen[e.name]; !ok {
+			entries = append(entries, e)
+		}
+	}
+	chain.entries = entries
+	return nil
+}
+
+// Probers returns the list of probers in the chain, in order
+func (chain *Chain) Probers() []ProberInfo {
+	ret := []ProberInfo{}
+	for _, e := range chain.entries {
+		ret = append(ret, ProberInfo{Name: e.name, Enabled: e.enabled})
+	}
+	return ret
+}
+
+// clone returns a copy of the chain that is not affected by later changes
+// to the original.
+func (chain *Chain) clone() *Chain {
+	ret := &Chain{Policy: chain.Policy, entries: make([]*chainEntry, 0, len(chain.entries))}
+	for _, e := range chain.entries {
+		ec := *e
+		ret.entries = append(ret.entries, &ec)
+	}
+	return ret
+}
+
+// instantiate returns a copy of the chain where all the probers registered
+// with a factory have a new probe instance.
+func (chain *Chain) instantiate() *Chain {
+	ret := chain.clone()
+	for _, e := range ret.entries {
+		if e.factory != nil && e.enabled {
+			e.probe = e.factory()
+			e.perCall = true
+		}
+	}
+	return ret
+}
+
+// enabledEntries returns the probers in the chain that are enabled
+func (chain *Chain) enabledEntries() []*chainEntry {
+	ret := []*chainEntry{}
+	for _, e := range chain.entries {
+		if e.enabled && e.probe != nil {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+// SetOptions passes the options to the probers created for this chain. Shared
+// probers don't get their options set, they get them with each call to
+// FindDocumentsFromPurl.
+func (chain *Chain) SetOptions(opts options.Options) {
+	for _, e := range chain.entries {
+		if e.perCall && e.probe != nil {
+			e.probe.SetOptions(opts)
+		}
+	}
+}
+
+// FindDocumentsFromPurl queries the probers in the chain according to the
+// chain policy.
+func (chain *Chain) FindDocumentsFromPurl(opts options.Options, p purl.PackageURL) ([]*vex.VEX, error) {
+	docs, err := chain.FindDocumentsWithProvenance(opts, p)
+	if err != nil {
+		return nil, err
+	}
+	return results.ToVEX(docs), nil
+}
+
+// FindDocumentsWithProvenance queries the probers in the chain according to
+// the chain policy and returns the documents with their provenance data.
+func (chain *Chain) FindDocumentsWithProvenance(opts options.Options, p purl.PackageURL) ([]*results.Document, error) {
+	entries := chain.enabledEntries()
+	if len(entries) == 0 {
+		return nil, errdefs.New(errdefs.ErrUnsupportedPurlType, "no probers enabled for purl type %s", p.Type)
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = options.Default.Logger
+	}
+
+	docs := []*results.Document{}
+	errs := []error{}
+	for _, e := range entries {
+		start := time.Now()
+		found, err := findDocuments(opts, e.probe, p)
+		telemetry.RecordProbe(opts, e.name, p.Type, start, results.CountAccepted(found), err)
+		if err != nil {
+			logger.WarnContext(opts.Context, fmt.Sprintf("prober %s failed: %v", e.name, err))
+			errs = append(errs, fmt.Errorf("%s: %w", e.name, err))
+			continue
+		}
+		docs = append(docs, found...)
+		if chain.Policy != QueryAll && results.CountAccepted(found) > 0 {
+			return docs, nil
+		}
+	}
+
+	// Failures are only reported when no prober found documents, a prober
+	// that found nothing does not hide the errors of the rest.
+	if len(errs) == len(entries) || (len(errs) > 0 && results.CountAccepted(docs) == 0) {
+		return nil, errors.Join(errs...)
+	}
+	return docs, nil
+}
+
+// FindSBOM reads the SBOMs of a purl with the probers in the chain that
+// implement SBOMProbe. The probers are queried in order until one of them
+// returns an SBOM listing components or VEX references.
+func (chain *Chain) FindSBOM(opts options.Options, p purl.PackageURL) (*sbom.Contents, error) {
+	var contents *sbom.Contents
+	errs := []error{}
+	for _, e := range chain.enabledEntries() {
+		sp, ok := e.probe.(SBOMProbe)
+		if !ok {
+			continue
+		}
+		found, err := sp.FindSBOM(opts, p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.name, err))
+			continue
+		}
+		if len(found.Purls) > 0 || len(found.VEXReferences) > 0 {
+			return found, nil
+		}
+		contents = found
+	}
+
+	switch {
+	case contents != nil:
+		return contents, nil
+	case len(errs) > 0:
+		return nil, errors.Join(errs...)
+	default:
+		return nil, errdefs.New(errdefs.ErrUnsupportedPurlType, "no probers read sboms of purl type %s", p.Type)
+	}
+}
@@ -0,0 +1,283 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/openvex/go-vex/pkg/vex"
+	"github.com/package-url/packageurl-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/pkg/discovery"
+	"github.com/openvex/discovery/pkg/discovery/discoveryfakes"
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/errdefs"
+	"github.com/openvex/discovery/pkg/sbom"
+)
+
+func newFakeProbe(docs []*vex.VEX, err error) *discoveryfakes.FakeVexProbe {
+	probe := &discoveryfakes.FakeVexProbe{}
+	probe.FindDocumentsFromPurlReturns(docs, err)
+	return probe
+}
+
+func TestChains(t *testing.T) {
+	t.Cleanup(func() {
+		discovery.UnregisterDrivers()
+		discovery.RegisterBuiltInDrivers()
+	})
+	syntErr := fmt.Errorf("synthetic error")
+	purlString := "pkg:generic/test@1.0.0"
+
+	for _, tc := range []struct {
+		name     string
+		policy   discovery.ChainPolicy
+		probes   []*discoveryfakes.FakeVexProbe
+		disable  []string
+		numDocs  int
+		numCalls []int
+		mustErr  bool
+	}{
+		{
+			name:     "first hit",
+			policy:   discovery.FirstHit,
+			probes:   []*discoveryfakes.FakeVexProbe{newFakeProbe([]*vex.VEX{{}}, nil), newFakeProbe([]*vex.VEX{{}}, nil)},
+			numDocs:  1,
+			numCalls: []int{1, 0},
+		},
+		{
+			name:     "first hit falls back on error",
+			policy:   discovery.FirstHit,
+			probes:   []*discoveryfakes.FakeVexProbe{newFakeProbe(nil, syntErr), newFakeProbe([]*vex.VEX{{}}, nil)},
+			numDocs:  1,
+			numCalls: []int{1, 1},
+		},
+		{
+			name:     "first hit falls back when nothing found",
+			policy:   discovery.FirstHit,
+			probes:   []*discoveryfakes.FakeVexProbe{newFakeProbe([]*vex.VEX{}, nil), newFakeProbe([]*vex.VEX{{}, {}}, nil)},
+			numDocs:  2,
+			numCalls: []int{1, 1},
+		},
+		{
+			name:     "first hit reports errors when nothing found",
+			policy:   discovery.FirstHit,
+			probes:   []*discoveryfakes.FakeVexProbe{newFakeProbe([]*vex.VEX{}, nil), newFakeProbe(nil, syntErr)},
+			numCalls: []int{1, 1},
+			mustErr:  true,
+		},
+		{
+			name:     "query all",
+			policy:   discovery.QueryAll,
+			probes:   []*discoveryfakes.FakeVexProbe{newFakeProbe([]*vex.VEX{{}}, nil), newFakeProbe(nil, syntErr), newFakeProbe([]*vex.VEX{{}}, nil)},
+			numDocs:  2,
+			numCalls: []int{1, 1, 1},
+		},
+		{
+			name:     "all fail",
+			policy:   discovery.QueryAll,
+			probes:   []*discoveryfakes.FakeVexProbe{newFakeProbe(nil, syntErr), newFakeProbe(nil, syntErr)},
+			numCalls: []int{1, 1},
+			mustErr:  true,
+		},
+		{
+			name:     "disabled prober",
+			policy:   discovery.FirstHit,
+			probes:   []*discoveryfakes.FakeVexProbe{newFakeProbe([]*vex.VEX{{}}, nil), newFakeProbe([]*vex.VEX{{}, {}}, nil)},
+			disable:  []string{"probe-0"},
+			numDocs:  2,
+			numCalls: []int{0, 1},
+		},
+		{
+			name:     "all disabled",
+			policy:   discovery.FirstHit,
+			probes:   []*discoveryfakes.FakeVexProbe{newFakeProbe([]*vex.VEX{{}}, nil)},
+			disable:  []string{"probe-0"},
+			numCalls: []int{0},
+			mustErr:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			discovery.UnregisterDrivers()
+			for i, p := range tc.probes {
+				discovery.RegisterNamedDriver("generic", fmt.Sprintf("probe-%d", i), p)
+			}
+			require.NoError(t, discovery.SetChainPolicy("generic", tc.policy))
+			for _, name := range tc.disable {
+				require.NoError(t, discovery.DisableDriver("generic", name))
+			}
+			require.Len(t, discovery.Drivers("generic"), len(tc.probes))
+
+			docs, err := discovery.NewAgent().ProbePurl(purlString)
+			for i, p := range tc.probes {
+				require.Equal(t, tc.numCalls[i], p.FindDocumentsFromPurlCallCount(), "calls to probe-%d", i)
+			}
+			if tc.mustErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, docs, tc.numDocs)
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	t.Cleanup(func() {
+		discovery.UnregisterDrivers()
+		discovery.RegisterBuiltInDrivers()
+	})
+	discovery.UnregisterDrivers()
+
+	// Registering a probe with the same name replaces it
+	discovery.RegisterNamedDriver("generic", "a", &discoveryfakes.FakeVexProbe{})
+	discovery.RegisterNamedDriver("generic", "b", &discoveryfakes.FakeVexProbe{})
+	discovery.RegisterNamedDriver("generic", "a", &discoveryfakes.FakeVexProbe{})
+	require.Equal(t, []discovery.ProberInfo{{"a", true}, {"b", true}}, discovery.Drivers("generic"))
+
+	require.NoError(t, discovery.DisableDriver("generic", "b"))
+	require.Equal(t, []discovery.ProberInfo{{"a", true}, {"b", false}}, discovery.Drivers("generic"))
+	require.NoError(t, discovery.EnableDriver("generic", "b"))
+	require.Equal(t, []discovery.ProberInfo{{"a", true}, {"b", true}}, discovery.Drivers("generic"))
+
+	discovery.RegisterNamedDriver("generic", "c", &discoveryfakes.FakeVexProbe{})
+	require.NoError(t, discovery.SetDriverOrder("generic", []string{"c", "b"}))
+	require.Equal(t, []discovery.ProberInfo{{"c", true}, {"b", true}, {"a", true}}, discovery.Drivers("generic"))
+	require.Error(t, discovery.SetDriverOrder("generic", []string{"b", "b"}))
+	require.Error(t, discovery.SetDriverOrder("generic", []string{"d"}))
+	require.Error(t, discovery.SetDriverOrder("npm", []string{"a"}))
+
+	require.Error(t, discovery.DisableDriver("generic", "d"))
+	require.Error(t, discovery.DisableDriver("npm", "a"))
+	require.Error(t, discovery.SetChainPolicy("generic", "random"))
+	require.Empty(t, discovery.Drivers("npm"))
+}
+
+func TestAgentRegistry(t *testing.T) {
+	t.Cleanup(func() {
+		discovery.UnregisterDrivers()
+		discovery.RegisterBuiltInDrivers()
+	})
+	purlString := "pkg:generic/test@1.0.0"
+
+	// Agents get a copy of the default registry when created
+	discovery.UnregisterDrivers()
+	discovery.RegisterNamedDriver("generic", "shared", newFakeProbe([]*vex.VEX{{}}, nil))
+	agentA := discovery.NewAgent()
+	agentB := discovery.NewAgent()
+
+	// Changes to an agent's registry don't affect others
+	agentA.Registry.RegisterNamedDriver("generic", "shared", newFakeProbe([]*vex.VEX{{}, {}}, nil))
+	discovery.UnregisterDrivers()
+
+	docs, err := agentA.ProbePurl(purlString)
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+
+	docs, err = agentB.ProbePurl(purlString)
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+
+	_, err = discovery.NewAgent().ProbePurl(purlString)
+	require.Error(t, err)
+}
+
+func TestDriverFactory(t *testing.T) {
+	reg := discovery.NewRegistry()
+	created := []*discoveryfakes.FakeVexProbe{}
+	reg.RegisterDriverFactory("generic", "factory", func() discovery.VexProbe {
+		p := newFakeProbe([]*vex.VEX{{}}, nil)
+		created = append(created, p)
+		return p
+	})
+
+	agent := discovery.NewAgent()
+	agent.Registry = reg
+	for i := 0; i < 3; i++ {
+		_, err := agent.ProbePurl("pkg:generic/test@1.0.0")
+		require.NoError(t, err)
+	}
+
+	// Each probe gets a new instance with the agent options set
+	require.Len(t, created, 3)
+	for _, p := range created {
+		require.Equal(t, 1, p.SetOptionsCallCount())
+		require.Equal(t, 1, p.FindDocumentsFromPurlCallCount())
+	}
+
+	// Disabled factories are not instantiated
+	require.NoError(t, reg.DisableDriver("generic", "factory"))
+	_, err := agent.ProbePurl("pkg:generic/test@1.0.0")
+	require.Error(t, err)
+	require.Len(t, created, 3)
+}
+
+// fakeSBOMProbe is a fake probe that also reads SBOMs
+type fakeSBOMProbe struct {
+	*discoveryfakes.FakeVexProbe
+	contents *sbom.Contents
+	err      error
+}
+
+func (p *fakeSBOMProbe) FindSBOM(options.Options, packageurl.PackageURL) (*sbom.Contents, error) {
+	return p.contents, p.err
+}
+
+func TestChainFindSBOM(t *testing.T) {
+	p, err := packageurl.FromString("pkg:oci/test")
+	require.NoError(t, err)
+	components := &sbom.Contents{Purls: []string{"pkg:apk/wolfi/busybox@1.36.1-r0"}}
+
+	for _, tc := range []struct {
+		name     string
+		probes   []discovery.VexProbe
+		expected *sbom.Contents
+		kind     error
+	}{
+		{
+			name: "first sbom with components",
+			probes: []discovery.VexProbe{
+				&discoveryfakes.FakeVexProbe{},
+				&fakeSBOMProbe{err: errdefs.New(errdefs.ErrNotFound, "no sbom")},
+				&fakeSBOMProbe{contents: &sbom.Contents{}},
+				&fakeSBOMProbe{contents: components},
+			},
+			expected: components,
+		},
+		{
+			name:     "empty sbom",
+			probes:   []discovery.VexProbe{&fakeSBOMProbe{contents: &sbom.Contents{}}},
+			expected: &sbom.Contents{},
+		},
+		{
+			name:   "all fail",
+			probes: []discovery.VexProbe{&fakeSBOMProbe{err: errdefs.New(errdefs.ErrNotFound, "no sbom")}},
+			kind:   errdefs.ErrNotFound,
+		},
+		{
+			name:   "no sbom probers",
+			probes: []discovery.VexProbe{&discoveryfakes.FakeVexProbe{}},
+			kind:   errdefs.ErrUnsupportedPurlType,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := discovery.NewRegistry()
+			for i, probe := range tc.probes {
+				reg.RegisterNamedDriver("oci", fmt.Sprintf("probe-%d", i), probe)
+			}
+			chain, err := reg.Chain("oci")
+			require.NoError(t, err)
+
+			contents, err := chain.FindSBOM(options.New(), p)
+			if tc.kind != nil {
+				require.ErrorIs(t, err, tc.kind)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, contents)
+		})
+	}
+}
@@ -2,16 +2,50 @@
 package discoveryfakes
 
 import (
+	"net/http"
 	"sync"
 
 	"github.com/openvex/discovery/pkg/discovery"
 	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/sbom"
+	"github.com/openvex/discovery/pkg/trust"
 	"github.com/openvex/go-vex/pkg/vex"
 	packageurl "github.com/package-url/packageurl-go"
 )
 
 type FakeAgentImplementation struct {
-	FindDocumentsFromPurlStub        func(options.Options, discovery.VexProbe, packageurl.PackageURL) ([]*vex.VEX, error)
+	ApplyTrustPolicyStub        func(*trust.Policy, packageurl.PackageURL, []*results.Document) ([]*results.Document, error)
+	applyTrustPolicyMutex       sync.RWMutex
+	applyTrustPolicyArgsForCall []struct {
+		arg1 *trust.Policy
+		arg2 packageurl.PackageURL
+		arg3 []*results.Document
+	}
+	applyTrustPolicyReturns struct {
+		result1 []*results.Document
+		result2 error
+	}
+	applyTrustPolicyReturnsOnCall map[int]struct {
+		result1 []*results.Document
+		result2 error
+	}
+	FetchDocumentStub        func(options.Options, *http.Client, string) (*results.Document, error)
+	fetchDocumentMutex       sync.RWMutex
+	fetchDocumentArgsForCall []struct {
+		arg1 options.Options
+		arg2 *http.Client
+		arg3 string
+	}
+	fetchDocumentReturns struct {
+		result1 *results.Document
+		result2 error
+	}
+	fetchDocumentReturnsOnCall map[int]struct {
+		result1 *results.Document
+		result2 error
+	}
+	FindDocumentsFromPurlStub        func(options.Options, discovery.VexProbe, packageurl.PackageURL) ([]*results.Document, error)
 	findDocumentsFromPurlMutex       sync.RWMutex
 	findDocumentsFromPurlArgsForCall []struct {
 		arg1 options.Options
@@ -19,18 +53,49 @@ type FakeAgentImplementation struct {
 		arg3 packageurl.PackageURL
 	}
 	findDocumentsFromPurlReturns struct {
-		result1 []*vex.VEX
+		result1 []*results.Document
 		result2 error
 	}
 	findDocumentsFromPurlReturnsOnCall map[int]struct {
-		result1 []*vex.VEX
+		result1 []*results.Document
+		result2 error
+	}
+	FindEffectiveStatusStub        func([]*vex.VEX, string, string) (*discovery.VulnerabilityStatus, error)
+	findEffectiveStatusMutex       sync.RWMutex
+	findEffectiveStatusArgsForCall []struct {
+		arg1 []*vex.VEX
+		arg2 string
+		arg3 string
+	}
+	findEffectiveStatusReturns struct {
+		result1 *discovery.VulnerabilityStatus
+		result2 error
+	}
+	findEffectiveStatusReturnsOnCall map[int]struct {
+		result1 *discovery.VulnerabilityStatus
+		result2 error
+	}
+	FindSBOMStub        func(options.Options, discovery.VexProbe, packageurl.PackageURL) (*sbom.Contents, error)
+	findSBOMMutex       sync.RWMutex
+	findSBOMArgsForCall []struct {
+		arg1 options.Options
+		arg2 discovery.VexProbe
+		arg3 packageurl.PackageURL
+	}
+	findSBOMReturns struct {
+		result1 *sbom.Contents
+		result2 error
+	}
+	findSBOMReturnsOnCall map[int]struct {
+		result1 *sbom.Contents
 		result2 error
 	}
-	GetPackageProbeStub        func(options.Options, packageurl.PackageURL) (discovery.VexProbe, error)
+	GetPackageProbeStub        func(*discovery.Registry, options.Options, packageurl.PackageURL) (discovery.VexProbe, error)
 	getPackageProbeMutex       sync.RWMutex
 	getPackageProbeArgsForCall []struct {
-		arg1 options.Options
-		arg2 packageurl.PackageURL
+		arg1 *discovery.Registry
+		arg2 options.Options
+		arg3 packageurl.PackageURL
 	}
 	getPackageProbeReturns struct {
 		result1 discovery.VexProbe
@@ -53,11 +118,162 @@ type FakeAgentImplementation struct {
 		result1 packageurl.PackageURL
 		result2 error
 	}
+	ReferenceToPurlStub        func(options.Options, string) (packageurl.PackageURL, error)
+	referenceToPurlMutex       sync.RWMutex
+	referenceToPurlArgsForCall []struct {
+		arg1 options.Options
+		arg2 string
+	}
+	referenceToPurlReturns struct {
+		result1 packageurl.PackageURL
+		result2 error
+	}
+	referenceToPurlReturnsOnCall map[int]struct {
+		result1 packageurl.PackageURL
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeAgentImplementation) FindDocumentsFromPurl(arg1 options.Options, arg2 discovery.VexProbe, arg3 packageurl.PackageURL) ([]*vex.VEX, error) {
+func (fake *FakeAgentImplementation) ApplyTrustPolicy(arg1 *trust.Policy, arg2 packageurl.PackageURL, arg3 []*results.Document) ([]*results.Document, error) {
+	var arg3Copy []*results.Document
+	if arg3 != nil {
+		arg3Copy = make([]*results.Document, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	fake.applyTrustPolicyMutex.Lock()
+	ret, specificReturn := fake.applyTrustPolicyReturnsOnCall[len(fake.applyTrustPolicyArgsForCall)]
+	fake.applyTrustPolicyArgsForCall = append(fake.applyTrustPolicyArgsForCall, struct {
+		arg1 *trust.Policy
+		arg2 packageurl.PackageURL
+		arg3 []*results.Document
+	}{arg1, arg2, arg3Copy})
+	stub := fake.ApplyTrustPolicyStub
+	fakeReturns := fake.applyTrustPolicyReturns
+	fake.recordInvocation("ApplyTrustPolicy", []interface{}{arg1, arg2, arg3Copy})
+	fake.applyTrustPolicyMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeAgentImplementation) ApplyTrustPolicyCallCount() int {
+	fake.applyTrustPolicyMutex.RLock()
+	defer fake.applyTrustPolicyMutex.RUnlock()
+	return len(fake.applyTrustPolicyArgsForCall)
+}
+
+func (fake *FakeAgentImplementation) ApplyTrustPolicyCalls(stub func(*trust.Policy, packageurl.PackageURL, []*results.Document) ([]*results.Document, error)) {
+	fake.applyTrustPolicyMutex.Lock()
+	defer fake.applyTrustPolicyMutex.Unlock()
+	fake.ApplyTrustPolicyStub = stub
+}
+
+func (fake *FakeAgentImplementation) ApplyTrustPolicyArgsForCall(i int) (*trust.Policy, packageurl.PackageURL, []*results.Document) {
+	fake.applyTrustPolicyMutex.RLock()
+	defer fake.applyTrustPolicyMutex.RUnlock()
+	argsForCall := fake.applyTrustPolicyArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeAgentImplementation) ApplyTrustPolicyReturns(result1 []*results.Document, result2 error) {
+	fake.applyTrustPolicyMutex.Lock()
+	defer fake.applyTrustPolicyMutex.Unlock()
+	fake.ApplyTrustPolicyStub = nil
+	fake.applyTrustPolicyReturns = struct {
+		result1 []*results.Document
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAgentImplementation) ApplyTrustPolicyReturnsOnCall(i int, result1 []*results.Document, result2 error) {
+	fake.applyTrustPolicyMutex.Lock()
+	defer fake.applyTrustPolicyMutex.Unlock()
+	fake.ApplyTrustPolicyStub = nil
+	if fake.applyTrustPolicyReturnsOnCall == nil {
+		fake.applyTrustPolicyReturnsOnCall = make(map[int]struct {
+			result1 []*results.Document
+			result2 error
+		})
+	}
+	fake.applyTrustPolicyReturnsOnCall[i] = struct {
+		result1 []*results.Document
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAgentImplementation) FetchDocument(arg1 options.Options, arg2 *http.Client, arg3 string) (*results.Document, error) {
+	fake.fetchDocumentMutex.Lock()
+	ret, specificReturn := fake.fetchDocumentReturnsOnCall[len(fake.fetchDocumentArgsForCall)]
+	fake.fetchDocumentArgsForCall = append(fake.fetchDocumentArgsForCall, struct {
+		arg1 options.Options
+		arg2 *http.Client
+		arg3 string
+	}{arg1, arg2, arg3})
+	stub := fake.FetchDocumentStub
+	fakeReturns := fake.fetchDocumentReturns
+	fake.recordInvocation("FetchDocument", []interface{}{arg1, arg2, arg3})
+	fake.fetchDocumentMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeAgentImplementation) FetchDocumentCallCount() int {
+	fake.fetchDocumentMutex.RLock()
+	defer fake.fetchDocumentMutex.RUnlock()
+	return len(fake.fetchDocumentArgsForCall)
+}
+
+func (fake *FakeAgentImplementation) FetchDocumentCalls(stub func(options.Options, *http.Client, string) (*results.Document, error)) {
+	fake.fetchDocumentMutex.Lock()
+	defer fake.fetchDocumentMutex.Unlock()
+	fake.FetchDocumentStub = stub
+}
+
+func (fake *FakeAgentImplementation) FetchDocumentArgsForCall(i int) (options.Options, *http.Client, string) {
+	fake.fetchDocumentMutex.RLock()
+	defer fake.fetchDocumentMutex.RUnlock()
+	argsForCall := fake.fetchDocumentArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeAgentImplementation) FetchDocumentReturns(result1 *results.Document, result2 error) {
+	fake.fetchDocumentMutex.Lock()
+	defer fake.fetchDocumentMutex.Unlock()
+	fake.FetchDocumentStub = nil
+	fake.fetchDocumentReturns = struct {
+		result1 *results.Document
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAgentImplementation) FetchDocumentReturnsOnCall(i int, result1 *results.Document, result2 error) {
+	fake.fetchDocumentMutex.Lock()
+	defer fake.fetchDocumentMutex.Unlock()
+	fake.FetchDocumentStub = nil
+	if fake.fetchDocumentReturnsOnCall == nil {
+		fake.fetchDocumentReturnsOnCall = make(map[int]struct {
+			result1 *results.Document
+			result2 error
+		})
+	}
+	fake.fetchDocumentReturnsOnCall[i] = struct {
+		result1 *results.Document
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAgentImplementation) FindDocumentsFromPurl(arg1 options.Options, arg2 discovery.VexProbe, arg3 packageurl.PackageURL) ([]*results.Document, error) {
 	fake.findDocumentsFromPurlMutex.Lock()
 	ret, specificReturn := fake.findDocumentsFromPurlReturnsOnCall[len(fake.findDocumentsFromPurlArgsForCall)]
 	fake.findDocumentsFromPurlArgsForCall = append(fake.findDocumentsFromPurlArgsForCall, struct {
@@ -84,7 +300,7 @@ func (fake *FakeAgentImplementation) FindDocumentsFromPurlCallCount() int {
 	return len(fake.findDocumentsFromPurlArgsForCall)
 }
 
-func (fake *FakeAgentImplementation) FindDocumentsFromPurlCalls(stub func(options.Options, discovery.VexProbe, packageurl.PackageURL) ([]*vex.VEX, error)) {
+func (fake *FakeAgentImplementation) FindDocumentsFromPurlCalls(stub func(options.Options, discovery.VexProbe, packageurl.PackageURL) ([]*results.Document, error)) {
 	fake.findDocumentsFromPurlMutex.Lock()
 	defer fake.findDocumentsFromPurlMutex.Unlock()
 	fake.FindDocumentsFromPurlStub = stub
@@ -97,45 +313,183 @@ func (fake *FakeAgentImplementation) FindDocumentsFromPurlArgsForCall(i int) (op
 	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
-func (fake *FakeAgentImplementation) FindDocumentsFromPurlReturns(result1 []*vex.VEX, result2 error) {
+func (fake *FakeAgentImplementation) FindDocumentsFromPurlReturns(result1 []*results.Document, result2 error) {
 	fake.findDocumentsFromPurlMutex.Lock()
 	defer fake.findDocumentsFromPurlMutex.Unlock()
 	fake.FindDocumentsFromPurlStub = nil
 	fake.findDocumentsFromPurlReturns = struct {
-		result1 []*vex.VEX
+		result1 []*results.Document
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeAgentImplementation) FindDocumentsFromPurlReturnsOnCall(i int, result1 []*vex.VEX, result2 error) {
+func (fake *FakeAgentImplementation) FindDocumentsFromPurlReturnsOnCall(i int, result1 []*results.Document, result2 error) {
 	fake.findDocumentsFromPurlMutex.Lock()
 	defer fake.findDocumentsFromPurlMutex.Unlock()
 	fake.FindDocumentsFromPurlStub = nil
 	if fake.findDocumentsFromPurlReturnsOnCall == nil {
 		fake.findDocumentsFromPurlReturnsOnCall = make(map[int]struct {
-			result1 []*vex.VEX
+			result1 []*results.Document
 			result2 error
 		})
 	}
 	fake.findDocumentsFromPurlReturnsOnCall[i] = struct {
-		result1 []*vex.VEX
+		result1 []*results.Document
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAgentImplementation) FindEffectiveStatus(arg1 []*vex.VEX, arg2 string, arg3 string) (*discovery.VulnerabilityStatus, error) {
+	var arg1Copy []*vex.VEX
+	if arg1 != nil {
+		arg1Copy = make([]*vex.VEX, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	fake.findEffectiveStatusMutex.Lock()
+	ret, specificReturn := fake.findEffectiveStatusReturnsOnCall[len(fake.findEffectiveStatusArgsForCall)]
+	fake.findEffectiveStatusArgsForCall = append(fake.findEffectiveStatusArgsForCall, struct {
+		arg1 []*vex.VEX
+		arg2 string
+		arg3 string
+	}{arg1Copy, arg2, arg3})
+	stub := fake.FindEffectiveStatusStub
+	fakeReturns := fake.findEffectiveStatusReturns
+	fake.recordInvocation("FindEffectiveStatus", []interface{}{arg1Copy, arg2, arg3})
+	fake.findEffectiveStatusMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeAgentImplementation) FindEffectiveStatusCallCount() int {
+	fake.findEffectiveStatusMutex.RLock()
+	defer fake.findEffectiveStatusMutex.RUnlock()
+	return len(fake.findEffectiveStatusArgsForCall)
+}
+
+func (fake *FakeAgentImplementation) FindEffectiveStatusCalls(stub func([]*vex.VEX, string, string) (*discovery.VulnerabilityStatus, error)) {
+	fake.findEffectiveStatusMutex.Lock()
+	defer fake.findEffectiveStatusMutex.Unlock()
+	fake.FindEffectiveStatusStub = stub
+}
+
+func (fake *FakeAgentImplementation) FindEffectiveStatusArgsForCall(i int) ([]*vex.VEX, string, string) {
+	fake.findEffectiveStatusMutex.RLock()
+	defer fake.findEffectiveStatusMutex.RUnlock()
+	argsForCall := fake.findEffectiveStatusArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeAgentImplementation) FindEffectiveStatusReturns(result1 *discovery.VulnerabilityStatus, result2 error) {
+	fake.findEffectiveStatusMutex.Lock()
+	defer fake.findEffectiveStatusMutex.Unlock()
+	fake.FindEffectiveStatusStub = nil
+	fake.findEffectiveStatusReturns = struct {
+		result1 *discovery.VulnerabilityStatus
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAgentImplementation) FindEffectiveStatusReturnsOnCall(i int, result1 *discovery.VulnerabilityStatus, result2 error) {
+	fake.findEffectiveStatusMutex.Lock()
+	defer fake.findEffectiveStatusMutex.Unlock()
+	fake.FindEffectiveStatusStub = nil
+	if fake.findEffectiveStatusReturnsOnCall == nil {
+		fake.findEffectiveStatusReturnsOnCall = make(map[int]struct {
+			result1 *discovery.VulnerabilityStatus
+			result2 error
+		})
+	}
+	fake.findEffectiveStatusReturnsOnCall[i] = struct {
+		result1 *discovery.VulnerabilityStatus
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAgentImplementation) FindSBOM(arg1 options.Options, arg2 discovery.VexProbe, arg3 packageurl.PackageURL) (*sbom.Contents, error) {
+	fake.findSBOMMutex.Lock()
+	ret, specificReturn := fake.findSBOMReturnsOnCall[len(fake.findSBOMArgsForCall)]
+	fake.findSBOMArgsForCall = append(fake.findSBOMArgsForCall, struct {
+		arg1 options.Options
+		arg2 discovery.VexProbe
+		arg3 packageurl.PackageURL
+	}{arg1, arg2, arg3})
+	stub := fake.FindSBOMStub
+	fakeReturns := fake.findSBOMReturns
+	fake.recordInvocation("FindSBOM", []interface{}{arg1, arg2, arg3})
+	fake.findSBOMMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeAgentImplementation) FindSBOMCallCount() int {
+	fake.findSBOMMutex.RLock()
+	defer fake.findSBOMMutex.RUnlock()
+	return len(fake.findSBOMArgsForCall)
+}
+
+func (fake *FakeAgentImplementation) FindSBOMCalls(stub func(options.Options, discovery.VexProbe, packageurl.PackageURL) (*sbom.Contents, error)) {
+	fake.findSBOMMutex.Lock()
+	defer fake.findSBOMMutex.Unlock()
+	fake.FindSBOMStub = stub
+}
+
+func (fake *FakeAgentImplementation) FindSBOMArgsForCall(i int) (options.Options, discovery.VexProbe, packageurl.PackageURL) {
+	fake.findSBOMMutex.RLock()
+	defer fake.findSBOMMutex.RUnlock()
+	argsForCall := fake.findSBOMArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeAgentImplementation) FindSBOMReturns(result1 *sbom.Contents, result2 error) {
+	fake.findSBOMMutex.Lock()
+	defer fake.findSBOMMutex.Unlock()
+	fake.FindSBOMStub = nil
+	fake.findSBOMReturns = struct {
+		result1 *sbom.Contents
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeAgentImplementation) GetPackageProbe(arg1 options.Options, arg2 packageurl.PackageURL) (discovery.VexProbe, error) {
+func (fake *FakeAgentImplementation) FindSBOMReturnsOnCall(i int, result1 *sbom.Contents, result2 error) {
+	fake.findSBOMMutex.Lock()
+	defer fake.findSBOMMutex.Unlock()
+	fake.FindSBOMStub = nil
+	if fake.findSBOMReturnsOnCall == nil {
+		fake.findSBOMReturnsOnCall = make(map[int]struct {
+			result1 *sbom.Contents
+			result2 error
+		})
+	}
+	fake.findSBOMReturnsOnCall[i] = struct {
+		result1 *sbom.Contents
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAgentImplementation) GetPackageProbe(arg1 *discovery.Registry, arg2 options.Options, arg3 packageurl.PackageURL) (discovery.VexProbe, error) {
 	fake.getPackageProbeMutex.Lock()
 	ret, specificReturn := fake.getPackageProbeReturnsOnCall[len(fake.getPackageProbeArgsForCall)]
 	fake.getPackageProbeArgsForCall = append(fake.getPackageProbeArgsForCall, struct {
-		arg1 options.Options
-		arg2 packageurl.PackageURL
-	}{arg1, arg2})
+		arg1 *discovery.Registry
+		arg2 options.Options
+		arg3 packageurl.PackageURL
+	}{arg1, arg2, arg3})
 	stub := fake.GetPackageProbeStub
 	fakeReturns := fake.getPackageProbeReturns
-	fake.recordInvocation("GetPackageProbe", []interface{}{arg1, arg2})
+	fake.recordInvocation("GetPackageProbe", []interface{}{arg1, arg2, arg3})
 	fake.getPackageProbeMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -149,17 +503,17 @@ func (fake *FakeAgentImplementation) GetPackageProbeCallCount() int {
 	return len(fake.getPackageProbeArgsForCall)
 }
 
-func (fake *FakeAgentImplementation) GetPackageProbeCalls(stub func(options.Options, packageurl.PackageURL) (discovery.VexProbe, error)) {
+func (fake *FakeAgentImplementation) GetPackageProbeCalls(stub func(*discovery.Registry, options.Options, packageurl.PackageURL) (discovery.VexProbe, error)) {
 	fake.getPackageProbeMutex.Lock()
 	defer fake.getPackageProbeMutex.Unlock()
 	fake.GetPackageProbeStub = stub
 }
 
-func (fake *FakeAgentImplementation) GetPackageProbeArgsForCall(i int) (options.Options, packageurl.PackageURL) {
+func (fake *FakeAgentImplementation) GetPackageProbeArgsForCall(i int) (*discovery.Registry, options.Options, packageurl.PackageURL) {
 	fake.getPackageProbeMutex.RLock()
 	defer fake.getPackageProbeMutex.RUnlock()
 	argsForCall := fake.getPackageProbeArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *FakeAgentImplementation) GetPackageProbeReturns(result1 discovery.VexProbe, result2 error) {
@@ -252,15 +606,90 @@ func (fake *FakeAgentImplementation) ParsePurlReturnsOnCall(i int, result1 packa
 	}{result1, result2}
 }
 
+func (fake *FakeAgentImplementation) ReferenceToPurl(arg1 options.Options, arg2 string) (packageurl.PackageURL, error) {
+	fake.referenceToPurlMutex.Lock()
+	ret, specificReturn := fake.referenceToPurlReturnsOnCall[len(fake.referenceToPurlArgsForCall)]
+	fake.referenceToPurlArgsForCall = append(fake.referenceToPurlArgsForCall, struct {
+		arg1 options.Options
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.ReferenceToPurlStub
+	fakeReturns := fake.referenceToPurlReturns
+	fake.recordInvocation("ReferenceToPurl", []interface{}{arg1, arg2})
+	fake.referenceToPurlMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeAgentImplementation) ReferenceToPurlCallCount() int {
+	fake.referenceToPurlMutex.RLock()
+	defer fake.referenceToPurlMutex.RUnlock()
+	return len(fake.referenceToPurlArgsForCall)
+}
+
+func (fake *FakeAgentImplementation) ReferenceToPurlCalls(stub func(options.Options, string) (packageurl.PackageURL, error)) {
+	fake.referenceToPurlMutex.Lock()
+	defer fake.referenceToPurlMutex.Unlock()
+	fake.ReferenceToPurlStub = stub
+}
+
+func (fake *FakeAgentImplementation) ReferenceToPurlArgsForCall(i int) (options.Options, string) {
+	fake.referenceToPurlMutex.RLock()
+	defer fake.referenceToPurlMutex.RUnlock()
+	argsForCall := fake.referenceToPurlArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeAgentImplementation) ReferenceToPurlReturns(result1 packageurl.PackageURL, result2 error) {
+	fake.referenceToPurlMutex.Lock()
+	defer fake.referenceToPurlMutex.Unlock()
+	fake.ReferenceToPurlStub = nil
+	fake.referenceToPurlReturns = struct {
+		result1 packageurl.PackageURL
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAgentImplementation) ReferenceToPurlReturnsOnCall(i int, result1 packageurl.PackageURL, result2 error) {
+	fake.referenceToPurlMutex.Lock()
+	defer fake.referenceToPurlMutex.Unlock()
+	fake.ReferenceToPurlStub = nil
+	if fake.referenceToPurlReturnsOnCall == nil {
+		fake.referenceToPurlReturnsOnCall = make(map[int]struct {
+			result1 packageurl.PackageURL
+			result2 error
+		})
+	}
+	fake.referenceToPurlReturnsOnCall[i] = struct {
+		result1 packageurl.PackageURL
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeAgentImplementation) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.applyTrustPolicyMutex.RLock()
+	defer fake.applyTrustPolicyMutex.RUnlock()
+	fake.fetchDocumentMutex.RLock()
+	defer fake.fetchDocumentMutex.RUnlock()
 	fake.findDocumentsFromPurlMutex.RLock()
 	defer fake.findDocumentsFromPurlMutex.RUnlock()
+	fake.findEffectiveStatusMutex.RLock()
+	defer fake.findEffectiveStatusMutex.RUnlock()
+	fake.findSBOMMutex.RLock()
+	defer fake.findSBOMMutex.RUnlock()
 	fake.getPackageProbeMutex.RLock()
 	defer fake.getPackageProbeMutex.RUnlock()
 	fake.parsePurlMutex.RLock()
 	defer fake.parsePurlMutex.RUnlock()
+	fake.referenceToPurlMutex.RLock()
+	defer fake.referenceToPurlMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
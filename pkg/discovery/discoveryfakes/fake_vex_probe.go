@@ -0,0 +1,160 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package discoveryfakes
+
+import (
+	"sync"
+
+	"github.com/openvex/discovery/pkg/discovery"
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/go-vex/pkg/vex"
+	packageurl "github.com/package-url/packageurl-go"
+)
+
+type FakeVexProbe struct {
+	FindDocumentsFromPurlStub        func(options.Options, packageurl.PackageURL) ([]*vex.VEX, error)
+	findDocumentsFromPurlMutex       sync.RWMutex
+	findDocumentsFromPurlArgsForCall []struct {
+		arg1 options.Options
+		arg2 packageurl.PackageURL
+	}
+	findDocumentsFromPurlReturns struct {
+		result1 []*vex.VEX
+		result2 error
+	}
+	findDocumentsFromPurlReturnsOnCall map[int]struct {
+		result1 []*vex.VEX
+		result2 error
+	}
+	SetOptionsStub        func(options.Options)
+	setOptionsMutex       sync.RWMutex
+	setOptionsArgsForCall []struct {
+		arg1 options.Options
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeVexProbe) FindDocumentsFromPurl(arg1 options.Options, arg2 packageurl.PackageURL) ([]*vex.VEX, error) {
+	fake.findDocumentsFromPurlMutex.Lock()
+	ret, specificReturn := fake.findDocumentsFromPurlReturnsOnCall[len(fake.findDocumentsFromPurlArgsForCall)]
+	fake.findDocumentsFromPurlArgsForCall = append(fake.findDocumentsFromPurlArgsForCall, struct {
+		arg1 options.Options
+		arg2 packageurl.PackageURL
+	}{arg1, arg2})
+	stub := fake.FindDocumentsFromPurlStub
+	fakeReturns := fake.findDocumentsFromPurlReturns
+	fake.recordInvocation("FindDocumentsFromPurl", []interface{}{arg1, arg2})
+	fake.findDocumentsFromPurlMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeVexProbe) FindDocumentsFromPurlCallCount() int {
+	fake.findDocumentsFromPurlMutex.RLock()
+	defer fake.findDocumentsFromPurlMutex.RUnlock()
+	return len(fake.findDocumentsFromPurlArgsForCall)
+}
+
+func (fake *FakeVexProbe) FindDocumentsFromPurlCalls(stub func(options.Options, packageurl.PackageURL) ([]*vex.VEX, error)) {
+	fake.findDocumentsFromPurlMutex.Lock()
+	defer fake.findDocumentsFromPurlMutex.Unlock()
+	fake.FindDocumentsFromPurlStub = stub
+}
+
+func (fake *FakeVexProbe) FindDocumentsFromPurlArgsForCall(i int) (options.Options, packageurl.PackageURL) {
+	fake.findDocumentsFromPurlMutex.RLock()
+	defer fake.findDocumentsFromPurlMutex.RUnlock()
+	argsForCall := fake.findDocumentsFromPurlArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeVexProbe) FindDocumentsFromPurlReturns(result1 []*vex.VEX, result2 error) {
+	fake.findDocumentsFromPurlMutex.Lock()
+	defer fake.findDocumentsFromPurlMutex.Unlock()
+	fake.FindDocumentsFromPurlStub = nil
+	fake.findDocumentsFromPurlReturns = struct {
+		result1 []*vex.VEX
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeVexProbe) FindDocumentsFromPurlReturnsOnCall(i int, result1 []*vex.VEX, result2 error) {
+	fake.findDocumentsFromPurlMutex.Lock()
+	defer fake.findDocumentsFromPurlMutex.Unlock()
+	fake.FindDocumentsFromPurlStub = nil
+	if fake.findDocumentsFromPurlReturnsOnCall == nil {
+		fake.findDocumentsFromPurlReturnsOnCall = make(map[int]struct {
+			result1 []*vex.VEX
+			result2 error
+		})
+	}
+	fake.findDocumentsFromPurlReturnsOnCall[i] = struct {
+		result1 []*vex.VEX
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeVexProbe) SetOptions(arg1 options.Options) {
+	fake.setOptionsMutex.Lock()
+	fake.setOptionsArgsForCall = append(fake.setOptionsArgsForCall, struct {
+		arg1 options.Options
+	}{arg1})
+	stub := fake.SetOptionsStub
+	fake.recordInvocation("SetOptions", []interface{}{arg1})
+	fake.setOptionsMutex.Unlock()
+	if stub != nil {
+		fake.SetOptionsStub(arg1)
+	}
+}
+
+func (fake *FakeVexProbe) SetOptionsCallCount() int {
+	fake.setOptionsMutex.RLock()
+	defer fake.setOptionsMutex.RUnlock()
+	return len(fake.setOptionsArgsForCall)
+}
+
+func (fake *FakeVexProbe) SetOptionsCalls(stub func(options.Options)) {
+	fake.setOptionsMutex.Lock()
+	defer fake.setOptionsMutex.Unlock()
+	fake.SetOptionsStub = stub
+}
+
+func (fake *FakeVexProbe) SetOptionsArgsForCall(i int) options.Options {
+	fake.setOptionsMutex.RLock()
+	defer fake.setOptionsMutex.RUnlock()
+	argsForCall := fake.setOptionsArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeVexProbe) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.findDocumentsFromPurlMutex.RLock()
+	defer fake.findDocumentsFromPurlMutex.RUnlock()
+	fake.setOptionsMutex.RLock()
+	defer fake.setOptionsMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeVexProbe) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ discovery.VexProbe = new(FakeVexProbe)
@@ -4,48 +4,290 @@
 package discovery
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"time"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/openvex/go-vex/pkg/vex"
 	purl "github.com/package-url/packageurl-go"
 
 	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/discovery/validation"
+	"github.com/openvex/discovery/pkg/errdefs"
+	"github.com/openvex/discovery/pkg/normalize"
+	doci "github.com/openvex/discovery/pkg/oci"
+	"github.com/openvex/discovery/pkg/probers/oci"
+	"github.com/openvex/discovery/pkg/sbom"
+	"github.com/openvex/discovery/pkg/trust"
+	"github.com/openvex/discovery/pkg/vexref"
 )
 
 //counterfeiter:generate . agentImplementation
 
 type agentImplementation interface {
 	ParsePurl(string) (purl.PackageURL, error)
-	GetPackageProbe(options.Options, purl.PackageURL) (VexProbe, error)
-	FindDocumentsFromPurl(options.Options, VexProbe, purl.PackageURL) ([]*vex.VEX, error)
+	ReferenceToPurl(options.Options, string) (purl.PackageURL, error)
+	GetPackageProbe(*Registry, options.Options, purl.PackageURL) (VexProbe, error)
+	FindDocumentsFromPurl(options.Options, VexProbe, purl.PackageURL) ([]*results.Document, error)
+	FindSBOM(options.Options, VexProbe, purl.PackageURL) (*sbom.Contents, error)
+	FetchDocument(options.Options, *http.Client, string) (*results.Document, error)
+	ApplyTrustPolicy(*trust.Policy, purl.PackageURL, []*results.Document) ([]*results.Document, error)
+	FindEffectiveStatus([]*vex.VEX, string, string) (*VulnerabilityStatus, error)
 }
 
 type defaultAgentImplementation struct{}
 
-// ParsePurl checks if a purl is correctly formed
+// ParsePurl checks if a purl is correctly formed and returns it normalized
 func (pi *defaultAgentImplementation) ParsePurl(purlString string) (purl.PackageURL, error) {
 	p, err := purl.FromString(purlString)
 	if err != nil {
 		return p, err
 	}
-	return p, nil
+	return normalize.PackageURL(p)
 }
 
-// GetPackageProbe returns a PackageProbe for the specified purl type
-func (pi *defaultAgentImplementation) GetPackageProbe(opts options.Options, p purl.PackageURL) (VexProbe, error) {
-	if p, ok := probers[p.Type]; ok {
-		p.SetOptions(opts)
-		return p, nil
+// ReferenceToPurl converts a container image reference to its oci purl, see
+// oci.ReferenceToPurl. If the OCI prober options select a platform, it is
+// added as the os and arch qualifiers.
+func (pi *defaultAgentImplementation) ReferenceToPurl(opts options.Options, refString string) (purl.PackageURL, error) {
+	ociOpts, err := oci.GetOptions(opts)
+	if err != nil {
+		return purl.PackageURL{}, err
+	}
+
+	var os, arch string
+	if ociOpts.Platform != "" {
+		platform, err := v1.ParsePlatform(ociOpts.Platform)
+		if err != nil {
+			return purl.PackageURL{}, fmt.Errorf("parsing platform: %w", err)
+		}
+		os, arch = platform.OS, platform.Architecture
+	}
+
+	purlString, err := doci.ReferenceToPurl(refString, os, arch)
+	if err != nil {
+		return purl.PackageURL{}, err
 	}
-	return nil, fmt.Errorf("purl type %s not supported", p.Type)
+	return purl.FromString(purlString)
+}
+
+// GetPackageProbe returns a PackageProbe for the specified purl type. The
+// returned probe is the chain of probers registered for the type in the
+// agent's registry.
+func (pi *defaultAgentImplementation) GetPackageProbe(reg *Registry, opts options.Options, p purl.PackageURL) (VexProbe, error) {
+	if reg == nil {
+		return nil, fmt.Errorf("agent has no prober registry")
+	}
+	chain, err := reg.Chain(p.Type)
+	if err != nil {
+		return nil, err
+	}
+	chain.SetOptions(opts)
+	return chain, nil
 }
 
 // FetchDocuments downloads all OpenVEX documents using the PackageProbe for
 // the specified purl.
-func (pi *defaultAgentImplementation) FindDocumentsFromPurl(opts options.Options, pkgProbe VexProbe, p purl.PackageURL) ([]*vex.VEX, error) {
+func (pi *defaultAgentImplementation) FindDocumentsFromPurl(opts options.Options, pkgProbe VexProbe, p purl.PackageURL) ([]*results.Document, error) {
+	return findDocuments(opts, pkgProbe, p)
+}
+
+// FindSBOM returns the purls of the components listed in the SBOMs of a
+// package and the VEX documents they link, read with the package probe. The
+// probe must implement SBOMProbe, chains query their probers that do.
+func (pi *defaultAgentImplementation) FindSBOM(opts options.Options, pkgProbe VexProbe, p purl.PackageURL) (*sbom.Contents, error) {
+	sp, ok := pkgProbe.(SBOMProbe)
+	if !ok {
+		return nil, errdefs.New(errdefs.ErrUnsupportedPurlType, "no probers read sboms of purl type %s", p.Type)
+	}
+	return sp.FindSBOM(opts, p)
+}
+
+// FetchDocument downloads an OpenVEX document from an HTTPS URL with the
+// client passed, or a new client from NewHTTPClient when nil. The document
+// is validated with the default limits, documents that can't be parsed or
+// fail validation are returned as rejected.
+func (pi *defaultAgentImplementation) FetchDocument(opts options.Options, client *http.Client, url string) (*results.Document, error) {
+	if !vexref.IsHTTPS(url) {
+		return nil, errdefs.New(errdefs.ErrInvalidArgument, "refusing to fetch %s: only https urls are fetched", url)
+	}
+	if client == nil {
+		client = NewHTTPClient(doci.DefaultRetryPolicy)
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errdefs.New(errdefs.ErrParse, "building request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errdefs.New(errdefs.ErrNetwork, "fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, errdefs.New(errdefs.ErrNotFound, "fetching %s: %s", url, resp.Status)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, errdefs.New(errdefs.ErrUnauthorized, "fetching %s: %s", url, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, errdefs.New(errdefs.ErrNetwork, "fetching %s: %s", url, resp.Status)
+	}
+
+	limits := validation.DefaultLimits
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(limits.MaxPayloadSize)+1))
+	if err != nil {
+		return nil, errdefs.New(errdefs.ErrNetwork, "reading %s: %w", url, err)
+	}
+
+	doc := &results.Document{Provenance: results.Provenance{Source: url}}
+	if problems := limits.CheckPayloadSize(len(data)); problems != nil {
+		doc.Problems = problems
+		return doc, nil
+	}
+
+	doc.VEX, err = vex.Parse(data)
+	if err != nil {
+		doc.Problems = []string{fmt.Sprintf("parsing openvex document: %s", err)}
+		return doc, nil
+	}
+	if problems := validation.Document(doc.VEX, limits); len(problems) > 0 {
+		doc.Problems = problems
+	}
+	doc.References, _ = vexref.FromDocument(data)
+	return doc, nil
+}
+
+// DefaultHTTPTimeout is the time limit of the requests made by the clients
+// from NewHTTPClient
+const DefaultHTTPTimeout = 30 * time.Second
+
+// NewHTTPClient returns the client agents use to download linked documents.
+// Requests time out after DefaultHTTPTimeout and are retried following the
+// retry policy. Redirects are only followed to HTTPS URLs.
+func NewHTTPClient(policy doci.RetryPolicy) *http.Client {
+	return &http.Client{
+		Timeout:   DefaultHTTPTimeout,
+		Transport: doci.NewRetryTransport(http.DefaultTransport, policy, nil),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" {
+				return errdefs.New(errdefs.ErrInvalidArgument, "refusing redirect to %s: only https urls are fetched", req.URL)
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// findDocuments queries a probe for the documents of a purl. If the probe does
+// not report provenance data, the documents are returned with the probed purl
+// as their only provenance.
+func findDocuments(opts options.Options, pkgProbe VexProbe, p purl.PackageURL) ([]*results.Document, error) {
+	if pp, ok := pkgProbe.(ProvenanceProbe); ok {
+		docs, err := pp.FindDocumentsWithProvenance(opts, p)
+		if err != nil {
+			return nil, fmt.Errorf("looking for documents: %w", err)
+		}
+		return docs, nil
+	}
+
 	docs, err := pkgProbe.FindDocumentsFromPurl(opts, p)
 	if err != nil {
 		return nil, fmt.Errorf("looking for documents: %w", err)
 	}
-	return docs, nil
+	return results.FromVEX(docs, results.Provenance{Prober: p.Type, Purl: p.String()}), nil
+}
+
+// ApplyTrustPolicy evaluates the documents against the trust policy and
+// records the decision in each of them. If the policy is enforced, untrusted
+// documents are dropped from the returned list.
+func (pi *defaultAgentImplementation) ApplyTrustPolicy(policy *trust.Policy, p purl.PackageURL, docs []*results.Document) ([]*results.Document, error) {
+	if policy == nil {
+		return docs, nil
+	}
+
+	ret := []*results.Document{}
+	for _, d := range docs {
+		// Rejected documents are returned as is to report their problems
+		if d.Rejected() {
+			ret = append(ret, d)
+			continue
+		}
+		decision := policy.Evaluate(p.String(), d)
+		d.Trust = &decision
+		if !decision.Trusted && policy.Enforced() {
+			continue
+		}
+		ret = append(ret, d)
+	}
+	return ret, nil
+}
+
+// matchesProduct returns true if any of the products of a statement refers
+// to the product identifier. Purls are compared normalized.
+func matchesProduct(stmt *vex.Statement, product string) bool {
+	for i := range stmt.Products {
+		if normalize.MatchesComponent(&stmt.Products[i].Component, product) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindEffectiveStatus merges the statements of the documents that match the
+// product and vulnerability and returns the status of the latest one. Statements
+// without a timestamp inherit it from their document. When two statements have
+// the same time, the one found last wins.
+func (pi *defaultAgentImplementation) FindEffectiveStatus(docs []*vex.VEX, product, vulnID string) (*VulnerabilityStatus, error) {
+	var status *VulnerabilityStatus
+	var latest time.Time
+	for _, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		for i := range doc.Statements {
+			if !doc.Statements[i].Vulnerability.Matches(vulnID) ||
+				!matchesProduct(&doc.Statements[i], product) {
+				continue
+			}
+
+			var t time.Time
+			switch {
+			case doc.Statements[i].Timestamp != nil:
+				t = *doc.Statements[i].Timestamp
+			case doc.Timestamp != nil:
+				t = *doc.Timestamp
+			}
+
+			if status != nil && t.Before(latest) {
+				continue
+			}
+
+			latest = t
+			status = &VulnerabilityStatus{
+				Vulnerability:   doc.Statements[i].Vulnerability,
+				Status:          doc.Statements[i].Status,
+				Justification:   doc.Statements[i].Justification,
+				ImpactStatement: doc.Statements[i].ImpactStatement,
+				ActionStatement: doc.Statements[i].ActionStatement,
+				Statement:       &doc.Statements[i],
+				Document:        doc,
+			}
+		}
+	}
+	return status, nil
 }
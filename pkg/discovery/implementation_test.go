@@ -0,0 +1,219 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openvex/go-vex/pkg/vex"
+	purl "github.com/package-url/packageurl-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/errdefs"
+	doci "github.com/openvex/discovery/pkg/oci"
+	"github.com/openvex/discovery/pkg/probers/oci"
+	"github.com/openvex/discovery/pkg/trust"
+)
+
+func TestFindEffectiveStatus(t *testing.T) {
+	product := "pkg:oci/alpine-cves@sha256%3Aeece025e432126ce23f223450a0326fbebde39cdf496a85d8c016293fc851978"
+	t1 := time.Date(2023, 11, 25, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+
+	docA := &vex.VEX{
+		Metadata: vex.Metadata{ID: "doc-a", Timestamp: &t1},
+		Statements: []vex.Statement{
+			{
+				Vulnerability: vex.Vulnerability{Name: "CVE-2023-5363", Aliases: []vex.VulnerabilityID{"GHSA-xw78-pcr6-wrg8"}},
+				Products:      []vex.Product{{Component: vex.Component{ID: product}}},
+				Status:        vex.StatusUnderInvestigation,
+			},
+		},
+	}
+	docB := &vex.VEX{
+		Metadata: vex.Metadata{ID: "doc-b", Timestamp: &t2},
+		Statements: []vex.Statement{
+			{
+				Vulnerability: vex.Vulnerability{Name: "CVE-2023-5363"},
+				Products:      []vex.Product{{Component: vex.Component{ID: product}}},
+				Status:        vex.StatusNotAffected,
+				Justification: vex.InlineMitigationsAlreadyExist,
+			},
+		},
+	}
+
+	impl := defaultAgentImplementation{}
+	for _, tc := range []struct {
+		name     string
+		docs     []*vex.VEX
+		product  string
+		vulnID   string
+		expected vex.Status
+		docID    string
+	}{
+		{"latest wins", []*vex.VEX{docB, docA}, product, "CVE-2023-5363", vex.StatusNotAffected, "doc-b"},
+		{"alias", []*vex.VEX{docA}, product, "GHSA-xw78-pcr6-wrg8", vex.StatusUnderInvestigation, "doc-a"},
+		{"qualified purl", []*vex.VEX{docA, docB}, product + "?tag=latest", "CVE-2023-5363", vex.StatusNotAffected, "doc-b"},
+		{"other registry", []*vex.VEX{docA, docB}, product + "?repository_url=localhost:5000", "CVE-2023-5363", "", ""},
+		{"unencoded digest", []*vex.VEX{docA, docB}, strings.Replace(product, "%3A", ":", 1), "CVE-2023-5363", vex.StatusNotAffected, "doc-b"},
+		{"uppercase digest", []*vex.VEX{docA}, "pkg:oci/alpine-cves@" + strings.ToUpper(strings.TrimPrefix(product, "pkg:oci/alpine-cves@")), "CVE-2023-5363", vex.StatusUnderInvestigation, "doc-a"},
+		{"other vulnerability", []*vex.VEX{docA, docB}, product, "CVE-2023-5678", "", ""},
+		{"other product", []*vex.VEX{docA, docB}, "pkg:oci/alpine", "CVE-2023-5363", "", ""},
+		{"no documents", []*vex.VEX{}, product, "CVE-2023-5363", "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			status, err := impl.FindEffectiveStatus(tc.docs, tc.product, tc.vulnID)
+			require.NoError(t, err)
+			if tc.expected == "" {
+				require.Nil(t, status)
+				return
+			}
+			require.NotNil(t, status)
+			require.Equal(t, tc.expected, status.Status)
+			require.Equal(t, tc.docID, status.Document.ID)
+			require.Equal(t, status.Statement.Justification, status.Justification)
+		})
+	}
+}
+
+func TestApplyTrustPolicy(t *testing.T) {
+	p, err := purl.FromString("pkg:oci/alpine-cves?repository_url=localhost:5000")
+	require.NoError(t, err)
+	newDocs := func() []*results.Document {
+		return []*results.Document{
+			{VEX: &vex.VEX{}, Provenance: results.Provenance{Source: "localhost:5000/alpine-cves:latest"}},
+			{VEX: &vex.VEX{}, Provenance: results.Provenance{Source: "ghcr.io/mallory/alpine-cves:latest"}},
+		}
+	}
+	rules := []trust.Rule{{Purl: "pkg:oci/alpine-cves", Sources: []string{"localhost:5000/*"}}}
+
+	impl := defaultAgentImplementation{}
+	for _, tc := range []struct {
+		name     string
+		policy   *trust.Policy
+		expected int
+	}{
+		{"no policy", nil, 2},
+		{"enforced", &trust.Policy{Rules: rules}, 1},
+		{"warn", &trust.Policy{Rules: rules, Mode: trust.ModeWarn}, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			docs, err := impl.ApplyTrustPolicy(tc.policy, p, newDocs())
+			require.NoError(t, err)
+			require.Len(t, docs, tc.expected)
+			for _, d := range docs {
+				if tc.policy == nil {
+					require.Nil(t, d.Trust)
+					continue
+				}
+				require.NotNil(t, d.Trust)
+			}
+			if tc.policy != nil && !tc.policy.Enforced() {
+				require.False(t, docs[1].Trust.Trusted)
+			}
+		})
+	}
+}
+
+func TestReferenceToPurl(t *testing.T) {
+	impl := defaultAgentImplementation{}
+	digest := "sha256:eece025e432126ce23f223450a0326fbebde39cdf496a85d8c016293fc851978"
+	for _, tc := range []struct {
+		name     string
+		ref      string
+		platform string
+		expected string
+		mustErr  bool
+	}{
+		{"tag", "ghcr.io/openvex/discovery:v1.0.0", "", "pkg:oci/discovery?repository_url=ghcr.io%2Fopenvex&tag=v1.0.0", false},
+		{"docker hub", "alpine:3.18", "", "pkg:oci/alpine?repository_url=index.docker.io%2Flibrary&tag=3.18", false},
+		{"digest", "ghcr.io/openvex/discovery@" + digest, "", "pkg:oci/discovery@" + strings.ReplaceAll(digest, ":", "%3A") + "?repository_url=ghcr.io%2Fopenvex", false},
+		{"no path", "localhost:5000/image:latest", "", "pkg:oci/image?repository_url=localhost%3A5000&tag=latest", false},
+		{"platform", "ghcr.io/openvex/discovery:v1.0.0", "linux/arm64", "pkg:oci/discovery?arch=arm64&os=linux&repository_url=ghcr.io%2Fopenvex&tag=v1.0.0", false},
+		{"invalid reference", "Not A Reference", "", "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.New().WithProberOptions(purl.TypeOCI, oci.NewOptions(oci.WithPlatform(tc.platform)))
+			p, err := impl.ReferenceToPurl(opts, tc.ref)
+			if tc.mustErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, p.String())
+		})
+	}
+}
+
+func TestFetchDocument(t *testing.T) {
+	valid := `{
+		"@context": "https://openvex.dev/ns/v0.2.0",
+		"@id": "https://example.com/vex-1",
+		"author": "OpenVEX",
+		"timestamp": "2023-12-01T00:00:00Z",
+		"version": 1,
+		"statements": [],
+		"references": [{"type": "vex", "url": "https://example.com/vex-2"}]
+	}`
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect.json":
+			http.Redirect(w, r, "http://"+r.Host+"/valid.json", http.StatusFound)
+		case "/valid.json":
+			fmt.Fprint(w, valid)
+		case "/invalid.json":
+			fmt.Fprint(w, `{"@context": "https://example.com"}`)
+		case "/private.json":
+			w.WriteHeader(http.StatusForbidden)
+		case "/error.json":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// The test server certificate is trusted by its client
+	client := NewHTTPClient(doci.RetryPolicy{})
+	client.Transport = server.Client().Transport
+
+	impl := defaultAgentImplementation{}
+	for _, tc := range []struct {
+		name     string
+		url      string
+		path     string
+		rejected bool
+		kind     error
+	}{
+		{"valid", server.URL, "/valid.json", false, nil},
+		{"invalid", server.URL, "/invalid.json", true, nil},
+		{"not found", server.URL, "/missing.json", false, errdefs.ErrNotFound},
+		{"forbidden", server.URL, "/private.json", false, errdefs.ErrUnauthorized},
+		{"server error", server.URL, "/error.json", false, errdefs.ErrNetwork},
+		{"plain http", strings.Replace(server.URL, "https://", "http://", 1), "/valid.json", false, errdefs.ErrInvalidArgument},
+		{"redirect to plain http", server.URL, "/redirect.json", false, errdefs.ErrNetwork},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := impl.FetchDocument(options.New(), client, tc.url+tc.path)
+			if tc.kind != nil {
+				require.ErrorIs(t, err, tc.kind)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.rejected, doc.Rejected(), doc.Problems)
+			require.Equal(t, tc.url+tc.path, doc.Provenance.Source)
+			if !tc.rejected {
+				require.Equal(t, "https://example.com/vex-1", doc.VEX.ID)
+				require.Equal(t, []string{"https://example.com/vex-2"}, doc.References)
+			}
+		})
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"context"
 	"log/slog"
 	"os"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Options struct {
@@ -15,9 +18,39 @@ type Options struct {
 	// Prober options is a map keyed by purl types that holds free form structs
 	// that are passed as options to the corresponding PackageProber.
 	ProberOptions map[string]interface{}
+
+	// TracerProvider and MeterProvider receive the traces and metrics of
+	// the discovery calls. When nil, no telemetry is reported.
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
 }
 
 var Default = Options{
 	Logger:        slog.New(slog.NewJSONHandler(os.Stderr, nil)),
 	ProberOptions: map[string]interface{}{},
 }
+
+// New returns a copy of the default options. The copy has its own prober
+// options map so it can be modified without affecting other users of the
+// default options.
+func New() Options {
+	opts := Default
+	opts.ProberOptions = map[string]interface{}{}
+	for k, v := range Default.ProberOptions {
+		opts.ProberOptions[k] = v
+	}
+	return opts
+}
+
+// WithProberOptions returns a copy of the options with the options of the
+// prober for purlType replaced. The prober options map is copied so the
+// original options are not modified.
+func (opts Options) WithProberOptions(purlType string, proberOptions interface{}) Options {
+	newOpts := opts
+	newOpts.ProberOptions = map[string]interface{}{}
+	for k, v := range opts.ProberOptions {
+		newOpts.ProberOptions[k] = v
+	}
+	newOpts.ProberOptions[purlType] = proberOptions
+	return newOpts
+}
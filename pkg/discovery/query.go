@@ -0,0 +1,34 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"github.com/openvex/go-vex/pkg/vex"
+)
+
+// VulnerabilityStatus captures the effective VEX status of a vulnerability
+// in a software component after merging all the documents discovered for it.
+type VulnerabilityStatus struct {
+	// Vulnerability is the vulnerability as recorded in the effective statement,
+	// including its name and aliases.
+	Vulnerability vex.Vulnerability
+
+	// Status is the effective VEX status of the vulnerability.
+	Status vex.Status
+
+	// Justification is the justification of a not_affected status.
+	Justification vex.Justification
+
+	// ImpactStatement is the free form impact statement of a not_affected status.
+	ImpactStatement string
+
+	// ActionStatement is the remediation statement of an affected status.
+	ActionStatement string
+
+	// Statement is the VEX statement that determined the status.
+	Statement *vex.Statement
+
+	// Document is the VEX document where the effective statement was found.
+	Document *vex.VEX
+}
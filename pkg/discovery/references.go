@@ -0,0 +1,210 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"errors"
+	"fmt"
+
+	purl "github.com/package-url/packageurl-go"
+
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/discovery/telemetry"
+	"github.com/openvex/discovery/pkg/errdefs"
+	"github.com/openvex/discovery/pkg/vexref"
+)
+
+// DefaultMaxReferenceDepth is the number of links followed by new agents
+const DefaultMaxReferenceDepth = 3
+
+// link is a reference to a VEX location waiting to be followed
+type link struct {
+	location string
+
+	// chain is the list of locations followed to reach the link, ending
+	// with the link location
+	chain []string
+}
+
+// ProbePurlWithReferences probes a purl just as ProbePurlWithProvenance and
+// then follows the links to other VEX locations found, for images, in their
+// SBOM attestations and, when FollowReferencesExtension is set, in the
+// documents. Links to purls are probed with the registered probers. HTTPS links are downloaded with HTTPClient only when
+// FollowURLs is set. The documents found are linked in turn, up to
+// MaxReferenceDepth links away from the probed purl. Locations already
+// visited are not followed again, so reference cycles end.
+//
+// Links are only read from documents trusted by the agent's trust policy, so
+// without a trust policy only the purls in SBOMs are followed. SBOM
+// attestations are not verified, the URLs they link are never downloaded.
+//
+// The provenance of each linked document records the chain of links followed
+// to reach it. Links to locations of other kinds, to purls of types without a
+// prober and to missing documents are skipped, any other error stops the probe.
+func (agent *Agent) ProbePurlWithReferences(purlString string) ([]*results.Document, error) {
+	docs := []*results.Document{}
+	opts, span := telemetry.Start(agent.Options, "ProbePurlWithReferences", telemetry.AttrPurl.String(purlString))
+	err := agent.probeReferences(opts, purlString, collect(&docs))
+	telemetry.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// probeReferences implements ProbePurlWithReferences using the options
+// passed. The documents found at each location are passed to emit.
+func (agent *Agent) probeReferences(opts options.Options, purlString string, emit emitFunc) error {
+	p, err := agent.impl.ParsePurl(purlString)
+	if err != nil {
+		return errdefs.New(errdefs.ErrParse, "parsing purl: %w", err)
+	}
+
+	docs, err := agent.probePurl(opts, purlString)
+	if err != nil {
+		return err
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = options.Default.Logger
+	}
+
+	root := []string{p.String()}
+	seen := map[string]struct{}{p.String(): {}}
+	pending, err := agent.linksFrom(opts, &p, docs, root)
+	if err != nil {
+		return err
+	}
+	if err := emit(docs); err != nil {
+		return err
+	}
+
+	for depth := 1; depth <= agent.MaxReferenceDepth && len(pending) > 0; depth++ {
+		next := []link{}
+		for _, l := range pending {
+			key, ok := agent.locationKey(l.location)
+			if !ok {
+				logger.DebugContext(opts.Context, "skipping unsupported vex reference", "location", l.location)
+				continue
+			}
+			if _, ok := seen[key]; ok {
+				logger.DebugContext(opts.Context, "skipping visited vex reference", "location", l.location)
+				continue
+			}
+			seen[key] = struct{}{}
+
+			found, links, err := agent.followLink(opts, p, l)
+			if errors.Is(err, errdefs.ErrUnsupportedPurlType) || errors.Is(err, errdefs.ErrNotFound) {
+				logger.DebugContext(opts.Context, "skipping vex reference", "location", l.location, "error", err)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("following vex reference %s: %w", l.location, err)
+			}
+			if err := emit(found); err != nil {
+				return err
+			}
+			next = append(next, links...)
+		}
+		pending = next
+	}
+	return nil
+}
+
+// locationKey returns the key used to detect visited locations: purls are
+// normalized, URLs are used as they are. It returns false if the location
+// is not a purl or, when the agent follows URLs, an HTTPS URL.
+func (agent *Agent) locationKey(location string) (string, bool) {
+	switch {
+	case vexref.IsPurl(location):
+		p, err := agent.impl.ParsePurl(location)
+		if err != nil {
+			return "", false
+		}
+		return p.String(), true
+	case agent.FollowURLs && vexref.IsHTTPS(location):
+		return location, true
+	default:
+		return "", false
+	}
+}
+
+// followLink fetches the documents at a linked location. Documents from URLs
+// are evaluated by the trust policy as documents of the probed purl. It
+// returns the documents, annotated with the link chain, and their links.
+func (agent *Agent) followLink(opts options.Options, root purl.PackageURL, l link) ([]*results.Document, []link, error) {
+	var docs []*results.Document
+	var target *purl.PackageURL
+	if vexref.IsPurl(l.location) {
+		p, err := agent.impl.ParsePurl(l.location)
+		if err != nil {
+			return nil, nil, errdefs.New(errdefs.ErrParse, "parsing purl: %w", err)
+		}
+		target = &p
+		if docs, err = agent.probePurl(opts, l.location); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		sopts, span := telemetry.Start(opts, "FetchDocument", telemetry.AttrReference.String(l.location))
+		doc, err := agent.impl.FetchDocument(sopts, agent.HTTPClient, l.location)
+		telemetry.End(span, err)
+		if err != nil {
+			return nil, nil, err
+		}
+		doc.Provenance.Purl = root.String()
+		if docs, err = agent.impl.ApplyTrustPolicy(agent.TrustPolicy, root, []*results.Document{doc}); err != nil {
+			return nil, nil, fmt.Errorf("applying trust policy: %w", err)
+		}
+	}
+
+	for _, d := range docs {
+		d.Provenance.Chain = append([]string{}, l.chain...)
+	}
+
+	links, err := agent.linksFrom(opts, target, docs, l.chain)
+	if err != nil {
+		return nil, nil, err
+	}
+	return docs, links, nil
+}
+
+// linksFrom returns the links found in the trusted documents of a location,
+// if the agent follows the references extension, and, if the location is an
+// image purl, the purls linked from the SBOMs attested to it. Chain is the
+// list of locations followed to reach the documents.
+func (agent *Agent) linksFrom(opts options.Options, p *purl.PackageURL, docs []*results.Document, chain []string) ([]link, error) {
+	locations := []string{}
+	for _, d := range docs {
+		if !agent.FollowReferencesExtension || d.Rejected() || d.Trust == nil || !d.Trust.Trusted {
+			continue
+		}
+		locations = append(locations, d.References...)
+	}
+
+	if p != nil && p.Type == purl.TypeOCI {
+		contents, err := agent.findSBOM(opts, *p)
+		switch {
+		case errors.Is(err, errdefs.ErrNotFound), errors.Is(err, errdefs.ErrUnsupportedPurlType):
+		case err != nil:
+			return nil, fmt.Errorf("reading sbom of %s: %w", p, err)
+		default:
+			for _, loc := range contents.VEXReferences {
+				if vexref.IsPurl(loc) {
+					locations = append(locations, loc)
+				}
+			}
+		}
+	}
+
+	links := make([]link, 0, len(locations))
+	for _, loc := range locations {
+		links = append(links, link{
+			location: loc,
+			chain:    append(append([]string{}, chain...), loc),
+		})
+	}
+	return links, nil
+}
@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/openvex/go-vex/pkg/vex"
+	"github.com/package-url/packageurl-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/pkg/discovery"
+	"github.com/openvex/discovery/pkg/discovery/discoveryfakes"
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/errdefs"
+	"github.com/openvex/discovery/pkg/sbom"
+	"github.com/openvex/discovery/pkg/trust"
+)
+
+func TestProbePurlWithReferences(t *testing.T) {
+	app := "pkg:oci/app?repository_url=example.com"
+	remote := map[string]*results.Document{
+		"https://example.com/base.json": {
+			VEX: &vex.VEX{Metadata: vex.Metadata{ID: "base"}},
+			References: []string{
+				"https://example.com/deep.json", "https://example.com/base.json", app, "https://example.com/untrusted.json",
+			},
+		},
+		"https://example.com/untrusted.json": {
+			VEX:        &vex.VEX{Metadata: vex.Metadata{ID: "untrusted"}},
+			References: []string{"https://example.com/never.json"},
+		},
+		"https://example.com/deep.json": {
+			VEX:        &vex.VEX{Metadata: vex.Metadata{ID: "deep"}},
+			References: []string{"https://example.com/deeper.json", "https://example.com/missing.json"},
+		},
+		"https://example.com/deeper.json": {VEX: &vex.VEX{Metadata: vex.Metadata{ID: "deeper"}}},
+		"https://example.com/never.json":  {VEX: &vex.VEX{Metadata: vex.Metadata{ID: "never"}}},
+		"https://example.com/sbom.json":   {VEX: &vex.VEX{Metadata: vex.Metadata{ID: "never"}}},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		depth      int
+		extension  bool
+		followURLs bool
+		trusted    bool
+		fetchErr   error
+		expected   []string
+		mustErr    bool
+	}{
+		{"default depth", discovery.DefaultMaxReferenceDepth, true, true, true, nil, []string{"app", "base", "sbomimg", "deep", "untrusted", "deeper"}, false},
+		{"depth limit", 2, true, true, true, nil, []string{"app", "base", "sbomimg", "deep", "untrusted"}, false},
+		{"references disabled", 0, true, true, true, nil, []string{"app"}, false},
+		{"extension not followed", discovery.DefaultMaxReferenceDepth, false, true, true, nil, []string{"app", "sbomimg"}, false},
+		{"urls not followed", discovery.DefaultMaxReferenceDepth, true, false, true, nil, []string{"app", "sbomimg"}, false},
+		{"no trust decision", discovery.DefaultMaxReferenceDepth, true, true, false, nil, []string{"app", "sbomimg"}, false},
+		{"fetch fails", 1, true, true, true, errdefs.New(errdefs.ErrNetwork, "connection refused"), nil, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			agent := discovery.NewAgent()
+			agent.MaxReferenceDepth = tc.depth
+			agent.FollowReferencesExtension = tc.extension
+			agent.FollowURLs = tc.followURLs
+			impl := &discoveryfakes.FakeAgentImplementation{}
+			impl.ParsePurlStub = packageurl.FromString
+			impl.GetPackageProbeStub = func(_ *discovery.Registry, _ options.Options, p packageurl.PackageURL) (discovery.VexProbe, error) {
+				if p.Type != packageurl.TypeOCI {
+					return nil, errdefs.New(errdefs.ErrUnsupportedPurlType, "no prober")
+				}
+				return &discoveryfakes.FakeVexProbe{}, nil
+			}
+			impl.FindDocumentsFromPurlStub = func(_ options.Options, _ discovery.VexProbe, p packageurl.PackageURL) ([]*results.Document, error) {
+				return []*results.Document{{
+					VEX:        &vex.VEX{Metadata: vex.Metadata{ID: p.Name}},
+					Provenance: results.Provenance{Purl: p.String()},
+					References: []string{
+						"https://example.com/base.json", "pkg:npm/lib@1.0.0", "s3://bucket/doc.json", "http://example.com/plain.json",
+					},
+				}}, nil
+			}
+			impl.FindSBOMReturns(&sbom.Contents{VEXReferences: []string{
+				"https://example.com/sbom.json", "pkg:oci/sbomimg?repository_url=example.com",
+			}}, nil)
+			impl.FetchDocumentStub = func(_ options.Options, _ *http.Client, url string) (*results.Document, error) {
+				if tc.fetchErr != nil {
+					return nil, tc.fetchErr
+				}
+				doc, ok := remote[url]
+				if !ok {
+					return nil, errdefs.New(errdefs.ErrNotFound, "%s not found", url)
+				}
+				d := *doc
+				d.Provenance = results.Provenance{Source: url}
+				return &d, nil
+			}
+			impl.ApplyTrustPolicyStub = func(_ *trust.Policy, _ packageurl.PackageURL, docs []*results.Document) ([]*results.Document, error) {
+				if tc.trusted {
+					for _, d := range docs {
+						d.Trust = &results.TrustDecision{Trusted: d.VEX.ID != "untrusted"}
+					}
+				}
+				return docs, nil
+			}
+			agent.SetImplementation(impl)
+
+			docs, err := agent.ProbePurlWithReferences(app)
+			if tc.mustErr {
+				require.ErrorIs(t, err, errdefs.ErrNetwork)
+				return
+			}
+			require.NoError(t, err)
+
+			ids := []string{}
+			chains := map[string][]string{}
+			for _, d := range docs {
+				ids = append(ids, d.VEX.ID)
+				chains[d.VEX.ID] = d.Provenance.Chain
+			}
+			require.Equal(t, tc.expected, ids)
+			require.Empty(t, chains["app"])
+			for i := 0; i < impl.FetchDocumentCallCount(); i++ {
+				_, client, _ := impl.FetchDocumentArgsForCall(i)
+				require.Same(t, agent.HTTPClient, client)
+			}
+			if tc.depth >= 3 && tc.extension && tc.followURLs && tc.trusted {
+				require.Equal(t, []string{
+					app, "https://example.com/base.json", "https://example.com/deep.json", "https://example.com/deeper.json",
+				}, chains["deeper"])
+				require.Equal(t, app, docs[len(docs)-1].Provenance.Purl)
+			}
+		})
+	}
+}
+
+func TestProbePurlWithReferencesCycle(t *testing.T) {
+	agent := discovery.NewAgent()
+	agent.MaxReferenceDepth = 10
+	agent.FollowReferencesExtension = true
+	impl := &discoveryfakes.FakeAgentImplementation{}
+	impl.ParsePurlStub = packageurl.FromString
+	impl.FindDocumentsFromPurlStub = func(_ options.Options, _ discovery.VexProbe, p packageurl.PackageURL) ([]*results.Document, error) {
+		next := "pkg:oci/a"
+		if p.Name == "a" {
+			next = "pkg:oci/b"
+		}
+		return []*results.Document{{VEX: &vex.VEX{Metadata: vex.Metadata{ID: p.Name}}, References: []string{next}}}, nil
+	}
+	impl.FindSBOMReturns(&sbom.Contents{}, nil)
+	impl.ApplyTrustPolicyStub = func(_ *trust.Policy, _ packageurl.PackageURL, docs []*results.Document) ([]*results.Document, error) {
+		for _, d := range docs {
+			d.Trust = &results.TrustDecision{Trusted: true}
+		}
+		return docs, nil
+	}
+	agent.SetImplementation(impl)
+
+	docs, err := agent.ProbePurlWithReferences("pkg:oci/a")
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	require.Equal(t, []string{"pkg:oci/a", "pkg:oci/b"}, docs[1].Provenance.Chain)
+	require.Equal(t, 2, impl.FindDocumentsFromPurlCallCount())
+}
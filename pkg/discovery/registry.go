@@ -0,0 +1,266 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	purl "github.com/package-url/packageurl-go"
+
+	"github.com/openvex/discovery/pkg/errdefs"
+	"github.com/openvex/discovery/pkg/probers/oci"
+	"github.com/openvex/discovery/pkg/probers/plugin"
+)
+
+// OCIDriverName is the name of the built in OCI prober in the chain of the
+// oci purl type
+const OCIDriverName = "oci"
+
+// ProberFactory is a function that returns a new instance of a VexProbe
+type ProberFactory func() VexProbe
+
+// defaultRegistry is the registry that seeds the registries of new agents
+var defaultRegistry = NewRegistry()
+
+func init() {
+	RegisterBuiltInDrivers()
+}
+
+// Registry holds the chains of probers an agent queries, keyed by purl type.
+//
+// Probers can be registered as instances or as factories. Instances are
+// shared by all the agents seeded from the registry, they must be safe for
+// concurrent use and get their options only as an argument to
+// FindDocumentsFromPurl. Factories are called each time the agent probes a
+// purl, the new probe gets its options set with SetOptions.
+type Registry struct {
+	mtx    sync.RWMutex
+	chains map[string]*Chain
+}
+
+// NewRegistry returns a new empty registry
+func NewRegistry() *Registry {
+	return &Registry{chains: map[string]*Chain{}}
+}
+
+// NewDefaultRegistry returns a new registry with the built in probers
+func NewDefaultRegistry() *Registry {
+	reg := NewRegistry()
+	reg.RegisterBuiltInDrivers()
+	return reg
+}
+
+// Clone returns a copy of the registry. Changes in the copy don't affect
+// the original registry.
+func (reg *Registry) Clone() *Registry {
+	reg.mtx.RLock()
+	defer reg.mtx.RUnlock()
+	ret := NewRegistry()
+	for t, chain := range reg.chains {
+		ret.chains[t] = chain.clone()
+	}
+	return ret
+}
+
+// RegisterBuiltInDrivers adds all the built in backend drivers to the registry
+func (reg *Registry) RegisterBuiltInDrivers() {
+	reg.RegisterDriverFactory(purl.TypeOCI, OCIDriverName, func() VexProbe {
+		return oci.New()
+	})
+}
+
+// RegisterDriver adds a new VexProbe at the end of the chain of probers of a
+// purl type. The probe is named after its Go type, registering another probe
+// of the same Go type for the purl type replaces it. Go type names change when
+// code is moved, use RegisterNamedDriver to give probes that are configured
+// by name a stable one.
+func (reg *Registry) RegisterDriver(purlType string, probe VexProbe) {
+	reg.RegisterNamedDriver(purlType, fmt.Sprintf("%T", probe), probe)
+}
+
+// RegisterNamedDriver adds a new VexProbe at the end of the chain of probers
+// of a purl type. If a probe with the same name is already registered for the
+// purl type, it gets replaced keeping its position in the chain.
+func (reg *Registry) RegisterNamedDriver(purlType, name string, probe VexProbe) {
+	reg.register(purlType, &chainEntry{name: name, probe: probe, enabled: true})
+}
+
+// RegisterDriverFactory adds a factory at the end of the chain of probers of
+// a purl type. A new probe is created with the factory each time a purl of
+// the type is probed. If a probe with the same name is already registered
+// for the purl type, it gets replaced keeping its position in the chain.
+func (reg *Registry) RegisterDriverFactory(purlType, name string, factory ProberFactory) {
+	reg.register(purlType, &chainEntry{name: name, factory: factory, enabled: true})
+}
+
+func (reg *Registry) register(purlType string, entry *chainEntry) {
+	reg.mtx.Lock()
+	defer reg.mtx.Unlock()
+	if _, ok := reg.chains[purlType]; !ok {
+		reg.chains[purlType] = &Chain{Policy: FirstHit}
+	}
+	reg.chains[purlType].add(entry)
+}
+
+// EnableDriver enables a named probe in the chain of a purl type
+func (reg *Registry) EnableDriver(purlType, name string) error {
+	return reg.setDriverEnabled(purlType, name, true)
+}
+
+// DisableDriver disables a named probe in the chain of a purl type. Disabled
+// probes remain registered but are not queried.
+func (reg *Registry) DisableDriver(purlType, name string) error {
+	return reg.setDriverEnabled(purlType, name, false)
+}
+
+func (reg *Registry) setDriverEnabled(purlType, name string, enabled bool) error {
+	reg.mtx.Lock()
+	defer reg.mtx.Unlock()
+	chain, ok := reg.chains[purlType]
+	if !ok {
+		return fmt.Errorf("no probers registered for purl type %s", purlType)
+	}
+	return chain.setEnabled(name, enabled)
+}
+
+// SetDriverOrder changes the order in which the probers of a purl type are
+// queried. The named probers are moved to the front of the chain in the order
+// listed, the rest keep their order after them.
+func (reg *Registry) SetDriverOrder(purlType string, names []string) error {
+	reg.mtx.Lock()
+	defer reg.mtx.Unlock()
+	chain, ok := reg.chains[purlType]
+	if !ok {
+		return fmt.Errorf("no probers registered for purl type %s", purlType)
+	}
+	return chain.reorder(names)
+}
+
+// SetChainPolicy sets how the probers registered for a purl type are queried
+func (reg *Registry) SetChainPolicy(purlType string, policy ChainPolicy) error {
+	if policy != FirstHit && policy != QueryAll {
+		return fmt.Errorf("unknown chain policy %q", policy)
+	}
+	reg.mtx.Lock()
+	defer reg.mtx.Unlock()
+	if _, ok := reg.chains[purlType]; !ok {
+		reg.chains[purlType] = &Chain{}
+	}
+	reg.chains[purlType].Policy = policy
+	return nil
+}
+
+// Drivers returns the probers registered for a purl type, in the order they
+// are queried.
+func (reg *Registry) Drivers(purlType string) []ProberInfo {
+	reg.mtx.RLock()
+	defer reg.mtx.RUnlock()
+	if chain, ok := reg.chains[purlType]; ok {
+		return chain.Probers()
+	}
+	return []ProberInfo{}
+}
+
+// RegisterPlugins registers the out-of-process probers found in a plugins
+// directory. Plugin executables must be named vex-probe-<purl type>, they
+// are added at the end of the chain of their purl type named as
+// plugin:<executable name>.
+func (reg *Registry) RegisterPlugins(dir string) error {
+	plugins, err := plugin.Discover(dir)
+	if err != nil {
+		return fmt.Errorf("discovering plugins: %w", err)
+	}
+	for purlType, p := range plugins {
+		p := p
+		reg.RegisterDriverFactory(purlType, "plugin:"+filepath.Base(p.Path), func() VexProbe {
+			return plugin.New(p.Type, p.Path, p.Args...)
+		})
+	}
+	return nil
+}
+
+// UnregisterDrivers removes all registered backend drivers from the registry
+func (reg *Registry) UnregisterDrivers() {
+	reg.mtx.Lock()
+	reg.chains = map[string]*Chain{}
+	reg.mtx.Unlock()
+}
+
+// Chain returns the chain of probers for a purl type, ready to be used to
+// probe a purl. Probers registered with a factory are instantiated and get
+// the options set.
+func (reg *Registry) Chain(purlType string) (*Chain, error) {
+	reg.mtx.RLock()
+	defer reg.mtx.RUnlock()
+	chain, ok := reg.chains[purlType]
+	if !ok || len(chain.entries) == 0 {
+		return nil, errdefs.New(errdefs.ErrUnsupportedPurlType, "purl type %s not supported", purlType)
+	}
+	return chain.instantiate(), nil
+}
+
+// RegisterBuiltInDrivers adds all the built in backend drivers to the
+// default registry.
+func RegisterBuiltInDrivers() {
+	defaultRegistry.RegisterBuiltInDrivers()
+}
+
+// RegisterDriver adds a new VexProbe to the default registry. New agents get
+// a copy of the default registry, see Registry.RegisterDriver.
+func RegisterDriver(purlType string, probe VexProbe) {
+	defaultRegistry.RegisterDriver(purlType, probe)
+}
+
+// RegisterNamedDriver adds a named VexProbe to the default registry, see
+// Registry.RegisterNamedDriver.
+func RegisterNamedDriver(purlType, name string, probe VexProbe) {
+	defaultRegistry.RegisterNamedDriver(purlType, name, probe)
+}
+
+// RegisterDriverFactory adds a prober factory to the default registry, see
+// Registry.RegisterDriverFactory.
+func RegisterDriverFactory(purlType, name string, factory ProberFactory) {
+	defaultRegistry.RegisterDriverFactory(purlType, name, factory)
+}
+
+// EnableDriver enables a named probe in the default registry
+func EnableDriver(purlType, name string) error {
+	return defaultRegistry.EnableDriver(purlType, name)
+}
+
+// DisableDriver disables a named probe in the default registry
+func DisableDriver(purlType, name string) error {
+	return defaultRegistry.DisableDriver(purlType, name)
+}
+
+// SetDriverOrder sets the order of the probers of a purl type in the default
+// registry, see Registry.SetDriverOrder.
+func SetDriverOrder(purlType string, names []string) error {
+	return defaultRegistry.SetDriverOrder(purlType, names)
+}
+
+// SetChainPolicy sets the chain policy of a purl type in the default registry
+func SetChainPolicy(purlType string, policy ChainPolicy) error {
+	return defaultRegistry.SetChainPolicy(purlType, policy)
+}
+
+// Drivers returns the probers registered for a purl type in the default registry
+func Drivers(purlType string) []ProberInfo {
+	return defaultRegistry.Drivers(purlType)
+}
+
+// RegisterPlugins registers the plugins found in a directory in the default
+// registry, see Registry.RegisterPlugins.
+func RegisterPlugins(dir string) error {
+	return defaultRegistry.RegisterPlugins(dir)
+}
+
+// UnregisterDrivers removes all registered backend drivers from the default
+// registry. This is useful when you want to enable only specific drivers
+// or register custom ones.
+func UnregisterDrivers() {
+	defaultRegistry.UnregisterDrivers()
+}
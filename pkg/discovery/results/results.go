@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package results
+
+import (
+	"github.com/openvex/go-vex/pkg/vex"
+)
+
+// Document is a VEX document found by a prober along with the data about
+// where it was found, who signed it and how it was evaluated by the agent.
+type Document struct {
+	VEX        *vex.VEX
+	Provenance Provenance
+
+	// Trust is the decision of the agent's trust policy about the document.
+	// It is nil when the agent has no trust policy set.
+	Trust *TrustDecision
+
+	// Problems lists the validation problems found in the document. A
+	// document with problems was rejected and must not be used, its VEX
+	// data is kept as parsed for inspection or is nil if it could not be
+	// read.
+	Problems []string
+
+	// Warnings lists problems found in the document that were not severe
+	// enough to reject it under the prober settings.
+	Warnings []string
+
+	// References lists the locations of other VEX documents linked from the
+	// document, as URLs or purls. They are read from the references list
+	// extension, see the vexref package.
+	References []string
+}
+
+// Rejected returns true if the document failed validation
+func (d *Document) Rejected() bool {
+	return len(d.Problems) > 0
+}
+
+// Provenance records where a document was found.
+type Provenance struct {
+	// Prober is the purl type of the prober that found the document. It is
+	// empty for documents downloaded from a URL.
+	Prober string
+
+	// Purl is the package URL that was probed to find the document
+	Purl string
+
+	// Source is the location where the document was retrieved from, for
+	// example the attestation tag in the repository the OCI prober read the
+	// attestations from.
+	Source string
+
+	// Platform is the platform of the image the document was attached to
+	// when probing all the images of a multi-arch index. It is empty for
+	// documents attached to the index itself.
+	Platform string
+
+	// Signatures has the data of the signatures wrapping the document, if any.
+	Signatures []Signature
+
+	// Retries counts the requests the prober retried during the probe
+	// that found the document.
+	Retries RetryStats
+
+	// Chain lists the links followed to reach the document: the probed purl
+	// and each of the referenced locations up to the one where the document
+	// was found. It is empty for documents found by probing the purl.
+	Chain []string
+}
+
+// RetryStats counts the requests retried by a prober.
+type RetryStats struct {
+	// Retries is the number of retried requests
+	Retries int
+
+	// RateLimited is the number of responses that signaled a rate limit
+	RateLimited int
+}
+
+// Signature captures the signer data of a signed document.
+type Signature struct {
+	KeyID    string
+	Identity string
+	Issuer   string
+
+	// Verified is set to true when the prober cryptographically verified the
+	// signature and the signer identity. Unverified identities are read from
+	// the signing certificate but must not be relied on.
+	Verified bool
+}
+
+// TrustDecision explains why a document was trusted or not.
+type TrustDecision struct {
+	Trusted bool
+
+	// Rule is the name of the policy rule that made the decision. It is empty
+	// when the policy default was applied.
+	Rule string
+
+	// Reason is a human readable explanation of the decision.
+	Reason string
+}
+
+// ToVEX unwraps a list of result documents into the VEX documents they
+// hold. Rejected documents are skipped.
+func ToVEX(docs []*Document) []*vex.VEX {
+	ret := make([]*vex.VEX, 0, len(docs))
+	for _, d := range docs {
+		if d.Rejected() || d.VEX == nil {
+			continue
+		}
+		ret = append(ret, d.VEX)
+	}
+	return ret
+}
+
+// CountAccepted returns the number of documents that passed validation
+func CountAccepted(docs []*Document) int {
+	n := 0
+	for _, d := range docs {
+		if !d.Rejected() {
+			n++
+		}
+	}
+	return n
+}
+
+// FromVEX wraps a list of VEX documents in result documents with the
+// specified provenance.
+func FromVEX(docs []*vex.VEX, prov Provenance) []*Document {
+	ret := make([]*Document, 0, len(docs))
+	for _, d := range docs {
+		ret = append(ret, &Document{VEX: d, Provenance: prov})
+	}
+	return ret
+}
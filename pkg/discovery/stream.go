@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/discovery/telemetry"
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+// emitFunc receives the documents found at each location probed. Returning
+// an error stops the probe.
+type emitFunc func([]*results.Document) error
+
+// collect returns an emitFunc that appends the documents to a list
+func collect(docs *[]*results.Document) emitFunc {
+	return func(found []*results.Document) error {
+		*docs = append(*docs, found...)
+		return nil
+	}
+}
+
+// Summary describes a finished streaming probe
+type Summary struct {
+	// Probes is the number of locations probed: the probed purl, the SBOM
+	// components or the followed references.
+	Probes int
+
+	// Documents is the number of documents sent, Rejected how many of them
+	// failed validation.
+	Documents int
+	Rejected  int
+
+	// Err is the error that stopped the probe. It wraps the context error
+	// when the probe was canceled.
+	Err error
+}
+
+// Stream is a probe running in the background. The documents are sent to
+// the Documents channel as they are found, the channel is closed when the
+// probe ends. Callers must read the channel until it is closed or cancel the
+// context of the probe.
+type Stream struct {
+	docs chan *results.Document
+	done chan struct{}
+
+	mu      sync.Mutex
+	summary Summary
+}
+
+// Documents returns the channel where the documents found are sent
+func (s *Stream) Documents() <-chan *results.Document {
+	return s.docs
+}
+
+// Summary waits for the probe to end and returns its summary
+func (s *Stream) Summary() Summary {
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.summary
+}
+
+// Done returns a channel that is closed when the probe ends
+func (s *Stream) Done() <-chan struct{} {
+	return s.done
+}
+
+// emitter returns an emitFunc that sends the documents to the stream. It
+// stops the probe when the context is canceled.
+func (s *Stream) emitter(ctx context.Context) emitFunc {
+	return func(docs []*results.Document) error {
+		s.count(func(sum *Summary) { sum.Probes++ })
+		for _, d := range docs {
+			select {
+			case s.docs <- d:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			s.count(func(sum *Summary) {
+				sum.Documents++
+				if d.Rejected() {
+					sum.Rejected++
+				}
+			})
+		}
+		return ctx.Err()
+	}
+}
+
+// count updates the summary
+func (s *Stream) count(update func(*Summary)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update(&s.summary)
+}
+
+// StreamPurl probes a purl just as ProbePurlWithProvenance, sending the
+// documents to the returned stream.
+func (agent *Agent) StreamPurl(ctx context.Context, purlString string) *Stream {
+	return agent.stream(ctx, "ProbePurl", telemetry.AttrPurl.String(purlString), func(opts options.Options, emit emitFunc) error {
+		docs, err := agent.probePurl(opts, purlString)
+		if err != nil {
+			return err
+		}
+		return emit(docs)
+	})
+}
+
+// StreamImageSBOM probes an image and the components in its SBOMs just as
+// ProbeImageSBOMWithProvenance, sending the documents found at each
+// component to the returned stream as soon as it is probed.
+func (agent *Agent) StreamImageSBOM(ctx context.Context, refString string) *Stream {
+	return agent.stream(ctx, "ProbeImageSBOM", telemetry.AttrReference.String(refString), func(opts options.Options, emit emitFunc) error {
+		p, err := agent.impl.ReferenceToPurl(opts, refString)
+		if err != nil {
+			return errdefs.New(errdefs.ErrParse, "converting image reference to purl: %w", err)
+		}
+		return agent.probeSBOM(opts, p.String(), map[string]struct{}{}, emit)
+	})
+}
+
+// StreamPurlWithReferences probes a purl and follows the VEX references
+// found just as ProbePurlWithReferences, sending the documents found at each
+// location to the returned stream as soon as it is probed.
+func (agent *Agent) StreamPurlWithReferences(ctx context.Context, purlString string) *Stream {
+	return agent.stream(ctx, "ProbePurlWithReferences", telemetry.AttrPurl.String(purlString), func(opts options.Options, emit emitFunc) error {
+		return agent.probeReferences(opts, purlString, emit)
+	})
+}
+
+// stream runs a probe in the background with the agent options bound to ctx
+func (agent *Agent) stream(
+	ctx context.Context, name string, attr attribute.KeyValue, probe func(options.Options, emitFunc) error,
+) *Stream {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s := &Stream{docs: make(chan *results.Document), done: make(chan struct{})}
+
+	opts := agent.Options
+	opts.Context = ctx
+	go func() {
+		defer close(s.done)
+		defer close(s.docs)
+
+		sopts, span := telemetry.Start(opts, name, attr)
+		err := probe(sopts, s.emitter(ctx))
+		telemetry.End(span, err)
+		s.count(func(sum *Summary) { sum.Err = err })
+	}()
+	return s
+}
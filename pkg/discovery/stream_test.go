@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openvex/go-vex/pkg/vex"
+	"github.com/package-url/packageurl-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/pkg/discovery"
+	"github.com/openvex/discovery/pkg/discovery/discoveryfakes"
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/errdefs"
+	"github.com/openvex/discovery/pkg/sbom"
+	"github.com/openvex/discovery/pkg/trust"
+)
+
+// sbomAgent returns an agent probing an image with an SBOM listing three
+// components. Each purl has one document, the second component's is rejected.
+func sbomAgent(t *testing.T) (*discovery.Agent, *discoveryfakes.FakeAgentImplementation) {
+	t.Helper()
+	image := "pkg:oci/wolfi-base?repository_url=cgr.dev%2Fchainguard&tag=latest"
+	agent := discovery.NewAgent()
+	impl := &discoveryfakes.FakeAgentImplementation{}
+	p, err := packageurl.FromString(image)
+	require.NoError(t, err)
+	impl.ReferenceToPurlReturns(p, nil)
+	impl.ParsePurlStub = packageurl.FromString
+	impl.FindSBOMReturns(&sbom.Contents{Purls: []string{
+		"pkg:apk/wolfi/busybox@1.36.1-r0", "pkg:apk/wolfi/glibc@2.38-r1", "pkg:apk/wolfi/curl@8.4.0-r0",
+	}}, nil)
+	impl.FindDocumentsFromPurlStub = func(_ options.Options, _ discovery.VexProbe, p packageurl.PackageURL) ([]*results.Document, error) {
+		doc := &results.Document{VEX: &vex.VEX{}, Provenance: results.Provenance{Purl: p.String()}}
+		if p.Name == "glibc" {
+			doc.Problems = []string{"document has no author"}
+		}
+		return []*results.Document{doc}, nil
+	}
+	impl.ApplyTrustPolicyStub = func(_ *trust.Policy, _ packageurl.PackageURL, docs []*results.Document) ([]*results.Document, error) {
+		return docs, nil
+	}
+	agent.SetImplementation(impl)
+	return agent, impl
+}
+
+func TestStreamImageSBOM(t *testing.T) {
+	agent, _ := sbomAgent(t)
+	expected, err := agent.ProbeImageSBOMWithProvenance("cgr.dev/chainguard/wolfi-base:latest")
+	require.NoError(t, err)
+
+	stream := agent.StreamImageSBOM(context.Background(), "cgr.dev/chainguard/wolfi-base:latest")
+	docs := []*results.Document{}
+	for d := range stream.Documents() {
+		docs = append(docs, d)
+	}
+	require.Equal(t, expected, docs)
+	require.Equal(t, discovery.Summary{Probes: 4, Documents: 4, Rejected: 1}, stream.Summary())
+}
+
+func TestStreamCancel(t *testing.T) {
+	agent, _ := sbomAgent(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := agent.StreamImageSBOM(ctx, "cgr.dev/chainguard/wolfi-base:latest")
+
+	d := <-stream.Documents()
+	require.Equal(t, "pkg:oci/wolfi-base?repository_url=cgr.dev%2Fchainguard&tag=latest", d.Provenance.Purl)
+	cancel()
+
+	summary := stream.Summary()
+	require.ErrorIs(t, summary.Err, context.Canceled)
+	require.Equal(t, 1, summary.Documents)
+
+	// The channel is closed when the probe ends
+	_, ok := <-stream.Documents()
+	require.False(t, ok)
+}
+
+func TestStreamPurl(t *testing.T) {
+	agent, impl := sbomAgent(t)
+
+	stream := agent.StreamPurl(context.Background(), "pkg:apk/wolfi/busybox@1.36.1-r0")
+	docs := []*results.Document{}
+	for d := range stream.Documents() {
+		docs = append(docs, d)
+	}
+	require.Len(t, docs, 1)
+	require.Equal(t, discovery.Summary{Probes: 1, Documents: 1}, stream.Summary())
+	require.Equal(t, 0, impl.FindSBOMCallCount())
+
+	impl.ParsePurlReturns(packageurl.PackageURL{}, errdefs.New(errdefs.ErrParse, "invalid purl"))
+	impl.ParsePurlStub = nil
+	stream = agent.StreamPurl(context.Background(), "not a purl")
+	<-stream.Done()
+	_, ok := <-stream.Documents()
+	require.False(t, ok)
+	require.ErrorIs(t, stream.Summary().Err, errdefs.ErrParse)
+}
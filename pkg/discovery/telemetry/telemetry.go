@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package telemetry reports the traces and metrics of the discovery agent
+// and its probers to the OpenTelemetry providers set in the options. When
+// the options have no providers, nothing is reported.
+package telemetry
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+// InstrumentationName is the name of the tracer and meter of the module
+const InstrumentationName = "github.com/openvex/discovery"
+
+// Names of the metrics reported
+const (
+	MetricDocuments = "openvex.discovery.documents"
+	MetricErrors    = "openvex.discovery.errors"
+	MetricDuration  = "openvex.discovery.probe.duration"
+)
+
+// Attribute keys used in spans and metrics
+const (
+	AttrPurl      = attribute.Key("openvex.purl")
+	AttrPurlType  = attribute.Key("openvex.purl.type")
+	AttrProber    = attribute.Key("openvex.prober")
+	AttrReference = attribute.Key("openvex.reference")
+	AttrErrorType = attribute.Key("error.type")
+)
+
+// tracerProvider returns the tracer provider set in the options or a
+// no-op one.
+func tracerProvider(opts options.Options) trace.TracerProvider {
+	if opts.TracerProvider == nil {
+		return trace.NewNoopTracerProvider()
+	}
+	return opts.TracerProvider
+}
+
+// meterProvider returns the meter provider set in the options or a no-op one
+func meterProvider(opts options.Options) metric.MeterProvider {
+	if opts.MeterProvider == nil {
+		return noop.NewMeterProvider()
+	}
+	return opts.MeterProvider
+}
+
+// Start starts a span as a child of the span in the options context. It
+// returns a copy of the options with the context of the new span so that
+// the calls made with them are traced under it.
+func Start(opts options.Options, name string, attrs ...attribute.KeyValue) (options.Options, trace.Span) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := tracerProvider(opts).Tracer(InstrumentationName).Start(
+		ctx, name, trace.WithAttributes(attrs...),
+	)
+	opts.Context = ctx
+	return opts, span
+}
+
+// End ends a span. If err is not nil, it is recorded in the span and the
+// span is marked as failed.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(AttrErrorType.String(ErrorType(err)))
+	}
+	span.End()
+}
+
+// ErrorType returns the kind name of an error to use in metrics and spans,
+// "unknown" if the error is not of a known kind.
+func ErrorType(err error) string {
+	if kind := errdefs.KindName(err); kind != "" {
+		return kind
+	}
+	return "unknown"
+}
+
+// Recorder records the metrics of the prober calls. Its instruments are
+// created once, when the recorder is built.
+type Recorder struct {
+	duration  metric.Float64Histogram
+	failures  metric.Int64Counter
+	documents metric.Int64Counter
+}
+
+// NewRecorder creates the metric instruments in a meter of the provider and
+// returns a recorder that reports to them. A nil provider reports nothing.
+// Instruments that can't be created are not reported.
+func NewRecorder(provider metric.MeterProvider) *Recorder {
+	if provider == nil {
+		provider = noop.NewMeterProvider()
+	}
+	meter := provider.Meter(InstrumentationName)
+	r := &Recorder{}
+	r.duration, _ = meter.Float64Histogram(
+		MetricDuration, metric.WithUnit("s"), metric.WithDescription("Duration of the prober calls"),
+	)
+	r.failures, _ = meter.Int64Counter(MetricErrors, metric.WithDescription("Failed prober calls by error type"))
+	r.documents, _ = meter.Int64Counter(MetricDocuments, metric.WithDescription("Documents found by the probers"))
+	return r
+}
+
+// RecordProbe records the metrics of a call to a prober: its latency, the
+// number of documents it found and, if it failed, the type of error.
+func (r *Recorder) RecordProbe(ctx context.Context, prober, purlType string, start time.Time, numDocs int, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	attrs := []attribute.KeyValue{AttrProber.String(prober), AttrPurlType.String(purlType)}
+
+	if r.duration != nil {
+		r.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+	}
+
+	if err != nil {
+		if r.failures != nil {
+			r.failures.Add(ctx, 1, metric.WithAttributes(append(attrs, AttrErrorType.String(ErrorType(err)))...))
+		}
+		return
+	}
+
+	if r.documents != nil {
+		r.documents.Add(ctx, int64(numDocs), metric.WithAttributes(attrs...))
+	}
+}
+
+// recorders caches the recorder of each meter provider
+var recorders sync.Map
+
+// recorder returns the recorder of the meter provider set in the options,
+// creating it the first time the provider is seen.
+func recorder(opts options.Options) *Recorder {
+	provider := meterProvider(opts)
+	// Providers that can't be map keys get a new recorder each time
+	if !reflect.TypeOf(provider).Comparable() {
+		return NewRecorder(provider)
+	}
+	if r, ok := recorders.Load(provider); ok {
+		return r.(*Recorder)
+	}
+	r, _ := recorders.LoadOrStore(provider, NewRecorder(provider))
+	return r.(*Recorder)
+}
+
+// RecordProbe records the metrics of a call to a prober with the recorder
+// of the meter provider set in the options, see Recorder.RecordProbe.
+func RecordProbe(opts options.Options, prober, purlType string, start time.Time, numDocs int, err error) {
+	recorder(opts).RecordProbe(opts.Context, prober, purlType, start, numDocs, err)
+}
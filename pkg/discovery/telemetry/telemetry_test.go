@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+func TestSpans(t *testing.T) {
+	// Without providers nothing is reported
+	opts, span := Start(options.Options{}, "noop")
+	require.NotNil(t, opts.Context)
+	require.False(t, span.IsRecording())
+	End(span, errors.New("ignored"))
+
+	recorder := tracetest.NewSpanRecorder()
+	opts = options.Options{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))}
+
+	parentOpts, parent := Start(opts, "parent", AttrPurl.String("pkg:oci/test"))
+	_, child := Start(parentOpts, "child")
+	End(child, errdefs.New(errdefs.ErrNotFound, "missing"))
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, "child", spans[0].Name())
+	require.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	require.Equal(t, codes.Error, spans[0].Status().Code)
+	require.Contains(t, spans[0].Attributes(), AttrErrorType.String("not_found"))
+	require.Equal(t, codes.Unset, spans[1].Status().Code)
+}
+
+func TestRecordProbe(t *testing.T) {
+	RecordProbe(options.Options{}, "noop", "oci", time.Now(), 1, nil)
+
+	reader := sdkmetric.NewManualReader()
+	opts := options.Options{MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))}
+
+	RecordProbe(opts, "a", "oci", time.Now(), 2, nil)
+	RecordProbe(opts, "a", "oci", time.Now(), 1, nil)
+	RecordProbe(opts, "b", "oci", time.Now(), 0, errdefs.New(errdefs.ErrNetwork, "rate limited"))
+	RecordProbe(opts, "b", "oci", time.Now(), 0, errors.New("plain"))
+
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	got := map[string]metricdata.Metrics{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		got[m.Name] = m
+	}
+
+	docs, ok := got[MetricDocuments].Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, docs.DataPoints, 1)
+	require.Equal(t, int64(3), docs.DataPoints[0].Value)
+
+	failures, ok := got[MetricErrors].Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	types := map[string]int64{}
+	for _, dp := range failures.DataPoints {
+		v, _ := dp.Attributes.Value(AttrErrorType)
+		types[v.AsString()] = dp.Value
+	}
+	require.Equal(t, map[string]int64{"network": 1, "unknown": 1}, types)
+
+	duration, ok := got[MetricDuration].Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, duration.DataPoints, 2)
+}
+
+// countingProvider counts the meters requested from a meter provider
+type countingProvider struct {
+	metric.MeterProvider
+	meters int
+}
+
+func (cp *countingProvider) Meter(name string, opts ...metric.MeterOption) metric.Meter {
+	cp.meters++
+	return cp.MeterProvider.Meter(name, opts...)
+}
+
+func TestRecorderInstruments(t *testing.T) {
+	provider := &countingProvider{MeterProvider: sdkmetric.NewMeterProvider()}
+	opts := options.Options{MeterProvider: provider}
+	for i := 0; i < 3; i++ {
+		RecordProbe(opts, "a", "oci", time.Now(), 1, nil)
+	}
+	require.Equal(t, 1, provider.meters)
+	require.Same(t, recorder(opts), recorder(opts))
+
+	// A nil provider gets a recorder that reports nothing
+	NewRecorder(nil).RecordProbe(context.Background(), "a", "oci", time.Now(), 0, errors.New("plain"))
+}
@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package validation checks the VEX documents downloaded by the probers
+// against the OpenVEX spec and the limits set on their size and shape.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/openvex/go-vex/pkg/vex"
+)
+
+// SupportedVersions are the OpenVEX spec versions accepted in documents
+var SupportedVersions = []string{"0.0.1", vex.SpecVersion}
+
+// Limits caps the size and shape of downloaded documents. Zero values
+// disable the limit.
+type Limits struct {
+	// MaxPayloadSize is the maximum size in bytes of a document payload
+	MaxPayloadSize int `yaml:"maxPayloadSize" json:"maxPayloadSize"`
+
+	// MaxStatements is the maximum number of statements in a document
+	MaxStatements int `yaml:"maxStatements" json:"maxStatements"`
+}
+
+// DefaultLimits are the limits used when none are specified
+var DefaultLimits = Limits{
+	MaxPayloadSize: 10 * 1024 * 1024,
+	MaxStatements:  10000,
+}
+
+// Validate checks the limit values
+func (l *Limits) Validate() error {
+	errs := []error{}
+	if l.MaxPayloadSize < 0 {
+		errs = append(errs, errors.New("max payload size must not be negative"))
+	}
+	if l.MaxStatements < 0 {
+		errs = append(errs, errors.New("max statements must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// CheckPayloadSize returns a problem if a payload exceeds the size limit
+func (l *Limits) CheckPayloadSize(size int) []string {
+	if l.MaxPayloadSize > 0 && size > l.MaxPayloadSize {
+		return []string{fmt.Sprintf("payload size %d exceeds the limit of %d bytes", size, l.MaxPayloadSize)}
+	}
+	return nil
+}
+
+// IsVEXPredicateType returns true if an in-toto predicate type is the
+// OpenVEX type, either unversioned or of a specific spec version.
+func IsVEXPredicateType(predicateType string) bool {
+	return predicateType == vex.TypeURI || strings.HasPrefix(predicateType, vex.TypeURI+"/v")
+}
+
+// specVersion returns the spec version in a document context. Unversioned
+// contexts return an empty string.
+func specVersion(context string) (string, bool) {
+	if context == vex.Context {
+		return "", true
+	}
+	v, ok := strings.CutPrefix(context, vex.Context+"/v")
+	return v, ok
+}
+
+// Document checks a VEX document against the OpenVEX spec and the limits.
+// It returns the problems found, an empty list means the document is valid.
+func Document(doc *vex.VEX, limits Limits) []string {
+	if doc == nil {
+		return []string{"document is empty"}
+	}
+
+	problems := []string{}
+	if version, ok := specVersion(doc.Context); !ok {
+		problems = append(problems, fmt.Sprintf("document context %q is not an OpenVEX context", doc.Context))
+	} else if version != "" && !supported(version) {
+		problems = append(problems, fmt.Sprintf("unsupported OpenVEX version %s", version))
+	}
+
+	if doc.ID == "" {
+		problems = append(problems, "document has no @id")
+	}
+	if doc.Author == "" {
+		problems = append(problems, "document has no author")
+	}
+	if doc.Timestamp == nil {
+		problems = append(problems, "document has no timestamp")
+	}
+
+	// Don't check the statements of documents over the limit
+	if limits.MaxStatements > 0 && len(doc.Statements) > limits.MaxStatements {
+		return append(problems, fmt.Sprintf(
+			"document has %d statements, the limit is %d", len(doc.Statements), limits.MaxStatements,
+		))
+	}
+
+	for i := range doc.Statements {
+		if doc.Statements[i].Vulnerability.Name == "" {
+			problems = append(problems, fmt.Sprintf("statement #%d has no vulnerability", i))
+		}
+		if err := doc.Statements[i].Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("statement #%d: %s", i, err))
+		}
+	}
+	return problems
+}
+
+// supported returns true if a spec version is supported
+func supported(version string) bool {
+	for _, v := range SupportedVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
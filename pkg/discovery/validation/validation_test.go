@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package validation
+
+import (
+	"testing"
+
+	"github.com/openvex/go-vex/pkg/vex"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocument(t *testing.T) {
+	newDoc := func(fn func(*vex.VEX)) *vex.VEX {
+		doc := vex.New()
+		doc.ID = "https://openvex.dev/docs/example/vex-1"
+		doc.Statements = []vex.Statement{
+			{Vulnerability: vex.Vulnerability{Name: "CVE-2023-1234"}, Status: vex.StatusFixed},
+		}
+		if fn != nil {
+			fn(&doc)
+		}
+		return &doc
+	}
+
+	for _, tc := range []struct {
+		name     string
+		doc      *vex.VEX
+		limits   Limits
+		problems int
+	}{
+		{"valid", newDoc(nil), DefaultLimits, 0},
+		{"nil document", nil, DefaultLimits, 1},
+		{"unversioned context", newDoc(func(d *vex.VEX) { d.Context = vex.Context }), DefaultLimits, 0},
+		{"old version", newDoc(func(d *vex.VEX) { d.Context = vex.Context + "/v0.0.1" }), DefaultLimits, 0},
+		{"unsupported version", newDoc(func(d *vex.VEX) { d.Context = vex.Context + "/v9.0.0" }), DefaultLimits, 1},
+		{"not openvex", newDoc(func(d *vex.VEX) { d.Context = "https://example.com/ns" }), DefaultLimits, 1},
+		{"missing metadata", newDoc(func(d *vex.VEX) { d.ID, d.Author, d.Timestamp = "", "", nil }), DefaultLimits, 3},
+		{"no vulnerability", newDoc(func(d *vex.VEX) { d.Statements[0].Vulnerability.Name = "" }), DefaultLimits, 1},
+		{"invalid statement", newDoc(func(d *vex.VEX) { d.Statements[0].Status = vex.StatusAffected }), DefaultLimits, 1},
+		{"too many statements", newDoc(func(d *vex.VEX) { d.Statements = append(d.Statements, d.Statements[0]) }), Limits{MaxStatements: 1}, 1},
+		{"no limits", newDoc(func(d *vex.VEX) { d.Statements = append(d.Statements, d.Statements[0]) }), Limits{}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Len(t, Document(tc.doc, tc.limits), tc.problems)
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	require.NoError(t, DefaultLimits.Validate())
+	require.Error(t, (&Limits{MaxPayloadSize: -1}).Validate())
+	require.Error(t, (&Limits{MaxStatements: -1}).Validate())
+
+	limits := Limits{MaxPayloadSize: 10}
+	require.Empty(t, limits.CheckPayloadSize(10))
+	require.Len(t, limits.CheckPayloadSize(11), 1)
+	require.Empty(t, (&Limits{}).CheckPayloadSize(1<<30))
+}
+
+func TestIsVEXPredicateType(t *testing.T) {
+	require.True(t, IsVEXPredicateType("https://openvex.dev/ns"))
+	require.True(t, IsVEXPredicateType("https://openvex.dev/ns/v0.2.0"))
+	require.False(t, IsVEXPredicateType("https://slsa.dev/provenance/v1"))
+	require.False(t, IsVEXPredicateType("https://openvex.dev/nsfoo"))
+}
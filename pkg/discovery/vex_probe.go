@@ -8,6 +8,8 @@ import (
 	purl "github.com/package-url/packageurl-go"
 
 	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/sbom"
 )
 
 // VexProbe abstracts a backend driver. The main goal of a probe is to
@@ -17,7 +19,24 @@ import (
 // The initial version of the VexProbe interface exposes a FindDocumentsFromPurl()
 // method that takes an options struct and a Package URL. The VEX probe captures
 // the logic to find VEX data for the purl type.
+//
+//counterfeiter:generate . VexProbe
 type VexProbe interface {
 	FindDocumentsFromPurl(options.Options, purl.PackageURL) ([]*vex.VEX, error)
 	SetOptions(options.Options)
 }
+
+// ProvenanceProbe is an optional interface that VexProbes can implement to
+// return the documents they find along with data about where they were found
+// and who signed them. The agent uses it to evaluate its trust policy.
+type ProvenanceProbe interface {
+	FindDocumentsWithProvenance(options.Options, purl.PackageURL) ([]*results.Document, error)
+}
+
+// SBOMProbe is an optional interface that VexProbes can implement to read
+// the SBOMs of a package, for example the SBOMs attested to an image. The
+// agent uses it to probe the components of images and to follow the VEX
+// documents linked from their SBOMs.
+type SBOMProbe interface {
+	FindSBOM(options.Options, purl.PackageURL) (*sbom.Contents, error)
+}
@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package errdefs defines the kinds of errors returned by the discovery agent
+// and its probers. Errors can be checked with errors.Is against the sentinel
+// errors in this package:
+//
+//	if errors.Is(err, errdefs.ErrNotFound) {
+//		...
+//	}
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnsupportedPurlType is returned when no prober handles a purl type
+	ErrUnsupportedPurlType = errors.New("unsupported purl type")
+
+	// ErrNotFound is returned when the probed package does not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrUnauthorized is returned when the credentials to access a
+	// repository are missing or were rejected
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrNetwork is returned when a remote service could not be reached,
+	// rate limited the client or failed with a server error
+	ErrNetwork = errors.New("network error")
+
+	// ErrParse is returned when an identifier or document is malformed
+	ErrParse = errors.New("parse error")
+
+	// ErrVerificationFailed is returned when a document or its signature
+	// could not be verified
+	ErrVerificationFailed = errors.New("verification failed")
+
+	// ErrInvalidArgument is returned when the options of a request don't
+	// apply to the probed artifact, eg selecting the platform of a single
+	// arch image
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// kinds lists the error kinds with the names used to serialize them
+var kinds = []struct {
+	name string
+	kind error
+}{
+	{"unsupported_purl_type", ErrUnsupportedPurlType},
+	{"not_found", ErrNotFound},
+	{"unauthorized", ErrUnauthorized},
+	{"network", ErrNetwork},
+	{"parse", ErrParse},
+	{"verification_failed", ErrVerificationFailed},
+	{"invalid_argument", ErrInvalidArgument},
+}
+
+// Error is an error of a known kind. It keeps the message of the error that
+// caused it and matches both the kind and the cause with errors.Is/As.
+type Error struct {
+	Kind error
+	Err  error
+}
+
+// Error returns the message of the underlying error
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Kind.Error()
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the kind and the underlying error
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// New returns a new error of a kind, formatted as fmt.Errorf
+func New(kind error, format string, args ...any) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// Wrap marks an error as being of a kind. It returns nil if err is nil.
+func Wrap(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// KindName returns the name of the kind of an error, eg "not_found". It
+// returns an empty string if the error is not of a known kind.
+func KindName(err error) string {
+	for _, k := range kinds {
+		if errors.Is(err, k.kind) {
+			return k.name
+		}
+	}
+	return ""
+}
+
+// FromKindName returns an error of the named kind with a message. If the
+// name is not a known kind, it returns a plain error.
+func FromKindName(name, message string) error {
+	for _, k := range kinds {
+		if k.name == name {
+			return &Error{Kind: k.kind, Err: errors.New(message)}
+		}
+	}
+	return errors.New(message)
+}
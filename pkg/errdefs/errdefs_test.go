@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestError(t *testing.T) {
+	err := New(ErrNotFound, "fetching image: %w", io.EOF)
+	require.Equal(t, "fetching image: EOF", err.Error())
+	require.ErrorIs(t, err, ErrNotFound)
+	require.ErrorIs(t, err, io.EOF)
+	require.NotErrorIs(t, err, ErrNetwork)
+
+	var e *Error
+	require.ErrorAs(t, fmt.Errorf("probing: %w", err), &e)
+	require.Equal(t, ErrNotFound, e.Kind)
+
+	require.NoError(t, Wrap(ErrParse, nil))
+	require.ErrorIs(t, Wrap(ErrParse, io.EOF), ErrParse)
+	require.Equal(t, "unauthorized", (&Error{Kind: ErrUnauthorized}).Error())
+}
+
+func TestKindName(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		kind string
+	}{
+		{"nil", nil, ""},
+		{"plain error", errors.New("plain"), ""},
+		{"sentinel", ErrNetwork, "network"},
+		{"invalid argument", New(ErrInvalidArgument, "not an index"), "invalid_argument"},
+		{"wrapped", fmt.Errorf("probing: %w", New(ErrVerificationFailed, "bad digest")), "verification_failed"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.kind, KindName(tc.err))
+		})
+	}
+}
+
+func TestFromKindName(t *testing.T) {
+	err := FromKindName("unsupported_purl_type", "no prober")
+	require.ErrorIs(t, err, ErrUnsupportedPurlType)
+	require.Equal(t, "no prober", err.Error())
+
+	err = FromKindName("unknown", "plain")
+	require.Equal(t, "", KindName(err))
+	require.Equal(t, "plain", err.Error())
+}
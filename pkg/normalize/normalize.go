@@ -0,0 +1,204 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package normalize writes package URLs in a canonical form and decides when
+// two of them refer to the same software. On top of the rules of the purl
+// spec applied by the purl parser, such as the order of the qualifiers or the
+// encoding of the version, it applies rules for each purl type. Oci purls get
+// their repository_url written as in pkg/oci.
+package normalize
+
+import (
+	"strings"
+
+	"github.com/openvex/go-vex/pkg/vex"
+	purl "github.com/package-url/packageurl-go"
+
+	"github.com/openvex/discovery/pkg/errdefs"
+	doci "github.com/openvex/discovery/pkg/oci"
+)
+
+// dockerHubRegistries are the repository urls of Docker Hub, the default
+// registry of docker purls
+var dockerHubRegistries = []string{
+	"docker.io", "index.docker.io", "registry-1.docker.io", "hub.docker.com",
+}
+
+// typeRules are the normalization rules applied to each purl type
+var typeRules = map[string]func(*purl.PackageURL){
+	purl.TypeOCI:    normalizeOCI,
+	purl.TypeDocker: normalizeDocker,
+}
+
+// Purl parses a purl string and returns its canonical form
+func Purl(purlString string) (string, error) {
+	p, err := purl.FromString(purlString)
+	if err != nil {
+		return "", errdefs.New(errdefs.ErrParse, "parsing purl: %w", err)
+	}
+	p, err = PackageURL(p)
+	if err != nil {
+		return "", err
+	}
+	return p.String(), nil
+}
+
+// PackageURL returns the canonical form of a purl
+func PackageURL(p purl.PackageURL) (purl.PackageURL, error) {
+	p.Qualifiers = append(purl.Qualifiers{}, p.Qualifiers...)
+	if err := p.Normalize(); err != nil {
+		return purl.PackageURL{}, errdefs.New(errdefs.ErrParse, "normalizing purl: %w", err)
+	}
+
+	for i := range p.Qualifiers {
+		if p.Qualifiers[i].Key == "repository_url" {
+			p.Qualifiers[i].Value = strings.TrimRight(p.Qualifiers[i].Value, "/")
+		}
+	}
+
+	if rule, ok := typeRules[p.Type]; ok {
+		rule(&p)
+	}
+
+	// Rules may empty qualifiers, normalize again to drop them
+	if err := p.Qualifiers.Normalize(); err != nil {
+		return purl.PackageURL{}, errdefs.New(errdefs.ErrParse, "normalizing purl: %w", err)
+	}
+	return p, nil
+}
+
+// normalizeOCI applies the oci rules: names and digests are lowercase and
+// the repository_url is written as by oci.ReferenceToPurl.
+func normalizeOCI(p *purl.PackageURL) {
+	p.Name = strings.ToLower(p.Name)
+	p.Version = strings.ToLower(p.Version)
+	for i := range p.Qualifiers {
+		if p.Qualifiers[i].Key == "repository_url" {
+			p.Qualifiers[i].Value = doci.NormalizeRepositoryURL(p.Qualifiers[i].Value, p.Name)
+		}
+	}
+}
+
+// normalizeDocker applies the docker rules: images in Docker Hub have no
+// repository_url and the official images are in the library namespace.
+func normalizeDocker(p *purl.PackageURL) {
+	p.Name = strings.ToLower(p.Name)
+	hub := true
+	for i := range p.Qualifiers {
+		if p.Qualifiers[i].Key != "repository_url" {
+			continue
+		}
+		repo := p.Qualifiers[i].Value
+		if _, rest, ok := strings.Cut(repo, "://"); ok {
+			repo = rest
+		}
+		hub = isDockerHub(repo)
+		if hub {
+			p.Qualifiers[i].Value = ""
+		}
+	}
+	if hub && p.Namespace == "" {
+		p.Namespace = "library"
+	}
+}
+
+// isDockerHub returns true if a registry is Docker Hub
+func isDockerHub(registry string) bool {
+	for _, r := range dockerHubRegistries {
+		if strings.EqualFold(registry, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Equivalent returns true if two purls are the same once normalized. Purls
+// that don't parse are not equivalent to any other.
+func Equivalent(purl1, purl2 string) bool {
+	p1, err := Purl(purl1)
+	if err != nil {
+		return false
+	}
+	p2, err := Purl(purl2)
+	if err != nil {
+		return false
+	}
+	return p1 == p2
+}
+
+// Matches returns true if a purl matches a pattern purl once both are
+// normalized. Patterns match from generic to specific: a pattern without
+// version matches all versions and the qualifiers of the pattern must be in
+// the purl, but the purl can have more. The repository_url of oci purls is
+// always compared, a purl without it refers to an image in Docker Hub.
+func Matches(pattern, purlString string) bool {
+	pp, err := purl.FromString(pattern)
+	if err != nil {
+		return false
+	}
+	if pp, err = PackageURL(pp); err != nil {
+		return false
+	}
+	p, err := purl.FromString(purlString)
+	if err != nil {
+		return false
+	}
+	if p, err = PackageURL(p); err != nil {
+		return false
+	}
+
+	if pp.Type != p.Type || pp.Namespace != p.Namespace || pp.Name != p.Name {
+		return false
+	}
+	if pp.Version != "" && pp.Version != p.Version {
+		return false
+	}
+	if pp.Subpath != "" && pp.Subpath != p.Subpath {
+		return false
+	}
+
+	if pp.Type == purl.TypeOCI && ociRepository(pp) != ociRepository(p) {
+		return false
+	}
+
+	qualifiers := p.Qualifiers.Map()
+	for k, v := range pp.Qualifiers.Map() {
+		if pp.Type == purl.TypeOCI && k == "repository_url" {
+			continue
+		}
+		if pv, ok := qualifiers[k]; !ok || pv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ociRepository returns the normalized repository_url of an oci purl. Purls
+// without one get the Docker Hub repository, as references without registry.
+func ociRepository(p purl.PackageURL) string {
+	if repo, ok := p.Qualifiers.Map()["repository_url"]; ok {
+		return repo
+	}
+	return doci.NormalizeRepositoryURL(dockerHubRegistries[0], p.Name)
+}
+
+// MatchesComponent returns true if a VEX component refers to an identifier.
+// Purls are compared with Matches instead of the component matching of
+// go-vex, which lets a purl without repository_url match images from any
+// registry.
+func MatchesComponent(c *vex.Component, identifier string) bool {
+	if c == nil {
+		return false
+	}
+	if !strings.HasPrefix(identifier, "pkg:") {
+		return c.Matches(identifier)
+	}
+
+	if c.ID == identifier || (strings.HasPrefix(c.ID, "pkg:") && Matches(c.ID, identifier)) {
+		return true
+	}
+	if id, ok := c.Identifiers[vex.PURL]; ok && (id == identifier || Matches(id, identifier)) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package normalize
+
+import (
+	"testing"
+
+	"github.com/openvex/go-vex/pkg/vex"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+func TestPurl(t *testing.T) {
+	digest := "sha256:47fed8868b46b060efb8699dc40e981a0c785650223e03602d8c4493fc75b68c"
+	encDigest := "sha256%3A47fed8868b46b060efb8699dc40e981a0c785650223e03602d8c4493fc75b68c"
+	for _, tc := range []struct {
+		name     string
+		input    string
+		expected string
+		mustErr  bool
+	}{
+		{
+			name:     "qualifier order",
+			input:    "pkg:oci/curl?tag=latest&repository_url=cgr.dev%2Fchainguard",
+			expected: "pkg:oci/curl?repository_url=cgr.dev%2Fchainguard&tag=latest",
+		},
+		{
+			name:     "unencoded digest",
+			input:    "pkg:oci/curl@" + digest + "?repository_url=cgr.dev/chainguard",
+			expected: "pkg:oci/curl@" + encDigest + "?repository_url=cgr.dev%2Fchainguard",
+		},
+		{
+			name:     "uppercase digest",
+			input:    "pkg:oci/curl@SHA256:47FED8868B46B060EFB8699DC40E981A0C785650223E03602D8C4493FC75B68C",
+			expected: "pkg:oci/curl@" + encDigest,
+		},
+		{
+			name:     "trailing slash in repository",
+			input:    "pkg:oci/curl?repository_url=cgr.dev%2Fchainguard%2F",
+			expected: "pkg:oci/curl?repository_url=cgr.dev%2Fchainguard",
+		},
+		{
+			name:     "repository with scheme",
+			input:    "pkg:oci/curl?repository_url=https%3A%2F%2FCGR.dev%2Fchainguard",
+			expected: "pkg:oci/curl?repository_url=cgr.dev%2Fchainguard",
+		},
+		{
+			name:     "docker hub repository",
+			input:    "pkg:oci/alpine?repository_url=docker.io",
+			expected: "pkg:oci/alpine?repository_url=index.docker.io%2Flibrary",
+		},
+		{
+			name:     "docker hub user repository",
+			input:    "pkg:oci/vexctl?repository_url=docker.io%2Fopenvex%2F",
+			expected: "pkg:oci/vexctl?repository_url=index.docker.io%2Fopenvex",
+		},
+		{
+			name:     "oci without repository",
+			input:    "pkg:oci/Alpine@" + digest,
+			expected: "pkg:oci/alpine@" + encDigest,
+		},
+		{
+			name:     "empty qualifiers",
+			input:    "pkg:oci/alpine?arch=&repository_url=docker.io",
+			expected: "pkg:oci/alpine?repository_url=index.docker.io%2Flibrary",
+		},
+		{
+			name:     "docker default namespace",
+			input:    "pkg:docker/alpine@3.18",
+			expected: "pkg:docker/library/alpine@3.18",
+		},
+		{
+			name:     "docker hub repository url",
+			input:    "pkg:docker/openvex/vexctl?repository_url=https%3A%2F%2Fhub.docker.com%2F",
+			expected: "pkg:docker/openvex/vexctl",
+		},
+		{
+			name:     "docker other registry",
+			input:    "pkg:docker/vexctl?repository_url=ghcr.io%2Fopenvex",
+			expected: "pkg:docker/vexctl?repository_url=ghcr.io%2Fopenvex",
+		},
+		{
+			name:     "pypi name",
+			input:    "pkg:pypi/Django_Rest@1.0",
+			expected: "pkg:pypi/django-rest@1.0",
+		},
+		{
+			name:     "maven repository url",
+			input:    "pkg:maven/org.apache/commons@1.0?repository_url=https%3A%2F%2Frepo.example.com%2F",
+			expected: "pkg:maven/org.apache/commons@1.0?repository_url=https%3A%2F%2Frepo.example.com",
+		},
+		{
+			name:    "invalid purl",
+			input:   "not a purl",
+			mustErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := Purl(tc.input)
+			if tc.mustErr {
+				require.ErrorIs(t, err, errdefs.ErrParse)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, res)
+
+			// Normalizing is idempotent
+			again, err := Purl(res)
+			require.NoError(t, err)
+			require.Equal(t, res, again)
+		})
+	}
+}
+
+func TestEquivalent(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		purl1    string
+		purl2    string
+		expected bool
+	}{
+		{"same", "pkg:oci/alpine", "pkg:oci/alpine", true},
+		{"docker hub aliases", "pkg:oci/alpine?repository_url=docker.io", "pkg:oci/alpine?repository_url=index.docker.io/library/", true},
+		{"encoded digest", "pkg:oci/alpine@sha256%3Aabc", "pkg:oci/alpine@sha256:abc", true},
+		{"different version", "pkg:oci/alpine@sha256:abc", "pkg:oci/alpine@sha256:def", false},
+		{"missing qualifier", "pkg:oci/alpine?tag=latest", "pkg:oci/alpine", false},
+		{"different type", "pkg:oci/alpine", "pkg:docker/alpine", false},
+		{"invalid", "pkg:oci/alpine", "invalid", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, Equivalent(tc.purl1, tc.purl2))
+			require.Equal(t, tc.expected, Equivalent(tc.purl2, tc.purl1))
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		pattern  string
+		purl     string
+		expected bool
+	}{
+		{"generic matches specific", "pkg:oci/alpine", "pkg:oci/alpine@sha256:abc?arch=amd64", true},
+		{"specific does not match generic", "pkg:oci/alpine@sha256:abc", "pkg:oci/alpine", false},
+		{"normalized repository", "pkg:oci/alpine?repository_url=docker.io", "pkg:oci/alpine@sha256%3Aabc?repository_url=index.docker.io%2Flibrary&tag=3.18", true},
+		{"different repository", "pkg:oci/alpine?repository_url=ghcr.io%2Fchainguard", "pkg:oci/alpine?repository_url=docker.io", false},
+		{"missing repository is docker hub", "pkg:oci/alpine", "pkg:oci/alpine@sha256:abc?repository_url=index.docker.io%2Flibrary", true},
+		{"missing repository in purl", "pkg:oci/alpine?repository_url=docker.io", "pkg:oci/alpine@sha256:abc", true},
+		{"missing repository does not match other registry", "pkg:oci/alpine", "pkg:oci/alpine?repository_url=evil.example.com", false},
+		{"qualifier value differs", "pkg:oci/alpine?arch=arm64", "pkg:oci/alpine?arch=amd64", false},
+		{"subpath", "pkg:golang/example.com/mod#cmd", "pkg:golang/example.com/mod@v1#cmd", true},
+		{"other subpath", "pkg:golang/example.com/mod#cmd", "pkg:golang/example.com/mod@v1#lib", false},
+		{"invalid pattern", "invalid", "pkg:oci/alpine", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, Matches(tc.pattern, tc.purl))
+		})
+	}
+}
+
+func TestMatchesComponent(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		component  *vex.Component
+		identifier string
+		expected   bool
+	}{
+		{
+			"purl id",
+			&vex.Component{ID: "pkg:oci/alpine?repository_url=docker.io%2F"},
+			"pkg:oci/alpine@sha256:abc?repository_url=index.docker.io%2Flibrary",
+			true,
+		},
+		{
+			"purl identifier",
+			&vex.Component{Identifiers: map[vex.IdentifierType]string{vex.PURL: "pkg:oci/alpine@sha256%3AABC"}},
+			"pkg:oci/alpine@sha256:abc",
+			true,
+		},
+		{
+			"non purl id",
+			&vex.Component{ID: "https://example.com/component"},
+			"https://example.com/component",
+			true,
+		},
+		{
+			"hash",
+			&vex.Component{Hashes: map[vex.Algorithm]vex.Hash{vex.SHA256: "abc"}},
+			"abc",
+			true,
+		},
+		{
+			"purl id from other registry",
+			&vex.Component{ID: "pkg:oci/alpine"},
+			"pkg:oci/alpine@sha256:abc?repository_url=evil.example.com",
+			false,
+		},
+		{
+			"purl identifier from other registry",
+			&vex.Component{Identifiers: map[vex.IdentifierType]string{vex.PURL: "pkg:oci/alpine"}},
+			"pkg:oci/alpine?repository_url=evil.example.com",
+			false,
+		},
+		{
+			"other purl",
+			&vex.Component{ID: "pkg:oci/nginx"},
+			"pkg:oci/alpine",
+			false,
+		},
+		{"nil component", nil, "pkg:oci/alpine", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, MatchesComponent(tc.component, tc.identifier))
+		})
+	}
+}
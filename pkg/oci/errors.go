@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+// ClassifyError marks an error returned by a registry call with its kind
+// from the errdefs package. Errors of unknown kind are returned unchanged.
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	// Errors already classified and canceled calls are not modified
+	if errdefs.KindName(err) != "" || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	var enf *ociremote.EntityNotFoundError
+	if errors.As(err, &enf) || errors.Is(err, ociremote.ErrImageNotFound) {
+		return errdefs.Wrap(errdefs.ErrNotFound, err)
+	}
+
+	var te *transport.Error
+	if errors.As(err, &te) {
+		switch {
+		case te.StatusCode == http.StatusUnauthorized || te.StatusCode == http.StatusForbidden:
+			return errdefs.Wrap(errdefs.ErrUnauthorized, err)
+		case te.StatusCode == http.StatusNotFound:
+			return errdefs.Wrap(errdefs.ErrNotFound, err)
+		case te.StatusCode == http.StatusTooManyRequests || te.StatusCode >= http.StatusInternalServerError:
+			return errdefs.Wrap(errdefs.ErrNetwork, err)
+		}
+		for _, d := range te.Errors {
+			switch d.Code {
+			case transport.UnauthorizedErrorCode, transport.DeniedErrorCode:
+				return errdefs.Wrap(errdefs.ErrUnauthorized, err)
+			case transport.ManifestUnknownErrorCode, transport.NameUnknownErrorCode, transport.BlobUnknownErrorCode:
+				return errdefs.Wrap(errdefs.ErrNotFound, err)
+			case transport.TooManyRequestsErrorCode, transport.UnavailableErrorCode:
+				return errdefs.Wrap(errdefs.ErrNetwork, err)
+			}
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return errdefs.Wrap(errdefs.ErrNetwork, err)
+	}
+	return err
+}
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/internal/testregistry"
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+func TestClassifyError(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{"unauthorized", &transport.Error{StatusCode: http.StatusUnauthorized}, errdefs.ErrUnauthorized},
+		{"forbidden", &transport.Error{StatusCode: http.StatusForbidden}, errdefs.ErrUnauthorized},
+		{"not found", &transport.Error{StatusCode: http.StatusNotFound}, errdefs.ErrNotFound},
+		{"rate limited", &transport.Error{StatusCode: http.StatusTooManyRequests}, errdefs.ErrNetwork},
+		{"server error", &transport.Error{StatusCode: http.StatusBadGateway}, errdefs.ErrNetwork},
+		{
+			"diagnostic code",
+			&transport.Error{StatusCode: http.StatusBadRequest, Errors: []transport.Diagnostic{{Code: transport.ManifestUnknownErrorCode}}},
+			errdefs.ErrNotFound,
+		},
+		{"wrapped", fmt.Errorf("fetching: %w", &transport.Error{StatusCode: http.StatusNotFound}), errdefs.ErrNotFound},
+		{"already classified", errdefs.New(errdefs.ErrParse, "bad"), errdefs.ErrParse},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ClassifyError(tc.err)
+			require.ErrorIs(t, err, tc.kind)
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	require.NoError(t, ClassifyError(nil))
+	plain := errors.New("plain")
+	require.Equal(t, plain, ClassifyError(plain))
+	require.Equal(t, "", errdefs.KindName(ClassifyError(context.Canceled)))
+}
+
+func TestGenerateReferenceIdentifiersErrors(t *testing.T) {
+	reg := testregistry.New(t)
+	noRetries := WithRetryPolicy(RetryPolicy{})
+	for _, tc := range []struct {
+		name string
+		ref  string
+		kind error
+	}{
+		{"invalid reference", "Not A Reference", errdefs.ErrParse},
+		{"missing image", reg.Ref("missing:latest"), errdefs.ErrNotFound},
+		{"unreachable registry", "127.0.0.1:1/image:latest", errdefs.ErrNetwork},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := GenerateReferenceIdentifiers(tc.ref, "", "", noRetries)
+			require.ErrorIs(t, err, tc.kind)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+// Mirror is a rewrite rule that maps a registry prefix to a mirror. Any
+// repository under Prefix is looked up in Mirror, keeping the rest of its
+// path. For example, with this rule:
+//
+//	Mirror{Prefix: "docker.io/library", Mirror: "mirror.example.com/hub"}
+//
+// docker.io/library/alpine:3.18 is rewritten to mirror.example.com/hub/alpine:3.18
+type Mirror struct {
+	Prefix string `yaml:"prefix" json:"prefix"`
+	Mirror string `yaml:"mirror" json:"mirror"`
+}
+
+// Validate checks the rule is well formed
+func (m *Mirror) Validate() error {
+	errs := []error{}
+	if m.Prefix == "" {
+		errs = append(errs, errors.New("mirror rule has no prefix"))
+	} else if _, err := normalizePrefix(m.Prefix); err != nil {
+		errs = append(errs, fmt.Errorf("invalid mirror prefix %q: %w", m.Prefix, err))
+	}
+	if m.Mirror == "" {
+		errs = append(errs, errors.New("mirror rule has no mirror"))
+	} else if strings.Contains(m.Mirror, "://") {
+		errs = append(errs, fmt.Errorf("invalid mirror %q: mirrors must not include a scheme", m.Mirror))
+	} else if _, err := name.NewRepository(strings.TrimSuffix(m.Mirror, "/") + "/test"); err != nil {
+		errs = append(errs, fmt.Errorf("invalid mirror %q: %w", m.Mirror, err))
+	}
+	return errors.Join(errs...)
+}
+
+// normalizePrefix returns the prefix with the registry part in the same form
+// GGCR uses in parsed references, eg docker.io becomes index.docker.io
+func normalizePrefix(prefix string) (string, error) {
+	if strings.Contains(prefix, "://") {
+		return "", errors.New("prefixes must not include a scheme")
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	host, path, _ := strings.Cut(prefix, "/")
+	reg, err := name.NewRegistry(host)
+	if err != nil {
+		return "", err
+	}
+	if path == "" {
+		return reg.Name(), nil
+	}
+	return reg.Name() + "/" + path, nil
+}
+
+// rewrite returns the names a repository maps to in the mirrors, in the
+// order of the rules. The original repository is not included.
+func rewrite(repo string, mirrors []Mirror) ([]string, error) {
+	ret := []string{}
+	for i := range mirrors {
+		prefix, err := normalizePrefix(mirrors[i].Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mirror prefix %q: %w", mirrors[i].Prefix, err)
+		}
+		if repo != prefix && !strings.HasPrefix(repo, prefix+"/") {
+			continue
+		}
+		ret = append(ret, strings.TrimSuffix(mirrors[i].Mirror, "/")+strings.TrimPrefix(repo, prefix))
+	}
+	return ret, nil
+}
+
+// RewriteReference applies the mirror rules to an image reference. It returns
+// the references to try in order: one for each matching rule followed by the
+// original reference.
+func RewriteReference(refString string, mirrors []Mirror) ([]string, error) {
+	ref, err := name.ParseReference(refString)
+	if err != nil {
+		return nil, errdefs.New(errdefs.ErrParse, "parsing reference: %w", err)
+	}
+
+	var suffix string
+	switch r := ref.(type) {
+	case name.Digest:
+		suffix = "@" + r.DigestStr()
+	case name.Tag:
+		suffix = ":" + r.TagStr()
+	}
+
+	repos, err := rewrite(ref.Context().Name(), mirrors)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]string, 0, len(repos)+1)
+	for _, repo := range repos {
+		ret = append(ret, repo+suffix)
+	}
+	return append(ret, refString), nil
+}
+
+// RewriteRepository applies the mirror rules to a repository. It returns the
+// repositories to try in order: one for each matching rule followed by the
+// original repository.
+func RewriteRepository(repoString string, mirrors []Mirror) ([]string, error) {
+	repo, err := name.NewRepository(repoString)
+	if err != nil {
+		return nil, errdefs.New(errdefs.ErrParse, "parsing repository: %w", err)
+	}
+
+	ret, err := rewrite(repo.Name(), mirrors)
+	if err != nil {
+		return nil, err
+	}
+	return append(ret, repoString), nil
+}
@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteReference(t *testing.T) {
+	mirrors := []Mirror{
+		{Prefix: "docker.io/library", Mirror: "mirror.example.com/hub"},
+		{Prefix: "docker.io", Mirror: "backup.example.com/dockerhub/"},
+		{Prefix: "cgr.dev/chainguard", Mirror: "localhost:5000"},
+	}
+	for _, tc := range []struct {
+		name     string
+		ref      string
+		expected []string
+		mustErr  bool
+	}{
+		{
+			"short name",
+			"alpine:3.18",
+			[]string{"mirror.example.com/hub/alpine:3.18", "backup.example.com/dockerhub/library/alpine:3.18", "alpine:3.18"},
+			false,
+		},
+		{
+			"digest",
+			"cgr.dev/chainguard/curl@sha256:47fed8868b46b060efb8699dc40e981a0c785650223e03602d8c4493fc75b68c",
+			[]string{
+				"localhost:5000/curl@sha256:47fed8868b46b060efb8699dc40e981a0c785650223e03602d8c4493fc75b68c",
+				"cgr.dev/chainguard/curl@sha256:47fed8868b46b060efb8699dc40e981a0c785650223e03602d8c4493fc75b68c",
+			},
+			false,
+		},
+		{
+			"prefix matches path components only",
+			"cgr.dev/chainguard-private/curl:latest",
+			[]string{"cgr.dev/chainguard-private/curl:latest"},
+			false,
+		},
+		{"no match", "registry.k8s.io/pause:3.9", []string{"registry.k8s.io/pause:3.9"}, false},
+		{"invalid", "Hello !", nil, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			refs, err := RewriteReference(tc.ref, mirrors)
+			if tc.mustErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, refs)
+		})
+	}
+}
+
+func TestRewriteRepository(t *testing.T) {
+	repos, err := RewriteRepository(
+		"ghcr.io/example/signatures",
+		[]Mirror{{Prefix: "ghcr.io/example", Mirror: "mirror.example.com/ghcr"}},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"mirror.example.com/ghcr/signatures", "ghcr.io/example/signatures"}, repos)
+}
+
+func TestMirrorValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		mirror  Mirror
+		mustErr bool
+	}{
+		{"valid", Mirror{Prefix: "docker.io", Mirror: "mirror.example.com/hub"}, false},
+		{"no prefix", Mirror{Mirror: "mirror.example.com/hub"}, true},
+		{"no mirror", Mirror{Prefix: "docker.io"}, true},
+		{"invalid mirror", Mirror{Prefix: "docker.io", Mirror: "https://mirror.example.com"}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.mirror.Validate()
+			if tc.mustErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -4,8 +4,13 @@
 package oci
 
 import (
-	"errors"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
+	"hash"
+	"path"
 	"sort"
 	"strings"
 
@@ -14,6 +19,8 @@ import (
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/openvex/go-vex/pkg/vex"
 	purl "github.com/package-url/packageurl-go"
+
+	"github.com/openvex/discovery/pkg/errdefs"
 )
 
 // IdentifiersBundle is a struct that collects different software identifiers
@@ -21,10 +28,15 @@ import (
 type IdentifiersBundle struct {
 	Identifiers map[vex.IdentifierType][]string
 	Hashes      map[vex.Algorithm][]vex.Hash
+
+	// Contents has the SHA256 digests of the image configs and layers when
+	// requested with WithImageContents. Layers are shared by many images, so
+	// the contents are not identifiers of the image and are not matched.
+	Contents []vex.Hash
 }
 
 // ToStringSlice returns all the identifiers and hashes contained in the bundle
-// in a flat string slice
+// in a flat string slice. The digests of the image contents are not included.
 func (bundle *IdentifiersBundle) ToStringSlice() []string {
 	ret := []string{}
 	if bundle.Identifiers != nil {
@@ -47,6 +59,123 @@ func (bundle *IdentifiersBundle) ToStringSlice() []string {
 	return ret
 }
 
+// Match returns true if a VEX product refers to the image in the bundle. The
+// product matches if its ID or one of its identifiers matches a bundle
+// identifier or if it has a hash listed in the bundle for the same algorithm.
+// Purls are matched from generic to specific, a product purl without digest
+// or qualifiers matches the purls of the image.
+func (bundle *IdentifiersBundle) Match(product *vex.Product) bool {
+	if product == nil {
+		return false
+	}
+
+	for _, ids := range bundle.Identifiers {
+		for _, id := range ids {
+			if product.Component.Matches(id) {
+				return true
+			}
+		}
+	}
+
+	for algo, h := range product.Hashes {
+		for _, bh := range bundle.Hashes[algo] {
+			if strings.EqualFold(string(h), string(bh)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type identifierOptions struct {
+	// RetryPolicy controls how the registry calls are retried
+	RetryPolicy RetryPolicy
+
+	// RetryCounter counts the retried registry calls
+	RetryCounter *RetryCounter
+
+	// HashAlgorithms are the algorithms used to hash the manifests, in
+	// addition to the SHA256 digests
+	HashAlgorithms []vex.Algorithm
+
+	// ImageContents adds the digests of the image configs and layers
+	ImageContents bool
+
+	// TagPurls adds purls without digest when the reference has a tag
+	TagPurls bool
+}
+
+type IdentifierOptions func(*identifierOptions)
+
+// WithRetryPolicy sets the policy to retry registry calls
+func WithRetryPolicy(policy RetryPolicy) IdentifierOptions {
+	return func(opts *identifierOptions) {
+		opts.RetryPolicy = policy
+	}
+}
+
+// WithRetryCounter sets a counter to record the retried registry calls
+func WithRetryCounter(counter *RetryCounter) IdentifierOptions {
+	return func(opts *identifierOptions) {
+		opts.RetryCounter = counter
+	}
+}
+
+// WithHashAlgorithms adds hashes of the image manifests computed with more
+// algorithms. SHA256 hashes are always included as they are the digests
+// of the images. Supported algorithms are SHA256, SHA384 and SHA512.
+func WithHashAlgorithms(algorithms ...vex.Algorithm) IdentifierOptions {
+	return func(opts *identifierOptions) {
+		opts.HashAlgorithms = algorithms
+	}
+}
+
+// WithImageContents records the SHA256 digests of the config and layers of
+// the images in the bundle contents.
+func WithImageContents() IdentifierOptions {
+	return func(opts *identifierOptions) {
+		opts.ImageContents = true
+	}
+}
+
+// WithTagPurls adds purls with the tag of the reference and no digest, to
+// match VEX documents that refer to images by tag.
+func WithTagPurls() IdentifierOptions {
+	return func(opts *identifierOptions) {
+		opts.TagPurls = true
+	}
+}
+
+// hashers are the functions to hash manifests with the supported algorithms
+var hashers = map[vex.Algorithm]func() hash.Hash{
+	vex.SHA256: sha256.New,
+	vex.SHA384: sha512.New384,
+	vex.SHA512: sha512.New,
+}
+
+// newIdentifierOptions applies the functional options to the defaults
+// and checks the result
+func newIdentifierOptions(fopts []IdentifierOptions) (*identifierOptions, error) {
+	opts := &identifierOptions{
+		RetryPolicy: DefaultRetryPolicy,
+	}
+	for _, opt := range fopts {
+		opt(opts)
+	}
+	for _, algo := range opts.HashAlgorithms {
+		if _, ok := hashers[algo]; !ok {
+			return nil, fmt.Errorf("unsupported hash algorithm %q", algo)
+		}
+	}
+	return opts, nil
+}
+
+// needsManifests returns true if the options require reading the image
+// manifests, not only their digests
+func (opts *identifierOptions) needsManifests() bool {
+	return opts.ImageContents || len(opts.HashAlgorithms) > 0
+}
+
 // GenerateReferenceIdentifiers reads an image reference string and
 // generates a list of identifiers that can be used to match an entry
 // in VEX a  document.
@@ -59,83 +188,240 @@ func (bundle *IdentifiersBundle) ToStringSlice() []string {
 // For each image, the returned bundle will include a SHA256 hash with
 // the image digest and two purls, with and without qualifiers. The
 // variant with qualifiers will contain all the data known from the
-// registry to match VEX documents with more specific purls.
+// registry to match VEX documents with more specific purls. More
+// identifiers can be added with WithHashAlgorithms, WithImageContents
+// and WithTagPurls.
 //
 // This function performs calls to the registry to retrieve data such
-// as the image digests when needed.
-func GenerateReferenceIdentifiers(refString, os, arch string) (IdentifiersBundle, error) {
-	var dString, tag string
-	bundle := IdentifiersBundle{
-		Identifiers: map[vex.IdentifierType][]string{vex.PURL: {}},
-		Hashes:      map[vex.Algorithm][]vex.Hash{vex.SHA256: {}},
+// as the image digests when needed. Registry calls are retried with the
+// default retry policy, use WithRetryPolicy to change it.
+func GenerateReferenceIdentifiers(refString, os, arch string, fopts ...IdentifierOptions) (IdentifiersBundle, error) {
+	opts, err := newIdentifierOptions(fopts)
+	if err != nil {
+		return IdentifiersBundle{}, err
+	}
+	craneOpts := []crane.Option{
+		func(o *crane.Options) {
+			o.Remote = append(o.Remote, opts.RetryPolicy.RemoteOptions(opts.RetryCounter)...)
+		},
 	}
 
 	ref, err := name.ParseReference(refString)
 	if err != nil {
-		return bundle, fmt.Errorf("parsing image reference: %w", err)
+		return IdentifiersBundle{}, errdefs.New(errdefs.ErrParse, "parsing image reference: %w", err)
 	}
 
-	identifier := ref.Identifier()
-	if strings.HasPrefix(identifier, "sha") {
-		dString = identifier
-	} else {
-		tag = identifier
+	// The manifest is needed to look up the platform image or to compute
+	// the extra identifiers. When we fetch it, the digest of tagged
+	// references is computed from it to avoid a second call.
+	image := bundleImage{}
+	if d, ok := ref.(name.Digest); ok {
+		image.Digest = d.DigestStr()
+	}
+	if opts.needsManifests() || (os != "" && arch != "") {
+		image.Manifest, err = crane.Manifest(refString, craneOpts...)
+		if err != nil {
+			return IdentifiersBundle{}, fmt.Errorf("getting image manifest: %w", ClassifyError(err))
+		}
+		if image.Digest == "" {
+			image.Digest = manifestDigest(image.Manifest)
+		}
+	} else if image.Digest == "" {
+		image.Digest, err = crane.Digest(refString, craneOpts...)
+		if err != nil {
+			return IdentifiersBundle{}, fmt.Errorf("getting image digest: %w", ClassifyError(err))
+		}
 	}
 
-	// If we dont have the digest in the reference, fetch it
-	if dString == "" {
-		dString, err = crane.Digest(refString)
+	fetch := func(digest string) ([]byte, error) {
+		raw, err := crane.Manifest(ref.Context().Digest(digest).String(), craneOpts...)
 		if err != nil {
-			return bundle, fmt.Errorf("getting image digest: %w", err)
+			return nil, fmt.Errorf("getting image manifest: %w", ClassifyError(err))
 		}
+		return raw, nil
 	}
 
-	bundle.Hashes[vex.SHA256] = append(
-		bundle.Hashes[vex.SHA256], vex.Hash(strings.TrimPrefix(dString, "sha256:")),
-	)
+	return buildBundle(ref, image, os, arch, opts, fetch)
+}
 
-	pts := strings.Split(ref.Context().RepositoryStr(), "/")
-	imageName := pts[len(pts)-1]
-	registryPath := ref.Context().RegistryStr() + "/" + strings.ReplaceAll(ref.Context().RepositoryStr(), imageName, "")
+// bundleImage is an image or index to add to an identifiers bundle
+type bundleImage struct {
+	// Digest is the digest of the image manifest
+	Digest string
 
-	// Generate the variants for the input reference
-	identifiers := generateImagePurlVariants(registryPath, imageName, dString, tag, os, arch)
-	bundle.Identifiers[vex.PURL] = append(bundle.Identifiers[vex.PURL], identifiers...)
+	// Manifest is the raw manifest. It is only needed to look up platform
+	// images and to compute the identifiers beyond the digest.
+	Manifest []byte
+}
 
-	if os == "" || arch == "" {
-		return bundle, nil
-	}
+// manifestDigest returns the SHA256 digest string of a raw manifest
+func manifestDigest(raw []byte) string {
+	return "sha256:" + hashManifest(vex.SHA256, raw)
+}
 
-	// Now compute the identifiers for the platform specific image
-	platform, err := v1.ParsePlatform(os + "/" + arch)
-	if err != nil {
-		return bundle, fmt.Errorf("parsing platform: %w", err)
-	}
+// hashManifest returns the hex encoded hash of a raw manifest
+func hashManifest(algorithm vex.Algorithm, raw []byte) string {
+	h := hashers[algorithm]()
+	h.Write(raw)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// buildBundle computes the identifiers of an image. If os and arch are set
+// and the image is an index, the image for the platform is looked up in the
+// index manifest and added to the bundle. The fetch function reads the
+// manifest of the platform image when the options need it, if it is nil the
+// bundle only has the platform image digest.
+func buildBundle(
+	ref name.Reference, image bundleImage, os, arch string, opts *identifierOptions,
+	fetch func(digest string) ([]byte, error),
+) (IdentifiersBundle, error) {
+	images := []bundleImage{image}
+	if os != "" && arch != "" {
+		platform, err := v1.ParsePlatform(os + "/" + arch)
+		if err != nil {
+			return IdentifiersBundle{}, errdefs.New(errdefs.ErrParse, "parsing platform: %w", err)
+		}
 
-	archDString, err := crane.Digest(refString, crane.WithPlatform(platform))
-	if err != nil {
 		// If there is no arch-specific variant, we simply don't
 		// include it. Return what we know.
-		if strings.Contains(err.Error(), "no child with platform") {
-			return bundle, nil
+		archDString, err := manifestPlatformDigest(image.Manifest, platform)
+		if err != nil {
+			return IdentifiersBundle{}, fmt.Errorf("getting image digest: %w", err)
+		}
+
+		if archDString != "" && archDString != image.Digest {
+			archImage := bundleImage{Digest: archDString}
+			if opts.needsManifests() && fetch != nil {
+				archImage.Manifest, err = fetch(archDString)
+				if err != nil {
+					return IdentifiersBundle{}, err
+				}
+			}
+			images = append(images, archImage)
 		}
-		return bundle, fmt.Errorf("getting image digest: %w", err)
 	}
+	return bundleFromImages(ref, images, os, arch, opts)
+}
+
+// bundleFromImages builds the identifiers of the images fronted by a
+// reference: usually the image itself or an index and its image for the
+// os and arch.
+func bundleFromImages(ref name.Reference, images []bundleImage, os, arch string, opts *identifierOptions) (IdentifiersBundle, error) {
+	bundle := IdentifiersBundle{
+		Identifiers: map[vex.IdentifierType][]string{vex.PURL: {}},
+		Hashes:      map[vex.Algorithm][]vex.Hash{vex.SHA256: {}},
+	}
+
+	tag := ""
+	if t, ok := ref.(name.Tag); ok {
+		tag = t.TagStr()
+	}
+
+	imageName, registryPath := splitRepository(ref.Context())
+
+	for _, image := range images {
+		bundle.Hashes[vex.SHA256] = append(
+			bundle.Hashes[vex.SHA256], vex.Hash(strings.TrimPrefix(image.Digest, "sha256:")),
+		)
 
-	// If the single-arch image digest is different, we generate purls for
-	// it as we want to match the index and the arch image:
-	if archDString != dString && archDString != "" {
+		// Generate the variants for the image
 		bundle.Identifiers[vex.PURL] = append(
-			bundle.Identifiers[vex.PURL], generateImagePurlVariants(registryPath, imageName, archDString, tag, os, arch)...,
+			bundle.Identifiers[vex.PURL], generateImagePurlVariants(registryPath, imageName, image.Digest, tag, os, arch)...,
 		)
-		bundle.Hashes[vex.SHA256] = append(
-			bundle.Hashes[vex.SHA256], vex.Hash(strings.TrimPrefix(archDString, "sha256:")),
+
+		if image.Manifest == nil {
+			continue
+		}
+
+		for _, algo := range opts.HashAlgorithms {
+			if algo == vex.SHA256 {
+				continue
+			}
+			bundle.Hashes[algo] = append(bundle.Hashes[algo], vex.Hash(hashManifest(algo, image.Manifest)))
+		}
+
+		if opts.ImageContents {
+			digests, err := manifestContents(image.Manifest)
+			if err != nil {
+				return IdentifiersBundle{}, err
+			}
+			bundle.Contents = append(bundle.Contents, digests...)
+		}
+	}
+
+	if opts.TagPurls && tag != "" {
+		bundle.Identifiers[vex.PURL] = append(
+			bundle.Identifiers[vex.PURL], generateTagPurlVariants(registryPath, imageName, tag, os, arch)...,
 		)
 	}
 
 	return bundle, nil
 }
 
+// manifestContents returns the SHA256 digests of the config and layers of
+// an image manifest. Indexes have no contents.
+func manifestContents(raw []byte) ([]vex.Hash, error) {
+	m, err := v1.ParseManifest(bytes.NewReader(raw))
+	if err != nil {
+		return nil, errdefs.New(errdefs.ErrParse, "parsing manifest: %w", err)
+	}
+
+	digests := []vex.Hash{}
+	if m.Config.Digest.Algorithm == "sha256" {
+		digests = append(digests, vex.Hash(m.Config.Digest.Hex))
+	}
+	for _, l := range m.Layers {
+		if l.Digest.Algorithm == "sha256" {
+			digests = append(digests, vex.Hash(l.Digest.Hex))
+		}
+	}
+	return digests, nil
+}
+
+// manifestPlatformDigest returns the digest of the image for a platform
+// listed in a raw index manifest. If the manifest is not an index or has no
+// image for the platform, it returns an empty string.
+func manifestPlatformDigest(raw []byte, platform *v1.Platform) (string, error) {
+	// Image manifests parse as an index without manifests
+	im, err := v1.ParseIndexManifest(bytes.NewReader(raw))
+	if err != nil {
+		return "", errdefs.New(errdefs.ErrParse, "parsing manifest: %w", err)
+	}
+
+	for _, m := range im.Manifests {
+		if m.Platform != nil && m.Platform.Satisfies(*platform) {
+			return m.Digest.String(), nil
+		}
+	}
+	return "", nil
+}
+
+// generateTagPurlVariants returns the purls of an image by tag, without
+// digest, with and without the platform qualifiers.
+func generateTagPurlVariants(registryString, imageName, tag, os, arch string) []string {
+	qMap := map[string]string{"tag": tag}
+	if registryString != "" {
+		qMap["repository_url"] = strings.TrimSuffix(registryString, "/")
+	}
+
+	purls := []string{
+		purl.NewPackageURL(purl.TypeOCI, "", imageName, "", purl.QualifiersFromMap(qMap), "").String(),
+	}
+	if os == "" && arch == "" {
+		return purls
+	}
+
+	if os != "" {
+		qMap["os"] = os
+	}
+	if arch != "" {
+		qMap["arch"] = arch
+	}
+	return append(purls,
+		purl.NewPackageURL(purl.TypeOCI, "", imageName, "", purl.QualifiersFromMap(qMap), "").String(),
+	)
+}
+
 // generatePurlVariants
 func generateImagePurlVariants(registryString, imageName, digestString, tag, os, arch string) []string {
 	purls := []string{}
@@ -175,6 +461,100 @@ func generateImagePurlVariants(registryString, imageName, digestString, tag, os,
 	return purls
 }
 
+// ReferenceToPurl returns the oci package URL of an image reference. It is
+// the inverse of PurlToReferenceString:
+//
+//   - The last element of the repository path is the purl name.
+//   - The registry, including its port, and the rest of the path are
+//     recorded in the repository_url qualifier. Docker Hub references are
+//     normalized, so alpine and docker.io/alpine are both recorded as
+//     index.docker.io/library.
+//   - The digest is the purl version. The tag, if any, is recorded in the
+//     tag qualifier. References without tag or digest get the latest tag.
+//   - If os or arch are set, they are added as qualifiers.
+//
+// Converting the purl back with PurlToReferenceString returns a reference
+// to the same image.
+func ReferenceToPurl(refString, os, arch string) (string, error) {
+	ref, err := name.ParseReference(refString)
+	if err != nil {
+		return "", errdefs.New(errdefs.ErrParse, "parsing image reference: %w", err)
+	}
+
+	imageName, repositoryURL := splitRepository(ref.Context())
+	qualifiers := map[string]string{"repository_url": repositoryURL}
+
+	version := ""
+	switch r := ref.(type) {
+	case name.Digest:
+		version = r.DigestStr()
+		// GGCR drops the tag of references with both tag and digest
+		if tag := referenceTag(strings.TrimSuffix(refString, "@"+version)); tag != "" {
+			qualifiers["tag"] = tag
+		}
+	case name.Tag:
+		qualifiers["tag"] = r.TagStr()
+	}
+
+	if os != "" {
+		qualifiers["os"] = os
+	}
+	if arch != "" {
+		qualifiers["arch"] = arch
+	}
+
+	return purl.NewPackageURL(
+		purl.TypeOCI, "", imageName, version, purl.QualifiersFromMap(qualifiers), "",
+	).String(), nil
+}
+
+// splitRepository returns the purl name of an image repository, the last
+// element of its path, and its repository_url: the registry, including its
+// port, followed by the rest of the path. Only the last element is removed,
+// the image name can appear in other parts of the path.
+func splitRepository(repo name.Repository) (imageName, repositoryURL string) {
+	repoPath := repo.RepositoryStr()
+	imageName = path.Base(repoPath)
+	repositoryURL = repo.RegistryStr()
+	if dir := path.Dir(repoPath); dir != "." {
+		repositoryURL += "/" + dir
+	}
+	return imageName, repositoryURL
+}
+
+// NormalizeRepositoryURL returns the repository_url qualifier of an oci purl
+// in the form written by ReferenceToPurl: without scheme or trailing slashes,
+// with the registry host in lowercase and Docker Hub registries expanded to
+// index.docker.io and its library namespace. Values that don't form a valid
+// repository with the image name are only trimmed.
+func NormalizeRepositoryURL(repositoryURL, imageName string) string {
+	if _, rest, ok := strings.Cut(repositoryURL, "://"); ok {
+		repositoryURL = rest
+	}
+	repositoryURL = strings.Trim(repositoryURL, "/")
+	host, repoPath, ok := strings.Cut(repositoryURL, "/")
+	repositoryURL = strings.ToLower(host)
+	if ok {
+		repositoryURL += "/" + repoPath
+	}
+
+	repo, err := name.NewRepository(repositoryURL + "/" + imageName)
+	if err != nil {
+		return repositoryURL
+	}
+	_, normalized := splitRepository(repo)
+	return normalized
+}
+
+// referenceTag returns the tag written in a reference string without digest.
+// The tag is the part after a colon in the last path element, colons in
+// the registry are ports.
+func referenceTag(refString string) string {
+	last := refString[strings.LastIndex(refString, "/")+1:]
+	_, tag, _ := strings.Cut(last, ":")
+	return tag
+}
+
 type purlRefConverterOptions struct {
 	// DefaultRepository will be added to the purl converter when none is found
 	// in the package url qualifiers
@@ -183,6 +563,9 @@ type purlRefConverterOptions struct {
 	// Override repository will always be added to the purl, overriding
 	// any that was set in the purl
 	OverrideRepository string
+
+	// Mirrors are rewrite rules applied to the resulting reference
+	Mirrors []Mirror
 }
 
 type RefConverterOptions func(*purlRefConverterOptions)
@@ -199,6 +582,12 @@ func WithOverrideRepository(reg string) RefConverterOptions {
 	}
 }
 
+func WithMirrors(mirrors []Mirror) RefConverterOptions {
+	return func(opts *purlRefConverterOptions) {
+		opts.Mirrors = mirrors
+	}
+}
+
 // PurlToReferenceString reads a Package URL of type OCI and returns an image
 // reference string. If the purl does not parse or is not of type oci: an error
 // will be returned. The function takes a few options:
@@ -210,7 +599,22 @@ func WithOverrideRepository(reg string) RefConverterOptions {
 //	WithOverrideRepository(string)
 //	Overrides repository used in the reference regardless one is set in the purl
 //	or not.
+//
+//	WithMirrors([]Mirror)
+//	Rewrites the reference to the first mirror matching it. Use
+//	PurlToReferenceStrings to get all the mirror candidates.
 func PurlToReferenceString(purlString string, fopts ...RefConverterOptions) (string, error) {
+	refs, err := PurlToReferenceStrings(purlString, fopts...)
+	if err != nil {
+		return "", err
+	}
+	return refs[0], nil
+}
+
+// PurlToReferenceStrings converts a purl of type OCI to image references just
+// as PurlToReferenceString but returns all the candidates produced by the
+// mirror rules in order, followed by the reference without mirrors.
+func PurlToReferenceStrings(purlString string, fopts ...RefConverterOptions) ([]string, error) {
 	opts := &purlRefConverterOptions{}
 	for _, opt := range fopts {
 		opt(opts)
@@ -218,15 +622,15 @@ func PurlToReferenceString(purlString string, fopts ...RefConverterOptions) (str
 
 	p, err := purl.FromString(purlString)
 	if err != nil {
-		return "", fmt.Errorf("parsing purl string: %w", err)
+		return nil, errdefs.New(errdefs.ErrParse, "parsing purl string: %w", err)
 	}
 
 	if p.Type != purl.TypeOCI {
-		return "", errors.New("package URL is not of type OCI")
+		return nil, errdefs.New(errdefs.ErrUnsupportedPurlType, "package URL is not of type OCI")
 	}
 
 	if p.Name == "" {
-		return "", errors.New("parsed package URL did not return a package name")
+		return nil, errdefs.New(errdefs.ErrParse, "parsed package URL did not return a package name")
 	}
 
 	qualifiers := p.Qualifiers.Map()
@@ -257,5 +661,9 @@ func PurlToReferenceString(purlString string, fopts ...RefConverterOptions) (str
 	if _, ok := qualifiers["tag"]; ok && p.Version == "" {
 		refString += ":" + qualifiers["tag"]
 	}
-	return refString, nil
+
+	if len(opts.Mirrors) == 0 {
+		return []string{refString}, nil
+	}
+	return RewriteReference(refString, opts.Mirrors)
 }
@@ -4,14 +4,27 @@
 package oci
 
 import (
+	"crypto/sha512"
+	"encoding/hex"
+	"net/url"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	purl "github.com/package-url/packageurl-go"
 	"github.com/stretchr/testify/require"
 
+	"github.com/openvex/discovery/internal/testregistry"
 	"github.com/openvex/go-vex/pkg/vex"
 )
 
 func TestGenerateReferenceIdentifiers(t *testing.T) {
+	reg := testregistry.New(t)
+	repoURL := url.QueryEscape(reg.Host)
+	indexHash := strings.TrimPrefix(testregistry.AlpineIndexDigest, "sha256:")
+	archHash := strings.TrimPrefix(testregistry.AlpineAmd64Digest, "sha256:")
 	for _, tc := range []struct {
 		name     string
 		input    string
@@ -20,39 +33,52 @@ func TestGenerateReferenceIdentifiers(t *testing.T) {
 	}{
 		{
 			name:  "multi arch index",
-			input: "alpine@sha256:eece025e432126ce23f223450a0326fbebde39cdf496a85d8c016293fc851978",
+			input: reg.Ref("alpine-cves@" + testregistry.AlpineIndexDigest),
 			expected: IdentifiersBundle{
 				Identifiers: map[vex.IdentifierType][]string{
 					vex.PURL: {
-						"pkg:oci/alpine@sha256%3Aeece025e432126ce23f223450a0326fbebde39cdf496a85d8c016293fc851978?repository_url=index.docker.io%2Flibrary",
-						"pkg:oci/alpine@sha256%3Aeece025e432126ce23f223450a0326fbebde39cdf496a85d8c016293fc851978?arch=amd64&os=linux&repository_url=index.docker.io%2Flibrary",
-						"pkg:oci/alpine@sha256%3A48d9183eb12a05c99bcc0bf44a003607b8e941e1d4f41f9ad12bdcc4b5672f86?repository_url=index.docker.io%2Flibrary",
-						"pkg:oci/alpine@sha256%3A48d9183eb12a05c99bcc0bf44a003607b8e941e1d4f41f9ad12bdcc4b5672f86?arch=amd64&os=linux&repository_url=index.docker.io%2Flibrary",
+						"pkg:oci/alpine-cves@sha256%3A" + indexHash + "?repository_url=" + repoURL,
+						"pkg:oci/alpine-cves@sha256%3A" + indexHash + "?arch=amd64&os=linux&repository_url=" + repoURL,
+						"pkg:oci/alpine-cves@sha256%3A" + archHash + "?repository_url=" + repoURL,
+						"pkg:oci/alpine-cves@sha256%3A" + archHash + "?arch=amd64&os=linux&repository_url=" + repoURL,
 					},
 				},
 				Hashes: map[vex.Algorithm][]vex.Hash{
-					vex.SHA256: {
-						"eece025e432126ce23f223450a0326fbebde39cdf496a85d8c016293fc851978",
-						"48d9183eb12a05c99bcc0bf44a003607b8e941e1d4f41f9ad12bdcc4b5672f86",
+					vex.SHA256: {vex.Hash(indexHash), vex.Hash(archHash)},
+				},
+			},
+			mustErr: false,
+		},
+		{
+			name:  "multi arch index by tag",
+			input: reg.Ref("alpine-cves:latest"),
+			expected: IdentifiersBundle{
+				Identifiers: map[vex.IdentifierType][]string{
+					vex.PURL: {
+						"pkg:oci/alpine-cves@sha256%3A" + indexHash + "?repository_url=" + repoURL,
+						"pkg:oci/alpine-cves@sha256%3A" + indexHash + "?arch=amd64&os=linux&repository_url=" + repoURL + "&tag=latest",
+						"pkg:oci/alpine-cves@sha256%3A" + archHash + "?repository_url=" + repoURL,
+						"pkg:oci/alpine-cves@sha256%3A" + archHash + "?arch=amd64&os=linux&repository_url=" + repoURL + "&tag=latest",
 					},
 				},
+				Hashes: map[vex.Algorithm][]vex.Hash{
+					vex.SHA256: {vex.Hash(indexHash), vex.Hash(archHash)},
+				},
 			},
 			mustErr: false,
 		},
 		{
 			name:  "single arch image",
-			input: "cgr.dev/chainguard/curl@sha256:3b987bc327e8aa8e7db26822e0552d927d25392ccb4d3b9d30b5390b485520d8",
+			input: reg.Ref("alpine-cves-amd64@" + testregistry.AlpineAmd64Digest),
 			expected: IdentifiersBundle{
 				Identifiers: map[vex.IdentifierType][]string{
 					vex.PURL: {
-						"pkg:oci/curl@sha256%3A3b987bc327e8aa8e7db26822e0552d927d25392ccb4d3b9d30b5390b485520d8?repository_url=cgr.dev%2Fchainguard",
-						"pkg:oci/curl@sha256%3A3b987bc327e8aa8e7db26822e0552d927d25392ccb4d3b9d30b5390b485520d8?arch=amd64&os=linux&repository_url=cgr.dev%2Fchainguard",
+						"pkg:oci/alpine-cves-amd64@sha256%3A" + archHash + "?repository_url=" + repoURL,
+						"pkg:oci/alpine-cves-amd64@sha256%3A" + archHash + "?arch=amd64&os=linux&repository_url=" + repoURL,
 					},
 				},
 				Hashes: map[vex.Algorithm][]vex.Hash{
-					vex.SHA256: {
-						"3b987bc327e8aa8e7db26822e0552d927d25392ccb4d3b9d30b5390b485520d8",
-					},
+					vex.SHA256: {vex.Hash(archHash)},
 				},
 			},
 			mustErr: false,
@@ -143,6 +169,15 @@ func TestPurlToReferenceString(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"mirror",
+			"pkg:oci/debian?tag=12",
+			"mirror.example.com/hub/debian:12",
+			[]RefConverterOptions{
+				WithMirrors([]Mirror{{Prefix: "docker.io/library", Mirror: "mirror.example.com/hub"}}),
+			},
+			false,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			ref, err := PurlToReferenceString(tc.testInput, tc.options...)
@@ -154,3 +189,272 @@ func TestPurlToReferenceString(t *testing.T) {
 		})
 	}
 }
+
+func TestReferenceToPurl(t *testing.T) {
+	digest := "sha256:47fed8868b46b060efb8699dc40e981a0c785650223e03602d8c4493fc75b68c"
+	encDigest := "sha256%3A47fed8868b46b060efb8699dc40e981a0c785650223e03602d8c4493fc75b68c"
+	for _, tc := range []struct {
+		name      string
+		ref       string
+		os        string
+		arch      string
+		expected  string
+		roundTrip string
+		mustErr   bool
+	}{
+		{
+			name:      "docker hub short name",
+			ref:       "alpine",
+			expected:  "pkg:oci/alpine?repository_url=index.docker.io%2Flibrary&tag=latest",
+			roundTrip: "index.docker.io/library/alpine:latest",
+		},
+		{
+			name:      "docker hub normalization",
+			ref:       "docker.io/alpine:3.18",
+			expected:  "pkg:oci/alpine?repository_url=index.docker.io%2Flibrary&tag=3.18",
+			roundTrip: "index.docker.io/library/alpine:3.18",
+		},
+		{
+			name:      "docker hub user repository",
+			ref:       "openvex/vexctl:v0.2.5",
+			expected:  "pkg:oci/vexctl?repository_url=index.docker.io%2Fopenvex&tag=v0.2.5",
+			roundTrip: "index.docker.io/openvex/vexctl:v0.2.5",
+		},
+		{
+			name:      "nested repository",
+			ref:       "ghcr.io/openvex/tools/vexctl:v1",
+			expected:  "pkg:oci/vexctl?repository_url=ghcr.io%2Fopenvex%2Ftools&tag=v1",
+			roundTrip: "ghcr.io/openvex/tools/vexctl:v1",
+		},
+		{
+			name:      "registry with port",
+			ref:       "localhost:5000/image:v1",
+			expected:  "pkg:oci/image?repository_url=localhost%3A5000&tag=v1",
+			roundTrip: "localhost:5000/image:v1",
+		},
+		{
+			name:      "digest",
+			ref:       "cgr.dev/chainguard/curl@" + digest,
+			expected:  "pkg:oci/curl@" + encDigest + "?repository_url=cgr.dev%2Fchainguard",
+			roundTrip: "cgr.dev/chainguard/curl@" + digest,
+		},
+		{
+			name:      "tag and digest",
+			ref:       "localhost:5000/curl:latest@" + digest,
+			expected:  "pkg:oci/curl@" + encDigest + "?repository_url=localhost%3A5000&tag=latest",
+			roundTrip: "localhost:5000/curl@" + digest,
+		},
+		{
+			name:      "platform",
+			ref:       "cgr.dev/chainguard/curl@" + digest,
+			os:        "linux",
+			arch:      "arm64",
+			expected:  "pkg:oci/curl@" + encDigest + "?arch=arm64&os=linux&repository_url=cgr.dev%2Fchainguard",
+			roundTrip: "cgr.dev/chainguard/curl@" + digest,
+		},
+		{
+			name:    "invalid reference",
+			ref:     "Not A Reference",
+			mustErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ReferenceToPurl(tc.ref, tc.os, tc.arch)
+			if tc.mustErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, p)
+
+			// Converting the purl back must point to the same image
+			back, err := PurlToReferenceString(p)
+			require.NoError(t, err)
+			require.Equal(t, tc.roundTrip, back)
+
+			original, err := name.ParseReference(tc.ref)
+			require.NoError(t, err)
+			parsed, err := name.ParseReference(back)
+			require.NoError(t, err)
+			require.Equal(t, original.Context().Name(), parsed.Context().Name())
+			require.Equal(t, original.Identifier(), parsed.Identifier())
+		})
+	}
+}
+
+func TestGenerateReferenceIdentifiersOptions(t *testing.T) {
+	reg := testregistry.New(t)
+	repoURL := url.QueryEscape(reg.Host)
+	ref := reg.Ref("alpine-cves:latest")
+
+	indexRef, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	index, err := remote.Get(indexRef)
+	require.NoError(t, err)
+	archRef, err := name.ParseReference(reg.Ref("alpine-cves@" + testregistry.AlpineAmd64Digest))
+	require.NoError(t, err)
+	arch, err := remote.Get(archRef)
+	require.NoError(t, err)
+	img, err := arch.Image()
+	require.NoError(t, err)
+	manifest, err := img.Manifest()
+	require.NoError(t, err)
+
+	res, err := GenerateReferenceIdentifiers(
+		ref, "linux", "amd64", WithHashAlgorithms(vex.SHA512), WithImageContents(), WithTagPurls(),
+	)
+	require.NoError(t, err)
+
+	indexSum := sha512.Sum512(index.Manifest)
+	archSum := sha512.Sum512(arch.Manifest)
+	require.Equal(t, []vex.Hash{
+		vex.Hash(hex.EncodeToString(indexSum[:])), vex.Hash(hex.EncodeToString(archSum[:])),
+	}, res.Hashes[vex.SHA512])
+
+	contents := []vex.Hash{vex.Hash(manifest.Config.Digest.Hex)}
+	for _, l := range manifest.Layers {
+		contents = append(contents, vex.Hash(l.Digest.Hex))
+	}
+	require.Equal(t, contents, res.Contents)
+	require.Len(t, res.Hashes[vex.SHA256], 2)
+
+	require.Len(t, res.Identifiers[vex.PURL], 6)
+	require.Equal(t, []string{
+		"pkg:oci/alpine-cves?repository_url=" + repoURL + "&tag=latest",
+		"pkg:oci/alpine-cves?arch=amd64&os=linux&repository_url=" + repoURL + "&tag=latest",
+	}, res.Identifiers[vex.PURL][4:])
+
+	// The offline bundle of the same image is the same
+	offline, err := GenerateLayoutIdentifiers(
+		ref, filepath.Join(testregistry.TestDataPath(), "alpine-cves"), "linux", "amd64",
+		WithHashAlgorithms(vex.SHA512), WithImageContents(), WithTagPurls(),
+	)
+	require.NoError(t, err)
+	require.Equal(t, res, offline)
+
+	_, err = GenerateReferenceIdentifiers(ref, "linux", "amd64", WithHashAlgorithms(vex.BLAKE3))
+	require.Error(t, err)
+}
+
+func TestIdentifiersBundleMatch(t *testing.T) {
+	bundle := IdentifiersBundle{
+		Identifiers: map[vex.IdentifierType][]string{
+			vex.PURL: {
+				"pkg:oci/alpine@sha256%3Aabc123?repository_url=index.docker.io%2Flibrary",
+				"pkg:oci/alpine@sha256%3Aabc123?arch=amd64&os=linux&repository_url=index.docker.io%2Flibrary&tag=latest",
+			},
+		},
+		Hashes: map[vex.Algorithm][]vex.Hash{
+			vex.SHA256: {"abc123"},
+			vex.SHA512: {"def456"},
+		},
+		Contents: []vex.Hash{"789abc"},
+	}
+	for _, tc := range []struct {
+		name     string
+		product  *vex.Product
+		expected bool
+	}{
+		{
+			"purl id",
+			&vex.Product{Component: vex.Component{ID: "pkg:oci/alpine@sha256%3Aabc123?repository_url=index.docker.io%2Flibrary"}},
+			true,
+		},
+		{
+			"generic purl",
+			&vex.Product{Component: vex.Component{ID: "pkg:oci/alpine"}},
+			true,
+		},
+		{
+			"purl identifier with tag",
+			&vex.Product{Component: vex.Component{Identifiers: map[vex.IdentifierType]string{
+				vex.PURL: "pkg:oci/alpine?tag=latest",
+			}}},
+			true,
+		},
+		{
+			"other image",
+			&vex.Product{Component: vex.Component{ID: "pkg:oci/nginx"}},
+			false,
+		},
+		{
+			"sha256 hash",
+			&vex.Product{Component: vex.Component{Hashes: map[vex.Algorithm]vex.Hash{vex.SHA256: "ABC123"}}},
+			true,
+		},
+		{
+			"sha512 hash",
+			&vex.Product{Component: vex.Component{Hashes: map[vex.Algorithm]vex.Hash{vex.SHA512: "def456"}}},
+			true,
+		},
+		{
+			"hash of other algorithm",
+			&vex.Product{Component: vex.Component{Hashes: map[vex.Algorithm]vex.Hash{vex.SHA512: "abc123"}}},
+			false,
+		},
+		{
+			"layer digest",
+			&vex.Product{Component: vex.Component{Hashes: map[vex.Algorithm]vex.Hash{vex.SHA256: "789abc"}}},
+			false,
+		},
+		{"nil product", nil, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, bundle.Match(tc.product))
+		})
+	}
+}
+
+func TestGenerateIdentifiersRepositoryPaths(t *testing.T) {
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	digest := manifestDigest(manifest)
+	encDigest := url.QueryEscape(digest)
+	for _, tc := range []struct {
+		name     string
+		ref      string
+		expected string
+	}{
+		{"image name in parent path", "registry.example.com/foo/foo-tools/foo:v1", "pkg:oci/foo@" + encDigest + "?repository_url=registry.example.com%2Ffoo%2Ffoo-tools"},
+		{"image name as parent", "ghcr.io/foo/foo:v1", "pkg:oci/foo@" + encDigest + "?repository_url=ghcr.io%2Ffoo"},
+		{"image name as prefix", "ghcr.io/app/application/app:v1", "pkg:oci/app@" + encDigest + "?repository_url=ghcr.io%2Fapp%2Fapplication"},
+		{"image name as suffix", "ghcr.io/webapp/app:v1", "pkg:oci/app@" + encDigest + "?repository_url=ghcr.io%2Fwebapp"},
+		{"image name in registry", "app.example.com/team/app:v1", "pkg:oci/app@" + encDigest + "?repository_url=app.example.com%2Fteam"},
+		{"single element", "registry.example.com/foo:v1", "pkg:oci/foo@" + encDigest + "?repository_url=registry.example.com"},
+		{"deeply nested", "registry.example.com/a/b/c/d/e:v1", "pkg:oci/e@" + encDigest + "?repository_url=registry.example.com%2Fa%2Fb%2Fc%2Fd"},
+		{"registry with port", "registry.example.com:8443/team/app:v1", "pkg:oci/app@" + encDigest + "?repository_url=registry.example.com%3A8443%2Fteam"},
+		{"localhost with port", "localhost:5000/a/b/c:v1", "pkg:oci/c@" + encDigest + "?repository_url=localhost%3A5000%2Fa%2Fb"},
+		{"ip address", "127.0.0.1:5000/foo/foo:v1", "pkg:oci/foo@" + encDigest + "?repository_url=127.0.0.1%3A5000%2Ffoo"},
+		{"ipv6 registry", "[::1]:5000/foo/bar:v1", "pkg:oci/bar@" + encDigest + "?repository_url=%5B%3A%3A1%5D%3A5000%2Ffoo"},
+		{"docker hub library", "alpine:3.18", "pkg:oci/alpine@" + encDigest + "?repository_url=index.docker.io%2Flibrary"},
+		{"docker hub user", "library/library:v1", "pkg:oci/library@" + encDigest + "?repository_url=index.docker.io%2Flibrary"},
+		{"digest reference", "ghcr.io/foo/foo@" + digest, "pkg:oci/foo@" + encDigest + "?repository_url=ghcr.io%2Ffoo"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := GenerateManifestIdentifiers(tc.ref, manifest, "", "")
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, res.Identifiers[vex.PURL][0])
+
+			// The purls must point back to the same repository
+			ref, err := name.ParseReference(tc.ref)
+			require.NoError(t, err)
+			for _, p := range res.Identifiers[vex.PURL] {
+				refString, err := PurlToReferenceString(p)
+				require.NoError(t, err)
+				back, err := name.ParseReference(refString)
+				require.NoError(t, err)
+				require.Equal(t, ref.Context().Name(), back.Context().Name())
+				require.Equal(t, digest, back.Identifier())
+			}
+
+			// ReferenceToPurl decomposes the repository in the same way
+			p, err := ReferenceToPurl(tc.ref, "", "")
+			require.NoError(t, err)
+			converted, err := purl.FromString(p)
+			require.NoError(t, err)
+			expected, err := purl.FromString(tc.expected)
+			require.NoError(t, err)
+			require.Equal(t, expected.Name, converted.Name)
+			require.Equal(t, expected.Qualifiers.Map()["repository_url"], converted.Qualifiers.Map()["repository_url"])
+		})
+	}
+}
@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+// refNameAnnotation is the annotation that records the reference of the
+// manifests in an OCI layout index
+const refNameAnnotation = "org.opencontainers.image.ref.name"
+
+// GenerateManifestIdentifiers computes the same identifiers as
+// GenerateReferenceIdentifiers from the raw bytes of an image manifest or
+// index, without calling the registry. The reference string is only used
+// to name the image in the purls. If it has a digest, it must match the
+// digest of the manifest.
+//
+// As only the index manifest is known, the bundle of an index has the digest
+// of the platform image but not the identifiers computed from its manifest.
+func GenerateManifestIdentifiers(refString string, manifest []byte, os, arch string, fopts ...IdentifierOptions) (IdentifiersBundle, error) {
+	return manifestIdentifiers(refString, manifest, os, arch, fopts, nil)
+}
+
+// manifestIdentifiers computes the identifiers of a raw manifest, reading
+// the manifest of the platform image with fetch, if not nil.
+func manifestIdentifiers(
+	refString string, manifest []byte, os, arch string, fopts []IdentifierOptions,
+	fetch func(digest string) ([]byte, error),
+) (IdentifiersBundle, error) {
+	opts, err := newIdentifierOptions(fopts)
+	if err != nil {
+		return IdentifiersBundle{}, err
+	}
+
+	ref, err := name.ParseReference(refString)
+	if err != nil {
+		return IdentifiersBundle{}, errdefs.New(errdefs.ErrParse, "parsing image reference: %w", err)
+	}
+
+	digest := manifestDigest(manifest)
+	if d, ok := ref.(name.Digest); ok && d.DigestStr() != digest {
+		return IdentifiersBundle{}, errdefs.New(
+			errdefs.ErrVerificationFailed, "manifest digest %s does not match the reference digest %s",
+			digest, d.DigestStr(),
+		)
+	}
+
+	return buildBundle(ref, bundleImage{Digest: digest, Manifest: manifest}, os, arch, opts, fetch)
+}
+
+// GenerateLayoutIdentifiers computes the identifiers of an image stored in
+// an OCI image layout directory. If the layout holds more than one image, the
+// one annotated with the reference or its tag is used.
+func GenerateLayoutIdentifiers(refString, layoutPath, os, arch string, fopts ...IdentifierOptions) (IdentifiersBundle, error) {
+	ref, err := name.ParseReference(refString)
+	if err != nil {
+		return IdentifiersBundle{}, errdefs.New(errdefs.ErrParse, "parsing image reference: %w", err)
+	}
+
+	p, err := layout.FromPath(layoutPath)
+	if err != nil {
+		return IdentifiersBundle{}, fmt.Errorf("opening image layout: %w", err)
+	}
+
+	idx, err := p.ImageIndex()
+	if err != nil {
+		return IdentifiersBundle{}, fmt.Errorf("reading image layout index: %w", err)
+	}
+
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return IdentifiersBundle{}, fmt.Errorf("reading image layout index: %w", err)
+	}
+
+	desc, err := findLayoutManifest(im, ref)
+	if err != nil {
+		return IdentifiersBundle{}, err
+	}
+
+	raw, err := p.Bytes(desc.Digest)
+	if err != nil {
+		return IdentifiersBundle{}, fmt.Errorf("reading manifest %s: %w", desc.Digest, err)
+	}
+
+	fetch := func(digest string) ([]byte, error) {
+		h, err := v1.NewHash(digest)
+		if err != nil {
+			return nil, errdefs.New(errdefs.ErrParse, "parsing digest: %w", err)
+		}
+		raw, err := p.Bytes(h)
+		if err != nil {
+			return nil, fmt.Errorf("reading manifest %s: %w", digest, err)
+		}
+		return raw, nil
+	}
+
+	return manifestIdentifiers(refString, raw, os, arch, fopts, fetch)
+}
+
+// findLayoutManifest returns the descriptor of the image in a layout index
+// matching a reference
+func findLayoutManifest(im *v1.IndexManifest, ref name.Reference) (*v1.Descriptor, error) {
+	if len(im.Manifests) == 1 {
+		return &im.Manifests[0], nil
+	}
+
+	for i := range im.Manifests {
+		if d, ok := ref.(name.Digest); ok && im.Manifests[i].Digest.String() == d.DigestStr() {
+			return &im.Manifests[i], nil
+		}
+		refName := im.Manifests[i].Annotations[refNameAnnotation]
+		if refName == "" {
+			continue
+		}
+		if refName == ref.Identifier() || refName == ref.String() || refName == ref.Name() {
+			return &im.Manifests[i], nil
+		}
+	}
+	return nil, errdefs.New(errdefs.ErrNotFound, "image %s not found in layout", ref.String())
+}
+
+// GenerateTarballIdentifiers computes the identifiers of an image stored in
+// a tarball as written by docker save or crane pull. If the tarball holds
+// more than one image, the one tagged as the reference is used.
+//
+// Docker tarballs don't store the registry manifest. The digest is computed
+// from the manifest of the image as stored in the tarball, it only matches
+// the registry digest if the image was written with the same layers.
+func GenerateTarballIdentifiers(refString, tarballPath, os, arch string, fopts ...IdentifierOptions) (IdentifiersBundle, error) {
+	ref, err := name.ParseReference(refString)
+	if err != nil {
+		return IdentifiersBundle{}, errdefs.New(errdefs.ErrParse, "parsing image reference: %w", err)
+	}
+
+	img, err := tarball.ImageFromPath(tarballPath, nil)
+	if tag, ok := ref.(name.Tag); ok && err != nil {
+		img, err = tarball.ImageFromPath(tarballPath, &tag)
+	}
+	if err != nil {
+		return IdentifiersBundle{}, fmt.Errorf("reading image tarball: %w", err)
+	}
+
+	raw, err := img.RawManifest()
+	if err != nil {
+		return IdentifiersBundle{}, fmt.Errorf("reading image manifest: %w", err)
+	}
+
+	return GenerateManifestIdentifiers(refString, raw, os, arch, fopts...)
+}
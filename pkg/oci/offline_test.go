@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/internal/testregistry"
+	"github.com/openvex/discovery/pkg/errdefs"
+	"github.com/openvex/go-vex/pkg/vex"
+)
+
+func TestGenerateLayoutIdentifiers(t *testing.T) {
+	reg := testregistry.New(t)
+	layoutPath := filepath.Join(testregistry.TestDataPath(), "alpine-cves")
+	for _, tc := range []struct {
+		name    string
+		input   string
+		os      string
+		arch    string
+		mustErr bool
+	}{
+		{"by digest", reg.Ref("alpine-cves@" + testregistry.AlpineIndexDigest), "linux", "amd64", false},
+		{"by tag", reg.Ref("alpine-cves:latest"), "linux", "amd64", false},
+		{"no platform", reg.Ref("alpine-cves:latest"), "", "", false},
+		{"digest mismatch", reg.Ref("alpine-cves@" + testregistry.AlpineAmd64Digest), "linux", "amd64", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := GenerateLayoutIdentifiers(tc.input, layoutPath, tc.os, tc.arch)
+			if tc.mustErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			expected, err := GenerateReferenceIdentifiers(tc.input, tc.os, tc.arch)
+			require.NoError(t, err)
+			require.Equal(t, expected, res)
+		})
+	}
+}
+
+func TestGenerateManifestIdentifiers(t *testing.T) {
+	reg := testregistry.New(t)
+	imageRef, err := name.ParseReference(reg.Ref("alpine-cves-amd64:latest"))
+	require.NoError(t, err)
+	desc, err := remote.Get(imageRef)
+	require.NoError(t, err)
+
+	ref := reg.Ref("alpine-cves-amd64@" + testregistry.AlpineAmd64Digest)
+	res, err := GenerateManifestIdentifiers(ref, desc.Manifest, "linux", "amd64")
+	require.NoError(t, err)
+
+	expected, err := GenerateReferenceIdentifiers(ref, "linux", "amd64")
+	require.NoError(t, err)
+	require.Equal(t, expected, res)
+
+	_, err = GenerateManifestIdentifiers(
+		reg.Ref("alpine-cves@"+testregistry.AlpineIndexDigest), desc.Manifest, "linux", "amd64",
+	)
+	require.ErrorIs(t, err, errdefs.ErrVerificationFailed)
+
+	_, err = GenerateManifestIdentifiers("invalid reference", desc.Manifest, "linux", "amd64")
+	require.ErrorIs(t, err, errdefs.ErrParse)
+}
+
+func TestGenerateTarballIdentifiers(t *testing.T) {
+	reg := testregistry.New(t)
+	tag, err := name.NewTag(reg.Ref("alpine-cves-amd64:latest"))
+	require.NoError(t, err)
+	img, err := remote.Image(tag)
+	require.NoError(t, err)
+
+	tarPath := filepath.Join(t.TempDir(), "image.tar")
+	require.NoError(t, tarball.WriteToFile(tarPath, tag, img))
+
+	res, err := GenerateTarballIdentifiers(tag.String(), tarPath, "linux", "amd64")
+	require.NoError(t, err)
+
+	// Docker tarballs don't keep the registry manifest, the identifiers
+	// use the digest of the manifest computed from the tarball.
+	tarImg, err := tarball.ImageFromPath(tarPath, nil)
+	require.NoError(t, err)
+	d, err := tarImg.Digest()
+	require.NoError(t, err)
+	require.Equal(t, []vex.Hash{vex.Hash(d.Hex)}, res.Hashes[vex.SHA256])
+	require.Len(t, res.Identifiers[vex.PURL], 2)
+	require.Contains(t, res.Identifiers[vex.PURL][1], "arch=amd64&os=linux")
+	require.Contains(t, res.Identifiers[vex.PURL][1], "tag=latest")
+
+	_, err = GenerateTarballIdentifiers(tag.String(), filepath.Join(t.TempDir(), "missing.tar"), "linux", "amd64")
+	require.Error(t, err)
+}
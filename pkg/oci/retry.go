@@ -0,0 +1,295 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"gopkg.in/yaml.v3"
+)
+
+// retryStatusCodes are the registry responses that are retried
+var retryStatusCodes = map[int]struct{}{
+	http.StatusRequestTimeout:      {},
+	http.StatusTooManyRequests:     {},
+	http.StatusInternalServerError: {},
+	http.StatusBadGateway:          {},
+	http.StatusServiceUnavailable:  {},
+	http.StatusGatewayTimeout:      {},
+}
+
+// RetryPolicy defines how registry requests are retried when they are rate
+// limited or fail with a transient server error. Zero durations take the
+// values of DefaultRetryPolicy. When decoded from YAML or JSON, durations
+// are written as strings, eg "500ms", and the fields not set in the data
+// take the values of DefaultRetryPolicy.
+type RetryPolicy struct {
+	// MaxRetries is the number of times a request is retried. Zero
+	// disables retries.
+	MaxRetries int `yaml:"maxRetries" json:"maxRetries"`
+
+	// InitialBackoff is the time to wait before the first retry. It
+	// doubles with each retry.
+	InitialBackoff time.Duration `yaml:"initialBackoff" json:"initialBackoff"`
+
+	// MaxBackoff caps the time to wait between retries, including the
+	// time requested by the registry in Retry-After headers.
+	MaxBackoff time.Duration `yaml:"maxBackoff" json:"maxBackoff"`
+
+	// Jitter is the fraction of the backoff randomly added or subtracted
+	// to spread the retries, from 0 to 1.
+	Jitter float64 `yaml:"jitter" json:"jitter"`
+}
+
+// DefaultRetryPolicy is the retry policy used when none is specified
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     3,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Jitter:         0.2,
+}
+
+// withDefaults returns the policy with its zero durations set to the values
+// of DefaultRetryPolicy.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = DefaultRetryPolicy.InitialBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = DefaultRetryPolicy.MaxBackoff
+	}
+	return p
+}
+
+// UnmarshalYAML decodes a policy, the fields not set take their values from
+// DefaultRetryPolicy.
+func (p *RetryPolicy) UnmarshalYAML(value *yaml.Node) error {
+	type plain RetryPolicy
+	policy := plain(DefaultRetryPolicy)
+	if err := value.Decode(&policy); err != nil {
+		return err
+	}
+	*p = RetryPolicy(policy)
+	return nil
+}
+
+// UnmarshalJSON decodes a policy with its durations written as strings. The
+// fields not set take their values from DefaultRetryPolicy.
+func (p *RetryPolicy) UnmarshalJSON(data []byte) error {
+	type plain RetryPolicy
+	policy := plain(DefaultRetryPolicy)
+	aux := struct {
+		*plain
+		InitialBackoff jsonDuration `json:"initialBackoff"`
+		MaxBackoff     jsonDuration `json:"maxBackoff"`
+	}{
+		plain:          &policy,
+		InitialBackoff: jsonDuration(policy.InitialBackoff),
+		MaxBackoff:     jsonDuration(policy.MaxBackoff),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	policy.InitialBackoff = time.Duration(aux.InitialBackoff)
+	policy.MaxBackoff = time.Duration(aux.MaxBackoff)
+	*p = RetryPolicy(policy)
+	return nil
+}
+
+// MarshalJSON encodes the policy with its durations written as strings
+func (p RetryPolicy) MarshalJSON() ([]byte, error) {
+	type plain RetryPolicy
+	return json.Marshal(struct {
+		plain
+		InitialBackoff string `json:"initialBackoff"`
+		MaxBackoff     string `json:"maxBackoff"`
+	}{
+		plain:          plain(p),
+		InitialBackoff: p.InitialBackoff.String(),
+		MaxBackoff:     p.MaxBackoff.String(),
+	})
+}
+
+// jsonDuration is a duration written in JSON as a string, eg "1m30s". Numbers
+// are read as nanoseconds.
+type jsonDuration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("durations must be strings like \"500ms\": %w", err)
+		}
+		*d = jsonDuration(n)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(v)
+	return nil
+}
+
+// Validate checks the policy values
+func (p *RetryPolicy) Validate() error {
+	errs := []error{}
+	if p.MaxRetries < 0 {
+		errs = append(errs, errors.New("max retries must not be negative"))
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < 0 {
+		errs = append(errs, errors.New("backoff durations must not be negative"))
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		errs = append(errs, errors.New("jitter must be between 0 and 1"))
+	}
+	return errors.Join(errs...)
+}
+
+// backoff returns the time to wait before a retry. If the registry sent
+// a Retry-After header, it is honored.
+func (p *RetryPolicy) backoff(retry int, resp *http.Response) time.Duration {
+	if d, ok := retryAfter(resp); ok {
+		if p.MaxBackoff > 0 && d > p.MaxBackoff {
+			return p.MaxBackoff
+		}
+		return d
+	}
+
+	d := p.InitialBackoff << retry
+	if d < p.InitialBackoff || (p.MaxBackoff > 0 && d > p.MaxBackoff) {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration(float64(d) * p.Jitter * (2*rand.Float64() - 1)) //nolint:gosec // jitter does not need a secure rng
+	}
+	return d
+}
+
+// retryAfter reads the Retry-After header of a response. The header can
+// be a number of seconds or a date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// RetryCounter counts the retried registry requests. It is safe for
+// concurrent use.
+type RetryCounter struct {
+	retries     atomic.Int64
+	rateLimited atomic.Int64
+}
+
+// RetryStats are the counters of a RetryCounter
+type RetryStats struct {
+	// Retries is the number of retried requests
+	Retries int
+
+	// RateLimited is the number of responses that signaled a rate limit
+	RateLimited int
+}
+
+// Stats returns the counters
+func (c *RetryCounter) Stats() RetryStats {
+	return RetryStats{
+		Retries:     int(c.retries.Load()),
+		RateLimited: int(c.rateLimited.Load()),
+	}
+}
+
+// retryTransport retries requests according to a retry policy
+type retryTransport struct {
+	inner   http.RoundTripper
+	policy  RetryPolicy
+	counter *RetryCounter
+}
+
+// NewRetryTransport wraps a transport to retry the requests that are rate
+// limited or fail with transient server errors. If counter is not nil, the
+// retries are counted in it.
+func NewRetryTransport(inner http.RoundTripper, policy RetryPolicy, counter *RetryCounter) http.RoundTripper {
+	if counter == nil {
+		counter = &RetryCounter{}
+	}
+	return &retryTransport{inner: inner, policy: policy.withDefaults(), counter: counter}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		resp, err := t.inner.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+
+		if _, ok := retryStatusCodes[resp.StatusCode]; !ok {
+			return resp, nil
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			t.counter.rateLimited.Add(1)
+		}
+
+		// Requests with a body can only be retried if it can be read again
+		if retry >= t.policy.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+
+		wait := t.policy.backoff(retry, resp)
+		resp.Body.Close()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		t.counter.retries.Add(1)
+	}
+}
+
+// RemoteOptions returns the GGCR remote options to retry registry requests
+// with the policy. The default GGCR retries of error responses are turned
+// off so requests are not retried twice.
+func (p RetryPolicy) RemoteOptions(counter *RetryCounter) []remote.Option {
+	return []remote.Option{
+		remote.WithTransport(NewRetryTransport(remote.DefaultTransport, p, counter)),
+		remote.WithRetryStatusCodes(),
+	}
+}
@@ -0,0 +1,191 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openvex/go-vex/pkg/vex"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/openvex/discovery/internal/testregistry"
+)
+
+// flakyHandler fails the first requests with a status code
+func flakyHandler(failures, status int, next http.Handler) http.Handler {
+	calls := &atomic.Int64{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= int64(failures) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestRetryTransport(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	policy := RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	for _, tc := range []struct {
+		name     string
+		failures int
+		status   int
+		expected int
+		stats    RetryStats
+	}{
+		{"no failures", 0, http.StatusTooManyRequests, http.StatusOK, RetryStats{}},
+		{"rate limited", 2, http.StatusTooManyRequests, http.StatusOK, RetryStats{Retries: 2, RateLimited: 2}},
+		{"server error", 1, http.StatusBadGateway, http.StatusOK, RetryStats{Retries: 1}},
+		{"retries exhausted", 3, http.StatusServiceUnavailable, http.StatusServiceUnavailable, RetryStats{Retries: 2}},
+		{"not retried", 1, http.StatusNotFound, http.StatusNotFound, RetryStats{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := flakyHandler(tc.failures, tc.status, ok)
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			counter := &RetryCounter{}
+			client := &http.Client{Transport: NewRetryTransport(http.DefaultTransport, policy, counter)}
+			resp, err := client.Get(server.URL)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, tc.expected, resp.StatusCode)
+			require.Equal(t, tc.stats, counter.Stats())
+		})
+	}
+}
+
+func TestRetryTransportCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ask for a long wait so the test can only pass if the wait is canceled
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	policy := RetryPolicy{MaxRetries: 1, MaxBackoff: time.Minute}
+	client := &http.Client{Transport: NewRetryTransport(http.DefaultTransport, policy, nil)}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = client.Do(req) //nolint:bodyclose // the request fails
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	require.Equal(t, time.Second, policy.backoff(0, nil))
+	require.Equal(t, 4*time.Second, policy.backoff(2, nil))
+	require.Equal(t, 5*time.Second, policy.backoff(3, nil))
+	require.Equal(t, 5*time.Second, policy.backoff(100, nil))
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", "2")
+	require.Equal(t, 2*time.Second, policy.backoff(0, resp))
+	resp.Header.Set("Retry-After", "120")
+	require.Equal(t, 5*time.Second, policy.backoff(0, resp))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		d := policy.backoff(0, nil)
+		require.GreaterOrEqual(t, d, 500*time.Millisecond)
+		require.LessOrEqual(t, d, 1500*time.Millisecond)
+	}
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	require.NoError(t, DefaultRetryPolicy.Validate())
+	require.Error(t, (&RetryPolicy{MaxRetries: -1}).Validate())
+	require.Error(t, (&RetryPolicy{InitialBackoff: -time.Second}).Validate())
+	require.Error(t, (&RetryPolicy{Jitter: 2}).Validate())
+}
+
+func TestRetryPolicyDecode(t *testing.T) {
+	partial := RetryPolicy{
+		MaxRetries: 5, InitialBackoff: 500 * time.Millisecond, MaxBackoff: DefaultRetryPolicy.MaxBackoff, Jitter: DefaultRetryPolicy.Jitter,
+	}
+	for _, tc := range []struct {
+		name     string
+		yaml     bool
+		data     string
+		expected RetryPolicy
+		mustErr  bool
+	}{
+		{"json partial", false, `{"maxRetries": 5, "initialBackoff": "500ms"}`, partial, false},
+		{"json nanoseconds", false, `{"maxRetries": 5, "initialBackoff": 500000000}`, partial, false},
+		{"json disable retries", false, `{"maxRetries": 0}`, RetryPolicy{
+			InitialBackoff: DefaultRetryPolicy.InitialBackoff, MaxBackoff: DefaultRetryPolicy.MaxBackoff, Jitter: DefaultRetryPolicy.Jitter,
+		}, false},
+		{"json invalid duration", false, `{"initialBackoff": "soon"}`, RetryPolicy{}, true},
+		{"yaml partial", true, "maxRetries: 5\ninitialBackoff: 500ms\n", partial, false},
+		{"yaml empty", true, "{}", DefaultRetryPolicy, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := RetryPolicy{}
+			var err error
+			if tc.yaml {
+				err = yaml.Unmarshal([]byte(tc.data), &policy)
+			} else {
+				err = json.Unmarshal([]byte(tc.data), &policy)
+			}
+			if tc.mustErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, policy)
+		})
+	}
+
+	data, err := json.Marshal(partial)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"maxRetries": 5, "initialBackoff": "500ms", "maxBackoff": "30s", "jitter": 0.2}`, string(data))
+	policy := RetryPolicy{}
+	require.NoError(t, json.Unmarshal(data, &policy))
+	require.Equal(t, partial, policy)
+}
+
+func TestRetryPolicyDefaults(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 2}.withDefaults()
+	require.Equal(t, RetryPolicy{
+		MaxRetries: 2, InitialBackoff: DefaultRetryPolicy.InitialBackoff, MaxBackoff: DefaultRetryPolicy.MaxBackoff,
+	}, policy)
+}
+
+func TestGenerateReferenceIdentifiersRetries(t *testing.T) {
+	reg := testregistry.New(t)
+	target, err := url.Parse("http://" + reg.Host)
+	require.NoError(t, err)
+
+	handler := flakyHandler(2, http.StatusTooManyRequests, httputil.NewSingleHostReverseProxy(target))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	counter := &RetryCounter{}
+	bundle, err := GenerateReferenceIdentifiers(
+		host+"/alpine-cves:latest", "", "",
+		WithRetryPolicy(RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond}),
+		WithRetryCounter(counter),
+	)
+	require.NoError(t, err)
+	require.Len(t, bundle.Hashes[vex.SHA256], 1)
+	require.Equal(t, RetryStats{Retries: 2, RateLimited: 2}, counter.Stats())
+}
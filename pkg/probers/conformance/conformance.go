@@ -0,0 +1,437 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package conformance implements a test suite to check that VexProbe
+// implementations behave as the discovery agent expects. Third party probers
+// can run it from their own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Test(t, myprober.New(), conformance.Config{
+//			Type:    "generic",
+//			Found:   "pkg:generic/known-package@1.0.0",
+//			Missing: "pkg:generic/does-not-exist@1.0.0",
+//		})
+//	}
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openvex/go-vex/pkg/vex"
+	purl "github.com/package-url/packageurl-go"
+
+	"github.com/openvex/discovery/pkg/discovery"
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/validation"
+)
+
+// DefaultTimeout is the time a prober has to return from a call when the
+// configuration does not define one.
+const DefaultTimeout = 30 * time.Second
+
+// DefaultConcurrency is the number of simultaneous calls made to the prober
+// in the concurrency check when the configuration does not define one.
+const DefaultConcurrency = 8
+
+// Config defines the package URLs the suite uses to exercise the prober. Only
+// Type and Missing are required, the checks that need a purl that was not
+// specified are skipped.
+type Config struct {
+	// Type is the purl type handled by the prober
+	Type string
+
+	// Found is a purl of a package with at least one VEX document
+	Found string
+
+	// Missing is a purl of a package that does not exist
+	Missing string
+
+	// Malformed is a purl of a package whose VEX documents are malformed
+	Malformed string
+
+	// UnsupportedQualifiers is a purl with qualifiers the prober does not
+	// understand. If not set, the suite adds bogus qualifiers to Found or Missing.
+	UnsupportedQualifiers string
+
+	// Timeout is the maximum time a call to the prober may take
+	Timeout time.Duration
+
+	// Concurrency is the number of simultaneous calls in the concurrency check
+	Concurrency int
+
+	// Limits are the validation limits used to tell malformed documents, as
+	// the agent does. Defaults to validation.DefaultLimits.
+	Limits validation.Limits
+}
+
+// Violation records a check where the prober did not behave as expected.
+type Violation struct {
+	Check   string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Check, v.Message)
+}
+
+// Report is the result of running the suite on a prober.
+type Report struct {
+	// Passed lists the checks the prober passed
+	Passed []string
+
+	// Skipped lists the checks not run because the config lacked data for them
+	Skipped []string
+
+	// Violations lists the problems found in the prober
+	Violations []Violation
+}
+
+// OK returns true if no violations were found
+func (r *Report) OK() bool {
+	return len(r.Violations) == 0
+}
+
+type check struct {
+	name string
+	run  func(discovery.VexProbe, *Config) []string
+	skip func(*Config) bool
+}
+
+var checks = []check{
+	{name: "wrong-type", run: checkWrongType},
+	{name: "missing-package", run: checkMissing},
+	{
+		name: "found",
+		run:  checkFound,
+		skip: func(c *Config) bool { return c.Found == "" },
+	},
+	{name: "unsupported-qualifiers", run: checkUnsupportedQualifiers},
+	{
+		name: "malformed-documents",
+		run:  checkMalformed,
+		skip: func(c *Config) bool { return c.Malformed == "" },
+	},
+	{name: "empty-options", run: checkEmptyOptions},
+	{name: "foreign-options", run: checkForeignOptions},
+	{name: "invalid-options", run: checkInvalidOptions},
+	{name: "cancellation", run: checkCancellation},
+	{name: "concurrency", run: checkConcurrency},
+}
+
+// Run exercises the prober with all the checks in the suite and returns a
+// report with the violations found.
+func Run(probe discovery.VexProbe, cfg Config) *Report {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = DefaultConcurrency
+	}
+	if cfg.Limits == (validation.Limits{}) {
+		cfg.Limits = validation.DefaultLimits
+	}
+
+	report := &Report{}
+	if err := validateConfig(&cfg); err != nil {
+		report.Violations = append(report.Violations, Violation{"config", err.Error()})
+		return report
+	}
+
+	for _, c := range checks {
+		if c.skip != nil && c.skip(&cfg) {
+			report.Skipped = append(report.Skipped, c.name)
+			continue
+		}
+		problems := c.run(probe, &cfg)
+		if len(problems) == 0 {
+			report.Passed = append(report.Passed, c.name)
+			continue
+		}
+		for _, p := range problems {
+			report.Violations = append(report.Violations, Violation{c.name, p})
+		}
+	}
+	return report
+}
+
+// Test runs the suite and marks the test as failed for each violation found
+func Test(t testing.TB, probe discovery.VexProbe, cfg Config) {
+	t.Helper()
+	report := Run(probe, cfg)
+	for _, s := range report.Skipped {
+		t.Logf("conformance check %s skipped", s)
+	}
+	for _, v := range report.Violations {
+		t.Errorf("conformance violation in %s", v)
+	}
+}
+
+// testPurl returns the purl used by checks that work with any package. It
+// prefers the found package as it exercises more of the prober code.
+func (cfg *Config) testPurl() string {
+	if cfg.Found != "" {
+		return cfg.Found
+	}
+	return cfg.Missing
+}
+
+// validateConfig checks that the purls in the config are valid
+func validateConfig(cfg *Config) error {
+	if cfg.Type == "" {
+		return fmt.Errorf("purl type not set")
+	}
+	if cfg.Missing == "" {
+		return fmt.Errorf("missing package purl not set")
+	}
+	for _, s := range []string{cfg.Found, cfg.Missing, cfg.Malformed, cfg.UnsupportedQualifiers} {
+		if s == "" {
+			continue
+		}
+		p, err := purl.FromString(s)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", s, err)
+		}
+		if p.Type != cfg.Type {
+			return fmt.Errorf("purl %s is not of type %s", s, cfg.Type)
+		}
+	}
+	return nil
+}
+
+// defaultOptions returns a fresh set of options with a silent logger
+func defaultOptions() options.Options {
+	return options.Options{
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Context:       context.Background(),
+		ProberOptions: map[string]interface{}{},
+	}
+}
+
+// callResult captures the return values of a call to the prober
+type callResult struct {
+	docs     []*vex.VEX
+	err      error
+	panicked any
+	timedOut bool
+}
+
+// call invokes the prober setting the options first as the agent does with
+// per call probers. It recovers from panics and gives up waiting after the
+// configured timeout.
+func call(probe discovery.VexProbe, cfg *Config, opts options.Options, purlString string) callResult {
+	return doCall(probe, cfg, opts, purlString, true)
+}
+
+// doCall invokes the prober, optionally setting its options first
+func doCall(probe discovery.VexProbe, cfg *Config, opts options.Options, purlString string, setOptions bool) callResult {
+	p, err := purl.FromString(purlString)
+	if err != nil {
+		return callResult{err: err}
+	}
+
+	done := make(chan callResult, 1)
+	go func() {
+		res := callResult{}
+		defer func() {
+			if r := recover(); r != nil {
+				res.panicked = r
+			}
+			done <- res
+		}()
+		if setOptions {
+			probe.SetOptions(opts)
+		}
+		res.docs, res.err = probe.FindDocumentsFromPurl(opts, p)
+	}()
+
+	select {
+	case res := <-done:
+		return res
+	case <-time.After(cfg.Timeout):
+		return callResult{timedOut: true}
+	}
+}
+
+// commonProblems returns the violations any call can incur
+func commonProblems(res *callResult, purlString string) []string {
+	if res.panicked != nil {
+		return []string{fmt.Sprintf("prober panicked probing %s: %v", purlString, res.panicked)}
+	}
+	if res.timedOut {
+		return []string{fmt.Sprintf("prober did not return probing %s", purlString)}
+	}
+	problems := []string{}
+	for i, d := range res.docs {
+		if d == nil {
+			problems = append(problems, fmt.Sprintf("document #%d probing %s is nil", i, purlString))
+		}
+	}
+	if res.err != nil && len(res.docs) > 0 {
+		problems = append(problems, fmt.Sprintf("prober returned documents and an error probing %s", purlString))
+	}
+	return problems
+}
+
+// checkWrongType ensures the prober rejects purls of other types
+func checkWrongType(probe discovery.VexProbe, cfg *Config) []string {
+	purlType := "conformance"
+	if cfg.Type == purlType {
+		purlType = "conformance-other"
+	}
+	purlString := fmt.Sprintf("pkg:%s/conformance-test@1.0.0", purlType)
+	res := call(probe, cfg, defaultOptions(), purlString)
+	problems := commonProblems(&res, purlString)
+	if len(problems) > 0 {
+		return problems
+	}
+	if res.err == nil {
+		return []string{fmt.Sprintf("prober did not return an error probing %s", purlString)}
+	}
+	return nil
+}
+
+// checkMissing ensures a missing package does not produce documents
+func checkMissing(probe discovery.VexProbe, cfg *Config) []string {
+	res := call(probe, cfg, defaultOptions(), cfg.Missing)
+	problems := commonProblems(&res, cfg.Missing)
+	if len(problems) > 0 {
+		return problems
+	}
+	if len(res.docs) > 0 {
+		return []string{fmt.Sprintf("prober returned %d documents for missing package", len(res.docs))}
+	}
+	return nil
+}
+
+// checkFound ensures the prober finds the documents of a known package
+func checkFound(probe discovery.VexProbe, cfg *Config) []string {
+	res := call(probe, cfg, defaultOptions(), cfg.Found)
+	problems := commonProblems(&res, cfg.Found)
+	if len(problems) > 0 {
+		return problems
+	}
+	if res.err != nil {
+		return []string{fmt.Sprintf("probing %s: %v", cfg.Found, res.err)}
+	}
+	if len(res.docs) == 0 {
+		return []string{fmt.Sprintf("prober found no documents for %s", cfg.Found)}
+	}
+	return nil
+}
+
+// checkUnsupportedQualifiers ensures unknown qualifiers don't break the prober
+func checkUnsupportedQualifiers(probe discovery.VexProbe, cfg *Config) []string {
+	purlString := cfg.UnsupportedQualifiers
+	if purlString == "" {
+		p, err := purl.FromString(cfg.testPurl())
+		if err != nil {
+			return []string{err.Error()}
+		}
+		q := p.Qualifiers.Map()
+		q["conformance-qualifier"] = "bogus value"
+		p.Qualifiers = purl.QualifiersFromMap(q)
+		purlString = p.String()
+	}
+	res := call(probe, cfg, defaultOptions(), purlString)
+	return commonProblems(&res, purlString)
+}
+
+// checkMalformed ensures malformed documents are not returned
+func checkMalformed(probe discovery.VexProbe, cfg *Config) []string {
+	res := call(probe, cfg, defaultOptions(), cfg.Malformed)
+	problems := commonProblems(&res, cfg.Malformed)
+	if len(problems) > 0 {
+		return problems
+	}
+	for i, d := range res.docs {
+		if invalid := validation.Document(d, cfg.Limits); len(invalid) > 0 {
+			problems = append(problems, fmt.Sprintf("malformed document #%d was returned: %s", i, strings.Join(invalid, ", ")))
+		}
+	}
+	return problems
+}
+
+// checkEmptyOptions ensures the prober copes with zero value options
+func checkEmptyOptions(probe discovery.VexProbe, cfg *Config) []string {
+	purlString := cfg.testPurl()
+	res := call(probe, cfg, options.Options{}, purlString)
+	return commonProblems(&res, purlString)
+}
+
+// checkForeignOptions ensures the prober ignores options meant for others
+func checkForeignOptions(probe discovery.VexProbe, cfg *Config) []string {
+	purlString := cfg.testPurl()
+	opts := defaultOptions()
+	opts.ProberOptions["conformance"] = struct{ Bogus string }{"value"}
+	res := call(probe, cfg, opts, purlString)
+	return commonProblems(&res, purlString)
+}
+
+// checkInvalidOptions ensures the prober does not panic when its options are
+// of an unexpected type. Probers may reject them or ignore them.
+func checkInvalidOptions(probe discovery.VexProbe, cfg *Config) []string {
+	purlString := cfg.testPurl()
+	opts := defaultOptions()
+	opts.ProberOptions[cfg.Type] = struct{ Bogus string }{"value"}
+	res := call(probe, cfg, opts, purlString)
+	return commonProblems(&res, purlString)
+}
+
+// checkCancellation ensures the prober honors a canceled context
+func checkCancellation(probe discovery.VexProbe, cfg *Config) []string {
+	purlString := cfg.testPurl()
+	opts := defaultOptions()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts.Context = ctx
+
+	res := call(probe, cfg, opts, purlString)
+	problems := commonProblems(&res, purlString)
+	if len(problems) > 0 {
+		return problems
+	}
+	if res.err == nil {
+		return []string{"prober did not return an error when the context was canceled"}
+	}
+	return nil
+}
+
+// checkConcurrency calls the prober from several goroutines at the same time
+// and checks all calls return the same results. Shared probers don't get their
+// options set by the agent, so the calls pass the options only as argument.
+func checkConcurrency(probe discovery.VexProbe, cfg *Config) []string {
+	purlString := cfg.testPurl()
+
+	res := make([]callResult, cfg.Concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res[i] = doCall(probe, cfg, defaultOptions(), purlString, false)
+		}(i)
+	}
+	wg.Wait()
+
+	problems := []string{}
+	for i := range res {
+		problems = append(problems, commonProblems(&res[i], purlString)...)
+		if len(problems) > 0 {
+			continue
+		}
+		if (res[i].err == nil) != (res[0].err == nil) || len(res[i].docs) != len(res[0].docs) {
+			problems = append(problems, fmt.Sprintf(
+				"concurrent call #%d returned different results (%d docs, err %v) than call #0 (%d docs, err %v)",
+				i, len(res[i].docs), res[i].err, len(res[0].docs), res[0].err,
+			))
+		}
+	}
+	return problems
+}
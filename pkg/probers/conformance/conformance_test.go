@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package conformance
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openvex/go-vex/pkg/vex"
+	purl "github.com/package-url/packageurl-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/pkg/discovery/options"
+)
+
+// testProbe is a prober with configurable misbehavior
+type testProbe struct {
+	panics        bool
+	nilDocs       bool
+	assertOptions bool
+	malformed     bool
+}
+
+func (tp *testProbe) SetOptions(options.Options) {}
+
+func (tp *testProbe) FindDocumentsFromPurl(opts options.Options, p purl.PackageURL) ([]*vex.VEX, error) {
+	if tp.panics {
+		panic("synthetic panic")
+	}
+	if o, ok := opts.ProberOptions["generic"]; ok && tp.assertOptions {
+		_ = o.(string)
+	}
+	if p.Type != "generic" {
+		return nil, fmt.Errorf("unsupported purl type")
+	}
+	if opts.Context != nil && opts.Context.Err() != nil {
+		return nil, opts.Context.Err()
+	}
+	if p.Name == "malformed" && tp.malformed {
+		// Documents with an id but no author or timestamp are not valid
+		return []*vex.VEX{{Metadata: vex.Metadata{ID: "doc"}}}, nil
+	}
+	if p.Name != "found" {
+		return []*vex.VEX{}, nil
+	}
+	if tp.nilDocs {
+		return []*vex.VEX{nil}, nil
+	}
+	return []*vex.VEX{{Metadata: vex.Metadata{ID: "doc"}}}, nil
+}
+
+func TestRun(t *testing.T) {
+	cfg := Config{
+		Type:    "generic",
+		Found:   "pkg:generic/found@1.0.0",
+		Missing: "pkg:generic/missing@1.0.0",
+		Timeout: 5 * time.Second,
+	}
+	malformed := cfg
+	malformed.Malformed = "pkg:generic/malformed@1.0.0"
+	for _, tc := range []struct {
+		name    string
+		probe   *testProbe
+		cfg     Config
+		ok      bool
+		skipped int
+	}{
+		{"conformant", &testProbe{}, cfg, true, 1},
+		{"malformed documents dropped", &testProbe{}, malformed, true, 0},
+		{"malformed documents returned", &testProbe{malformed: true}, malformed, false, 0},
+		{"panics", &testProbe{panics: true}, cfg, false, 1},
+		{"nil documents", &testProbe{nilDocs: true}, cfg, false, 1},
+		{"panics on invalid options", &testProbe{assertOptions: true}, cfg, false, 1},
+		{"invalid config", &testProbe{}, Config{Type: "generic"}, false, 0},
+		{"wrong purl type in config", &testProbe{}, Config{Type: "generic", Missing: "pkg:oci/missing"}, false, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			report := Run(tc.probe, tc.cfg)
+			require.Equal(t, tc.ok, report.OK(), report.Violations)
+			require.Len(t, report.Skipped, tc.skipped)
+		})
+	}
+}
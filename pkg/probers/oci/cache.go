@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// payloadCache stores attestation payloads on disk, keyed by the digest of
+// the layer holding them. Cached payloads are checked against the digest
+// when read, files that don't match are removed.
+type payloadCache struct {
+	dir string
+}
+
+// path returns the file where the payload of a layer is cached
+func (c *payloadCache) path(digest v1.Hash) string {
+	return filepath.Join(c.dir, "attestations", digest.Algorithm, digest.Hex)
+}
+
+// get returns the cached payload of a layer, if there is one and its
+// digest matches. Corrupted or tampered files are evicted.
+func (c *payloadCache) get(digest v1.Hash) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(digest))
+	if err != nil {
+		return nil, false
+	}
+	sum, _, err := v1.SHA256(bytes.NewReader(data))
+	if err != nil || sum != digest {
+		// Removal errors are ignored, the entry is rewritten after download
+		_ = os.Remove(c.path(digest))
+		return nil, false
+	}
+	return data, true
+}
+
+// put stores the payload of a layer. The file is written to a temporary
+// location and renamed so concurrent probes never read partial payloads.
+func (c *payloadCache) put(digest v1.Hash, data []byte) error {
+	path := c.path(digest)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), digest.Hex+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating cache file: %w", err)
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		if rerr := os.Remove(f.Name()); rerr != nil && !errors.Is(rerr, fs.ErrNotExist) {
+			err = errors.Join(err, rerr)
+		}
+		return fmt.Errorf("writing cache file: %w", err)
+	}
+	return nil
+}
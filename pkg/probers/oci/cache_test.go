@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/internal/testregistry"
+	"github.com/openvex/discovery/pkg/discovery/options"
+)
+
+func TestAttestationPayload(t *testing.T) {
+	reg := testregistry.New(t)
+	ref, err := name.ParseReference(reg.Ref("alpine-cves:latest"))
+	require.NoError(t, err)
+	se, err := ociremote.SignedEntity(ref)
+	require.NoError(t, err)
+	atts, err := se.Attestations()
+	require.NoError(t, err)
+	sigs, err := atts.Get()
+	require.NoError(t, err)
+	require.NotEmpty(t, sigs)
+
+	expected, err := sigs[0].Payload()
+	require.NoError(t, err)
+	digest, err := sigs[0].Digest()
+	require.NoError(t, err)
+
+	// Without a cache directory the payload is downloaded
+	payload, problems, err := attestationPayload(options.New(), Options{}, sigs[0])
+	require.NoError(t, err)
+	require.Nil(t, problems)
+	require.Equal(t, expected, payload)
+
+	// The first read stores the payload in the cache
+	ociOpts := NewOptions(WithCacheDir(t.TempDir()))
+	payload, _, err = attestationPayload(options.New(), ociOpts, sigs[0])
+	require.NoError(t, err)
+	require.Equal(t, expected, payload)
+
+	cache := &payloadCache{dir: ociOpts.CacheDir}
+	cached, ok := cache.get(digest)
+	require.True(t, ok)
+	require.Equal(t, expected, cached)
+
+	// Later reads come from the cache
+	sig := &sizedSignature{signature: sigs[0], size: int64(len(expected)), payload: expected}
+	payload, _, err = attestationPayload(options.New(), ociOpts, sig)
+	require.NoError(t, err)
+	require.Equal(t, expected, payload)
+	require.False(t, sig.downloaded)
+
+	// Cache files that don't match the digest are evicted and downloaded again
+	for _, data := range [][]byte{bytes.Repeat([]byte("x"), len(expected)), []byte("truncated")} {
+		require.NoError(t, os.WriteFile(cache.path(digest), data, 0o600))
+		_, ok = cache.get(digest)
+		require.False(t, ok)
+		require.NoFileExists(t, cache.path(digest))
+
+		require.NoError(t, os.WriteFile(cache.path(digest), data, 0o600))
+		sig := &sizedSignature{signature: sigs[0], size: int64(len(expected)), payload: expected}
+		payload, _, err = attestationPayload(options.New(), ociOpts, sig)
+		require.NoError(t, err)
+		require.Equal(t, expected, payload)
+		require.True(t, sig.downloaded)
+
+		cached, ok = cache.get(digest)
+		require.True(t, ok)
+		require.Equal(t, expected, cached)
+	}
+}
@@ -0,0 +1,25 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci_test
+
+import (
+	"testing"
+	"time"
+
+	purl "github.com/package-url/packageurl-go"
+
+	"github.com/openvex/discovery/internal/testregistry"
+	"github.com/openvex/discovery/pkg/probers/conformance"
+	"github.com/openvex/discovery/pkg/probers/oci"
+)
+
+func TestConformance(t *testing.T) {
+	reg := testregistry.New(t)
+	conformance.Test(t, oci.New(), conformance.Config{
+		Type:    purl.TypeOCI,
+		Found:   "pkg:oci/alpine-cves?repository_url=" + reg.Host + "&tag=latest",
+		Missing: "pkg:oci/does-not-exist?repository_url=" + reg.Host + "&tag=latest",
+		Timeout: 10 * time.Second,
+	})
+}
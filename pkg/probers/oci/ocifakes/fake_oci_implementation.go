@@ -6,38 +6,68 @@ import (
 
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/openvex/discovery/pkg/discovery/options"
-	"github.com/openvex/go-vex/pkg/vex"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/sbom"
 	packageurl "github.com/package-url/packageurl-go"
 	ocia "github.com/sigstore/cosign/v2/pkg/oci"
 )
 
 type FakeOciImplementation struct {
-	DownloadDocumentsStub        func(options.Options, ocia.SignedEntity) ([]*vex.VEX, error)
+	AttestationSourceStub        func(options.Options, name.Reference, ocia.SignedEntity) (string, error)
+	attestationSourceMutex       sync.RWMutex
+	attestationSourceArgsForCall []struct {
+		arg1 options.Options
+		arg2 name.Reference
+		arg3 ocia.SignedEntity
+	}
+	attestationSourceReturns struct {
+		result1 string
+		result2 error
+	}
+	attestationSourceReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	DownloadDocumentsStub        func(options.Options, ocia.SignedEntity) ([]*results.Document, error)
 	downloadDocumentsMutex       sync.RWMutex
 	downloadDocumentsArgsForCall []struct {
 		arg1 options.Options
 		arg2 ocia.SignedEntity
 	}
 	downloadDocumentsReturns struct {
-		result1 []*vex.VEX
+		result1 []*results.Document
 		result2 error
 	}
 	downloadDocumentsReturnsOnCall map[int]struct {
-		result1 []*vex.VEX
+		result1 []*results.Document
+		result2 error
+	}
+	DownloadSBOMStub        func(options.Options, ocia.SignedEntity) (*sbom.Contents, error)
+	downloadSBOMMutex       sync.RWMutex
+	downloadSBOMArgsForCall []struct {
+		arg1 options.Options
+		arg2 ocia.SignedEntity
+	}
+	downloadSBOMReturns struct {
+		result1 *sbom.Contents
+		result2 error
+	}
+	downloadSBOMReturnsOnCall map[int]struct {
+		result1 *sbom.Contents
 		result2 error
 	}
-	PurlToReferenceStub        func(options.Options, packageurl.PackageURL) (name.Reference, error)
-	purlToReferenceMutex       sync.RWMutex
-	purlToReferenceArgsForCall []struct {
+	PurlToReferencesStub        func(options.Options, packageurl.PackageURL) ([]name.Reference, error)
+	purlToReferencesMutex       sync.RWMutex
+	purlToReferencesArgsForCall []struct {
 		arg1 options.Options
 		arg2 packageurl.PackageURL
 	}
-	purlToReferenceReturns struct {
-		result1 name.Reference
+	purlToReferencesReturns struct {
+		result1 []name.Reference
 		result2 error
 	}
-	purlToReferenceReturnsOnCall map[int]struct {
-		result1 name.Reference
+	purlToReferencesReturnsOnCall map[int]struct {
+		result1 []name.Reference
 		result2 error
 	}
 	ResolveImageReferenceStub        func(options.Options, name.Reference) (ocia.SignedEntity, error)
@@ -54,6 +84,20 @@ type FakeOciImplementation struct {
 		result1 ocia.SignedEntity
 		result2 error
 	}
+	ResolvePlatformImagesStub        func(options.Options, ocia.SignedEntity) (map[string]ocia.SignedImage, error)
+	resolvePlatformImagesMutex       sync.RWMutex
+	resolvePlatformImagesArgsForCall []struct {
+		arg1 options.Options
+		arg2 ocia.SignedEntity
+	}
+	resolvePlatformImagesReturns struct {
+		result1 map[string]ocia.SignedImage
+		result2 error
+	}
+	resolvePlatformImagesReturnsOnCall map[int]struct {
+		result1 map[string]ocia.SignedImage
+		result2 error
+	}
 	VerifyOptionsStub        func(*options.Options) error
 	verifyOptionsMutex       sync.RWMutex
 	verifyOptionsArgsForCall []struct {
@@ -69,7 +113,73 @@ type FakeOciImplementation struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeOciImplementation) DownloadDocuments(arg1 options.Options, arg2 ocia.SignedEntity) ([]*vex.VEX, error) {
+func (fake *FakeOciImplementation) AttestationSource(arg1 options.Options, arg2 name.Reference, arg3 ocia.SignedEntity) (string, error) {
+	fake.attestationSourceMutex.Lock()
+	ret, specificReturn := fake.attestationSourceReturnsOnCall[len(fake.attestationSourceArgsForCall)]
+	fake.attestationSourceArgsForCall = append(fake.attestationSourceArgsForCall, struct {
+		arg1 options.Options
+		arg2 name.Reference
+		arg3 ocia.SignedEntity
+	}{arg1, arg2, arg3})
+	stub := fake.AttestationSourceStub
+	fakeReturns := fake.attestationSourceReturns
+	fake.recordInvocation("AttestationSource", []interface{}{arg1, arg2, arg3})
+	fake.attestationSourceMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeOciImplementation) AttestationSourceCallCount() int {
+	fake.attestationSourceMutex.RLock()
+	defer fake.attestationSourceMutex.RUnlock()
+	return len(fake.attestationSourceArgsForCall)
+}
+
+func (fake *FakeOciImplementation) AttestationSourceCalls(stub func(options.Options, name.Reference, ocia.SignedEntity) (string, error)) {
+	fake.attestationSourceMutex.Lock()
+	defer fake.attestationSourceMutex.Unlock()
+	fake.AttestationSourceStub = stub
+}
+
+func (fake *FakeOciImplementation) AttestationSourceArgsForCall(i int) (options.Options, name.Reference, ocia.SignedEntity) {
+	fake.attestationSourceMutex.RLock()
+	defer fake.attestationSourceMutex.RUnlock()
+	argsForCall := fake.attestationSourceArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeOciImplementation) AttestationSourceReturns(result1 string, result2 error) {
+	fake.attestationSourceMutex.Lock()
+	defer fake.attestationSourceMutex.Unlock()
+	fake.AttestationSourceStub = nil
+	fake.attestationSourceReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOciImplementation) AttestationSourceReturnsOnCall(i int, result1 string, result2 error) {
+	fake.attestationSourceMutex.Lock()
+	defer fake.attestationSourceMutex.Unlock()
+	fake.AttestationSourceStub = nil
+	if fake.attestationSourceReturnsOnCall == nil {
+		fake.attestationSourceReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.attestationSourceReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOciImplementation) DownloadDocuments(arg1 options.Options, arg2 ocia.SignedEntity) ([]*results.Document, error) {
 	fake.downloadDocumentsMutex.Lock()
 	ret, specificReturn := fake.downloadDocumentsReturnsOnCall[len(fake.downloadDocumentsArgsForCall)]
 	fake.downloadDocumentsArgsForCall = append(fake.downloadDocumentsArgsForCall, struct {
@@ -95,7 +205,7 @@ func (fake *FakeOciImplementation) DownloadDocumentsCallCount() int {
 	return len(fake.downloadDocumentsArgsForCall)
 }
 
-func (fake *FakeOciImplementation) DownloadDocumentsCalls(stub func(options.Options, ocia.SignedEntity) ([]*vex.VEX, error)) {
+func (fake *FakeOciImplementation) DownloadDocumentsCalls(stub func(options.Options, ocia.SignedEntity) ([]*results.Document, error)) {
 	fake.downloadDocumentsMutex.Lock()
 	defer fake.downloadDocumentsMutex.Unlock()
 	fake.DownloadDocumentsStub = stub
@@ -108,43 +218,108 @@ func (fake *FakeOciImplementation) DownloadDocumentsArgsForCall(i int) (options.
 	return argsForCall.arg1, argsForCall.arg2
 }
 
-func (fake *FakeOciImplementation) DownloadDocumentsReturns(result1 []*vex.VEX, result2 error) {
+func (fake *FakeOciImplementation) DownloadDocumentsReturns(result1 []*results.Document, result2 error) {
 	fake.downloadDocumentsMutex.Lock()
 	defer fake.downloadDocumentsMutex.Unlock()
 	fake.DownloadDocumentsStub = nil
 	fake.downloadDocumentsReturns = struct {
-		result1 []*vex.VEX
+		result1 []*results.Document
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeOciImplementation) DownloadDocumentsReturnsOnCall(i int, result1 []*vex.VEX, result2 error) {
+func (fake *FakeOciImplementation) DownloadDocumentsReturnsOnCall(i int, result1 []*results.Document, result2 error) {
 	fake.downloadDocumentsMutex.Lock()
 	defer fake.downloadDocumentsMutex.Unlock()
 	fake.DownloadDocumentsStub = nil
 	if fake.downloadDocumentsReturnsOnCall == nil {
 		fake.downloadDocumentsReturnsOnCall = make(map[int]struct {
-			result1 []*vex.VEX
+			result1 []*results.Document
 			result2 error
 		})
 	}
 	fake.downloadDocumentsReturnsOnCall[i] = struct {
-		result1 []*vex.VEX
+		result1 []*results.Document
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOciImplementation) DownloadSBOM(arg1 options.Options, arg2 ocia.SignedEntity) (*sbom.Contents, error) {
+	fake.downloadSBOMMutex.Lock()
+	ret, specificReturn := fake.downloadSBOMReturnsOnCall[len(fake.downloadSBOMArgsForCall)]
+	fake.downloadSBOMArgsForCall = append(fake.downloadSBOMArgsForCall, struct {
+		arg1 options.Options
+		arg2 ocia.SignedEntity
+	}{arg1, arg2})
+	stub := fake.DownloadSBOMStub
+	fakeReturns := fake.downloadSBOMReturns
+	fake.recordInvocation("DownloadSBOM", []interface{}{arg1, arg2})
+	fake.downloadSBOMMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeOciImplementation) DownloadSBOMCallCount() int {
+	fake.downloadSBOMMutex.RLock()
+	defer fake.downloadSBOMMutex.RUnlock()
+	return len(fake.downloadSBOMArgsForCall)
+}
+
+func (fake *FakeOciImplementation) DownloadSBOMCalls(stub func(options.Options, ocia.SignedEntity) (*sbom.Contents, error)) {
+	fake.downloadSBOMMutex.Lock()
+	defer fake.downloadSBOMMutex.Unlock()
+	fake.DownloadSBOMStub = stub
+}
+
+func (fake *FakeOciImplementation) DownloadSBOMArgsForCall(i int) (options.Options, ocia.SignedEntity) {
+	fake.downloadSBOMMutex.RLock()
+	defer fake.downloadSBOMMutex.RUnlock()
+	argsForCall := fake.downloadSBOMArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeOciImplementation) DownloadSBOMReturns(result1 *sbom.Contents, result2 error) {
+	fake.downloadSBOMMutex.Lock()
+	defer fake.downloadSBOMMutex.Unlock()
+	fake.DownloadSBOMStub = nil
+	fake.downloadSBOMReturns = struct {
+		result1 *sbom.Contents
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeOciImplementation) PurlToReference(arg1 options.Options, arg2 packageurl.PackageURL) (name.Reference, error) {
-	fake.purlToReferenceMutex.Lock()
-	ret, specificReturn := fake.purlToReferenceReturnsOnCall[len(fake.purlToReferenceArgsForCall)]
-	fake.purlToReferenceArgsForCall = append(fake.purlToReferenceArgsForCall, struct {
+func (fake *FakeOciImplementation) DownloadSBOMReturnsOnCall(i int, result1 *sbom.Contents, result2 error) {
+	fake.downloadSBOMMutex.Lock()
+	defer fake.downloadSBOMMutex.Unlock()
+	fake.DownloadSBOMStub = nil
+	if fake.downloadSBOMReturnsOnCall == nil {
+		fake.downloadSBOMReturnsOnCall = make(map[int]struct {
+			result1 *sbom.Contents
+			result2 error
+		})
+	}
+	fake.downloadSBOMReturnsOnCall[i] = struct {
+		result1 *sbom.Contents
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOciImplementation) PurlToReferences(arg1 options.Options, arg2 packageurl.PackageURL) ([]name.Reference, error) {
+	fake.purlToReferencesMutex.Lock()
+	ret, specificReturn := fake.purlToReferencesReturnsOnCall[len(fake.purlToReferencesArgsForCall)]
+	fake.purlToReferencesArgsForCall = append(fake.purlToReferencesArgsForCall, struct {
 		arg1 options.Options
 		arg2 packageurl.PackageURL
 	}{arg1, arg2})
-	stub := fake.PurlToReferenceStub
-	fakeReturns := fake.purlToReferenceReturns
-	fake.recordInvocation("PurlToReference", []interface{}{arg1, arg2})
-	fake.purlToReferenceMutex.Unlock()
+	stub := fake.PurlToReferencesStub
+	fakeReturns := fake.purlToReferencesReturns
+	fake.recordInvocation("PurlToReferences", []interface{}{arg1, arg2})
+	fake.purlToReferencesMutex.Unlock()
 	if stub != nil {
 		return stub(arg1, arg2)
 	}
@@ -154,47 +329,47 @@ func (fake *FakeOciImplementation) PurlToReference(arg1 options.Options, arg2 pa
 	return fakeReturns.result1, fakeReturns.result2
 }
 
-func (fake *FakeOciImplementation) PurlToReferenceCallCount() int {
-	fake.purlToReferenceMutex.RLock()
-	defer fake.purlToReferenceMutex.RUnlock()
-	return len(fake.purlToReferenceArgsForCall)
+func (fake *FakeOciImplementation) PurlToReferencesCallCount() int {
+	fake.purlToReferencesMutex.RLock()
+	defer fake.purlToReferencesMutex.RUnlock()
+	return len(fake.purlToReferencesArgsForCall)
 }
 
-func (fake *FakeOciImplementation) PurlToReferenceCalls(stub func(options.Options, packageurl.PackageURL) (name.Reference, error)) {
-	fake.purlToReferenceMutex.Lock()
-	defer fake.purlToReferenceMutex.Unlock()
-	fake.PurlToReferenceStub = stub
+func (fake *FakeOciImplementation) PurlToReferencesCalls(stub func(options.Options, packageurl.PackageURL) ([]name.Reference, error)) {
+	fake.purlToReferencesMutex.Lock()
+	defer fake.purlToReferencesMutex.Unlock()
+	fake.PurlToReferencesStub = stub
 }
 
-func (fake *FakeOciImplementation) PurlToReferenceArgsForCall(i int) (options.Options, packageurl.PackageURL) {
-	fake.purlToReferenceMutex.RLock()
-	defer fake.purlToReferenceMutex.RUnlock()
-	argsForCall := fake.purlToReferenceArgsForCall[i]
+func (fake *FakeOciImplementation) PurlToReferencesArgsForCall(i int) (options.Options, packageurl.PackageURL) {
+	fake.purlToReferencesMutex.RLock()
+	defer fake.purlToReferencesMutex.RUnlock()
+	argsForCall := fake.purlToReferencesArgsForCall[i]
 	return argsForCall.arg1, argsForCall.arg2
 }
 
-func (fake *FakeOciImplementation) PurlToReferenceReturns(result1 name.Reference, result2 error) {
-	fake.purlToReferenceMutex.Lock()
-	defer fake.purlToReferenceMutex.Unlock()
-	fake.PurlToReferenceStub = nil
-	fake.purlToReferenceReturns = struct {
-		result1 name.Reference
+func (fake *FakeOciImplementation) PurlToReferencesReturns(result1 []name.Reference, result2 error) {
+	fake.purlToReferencesMutex.Lock()
+	defer fake.purlToReferencesMutex.Unlock()
+	fake.PurlToReferencesStub = nil
+	fake.purlToReferencesReturns = struct {
+		result1 []name.Reference
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeOciImplementation) PurlToReferenceReturnsOnCall(i int, result1 name.Reference, result2 error) {
-	fake.purlToReferenceMutex.Lock()
-	defer fake.purlToReferenceMutex.Unlock()
-	fake.PurlToReferenceStub = nil
-	if fake.purlToReferenceReturnsOnCall == nil {
-		fake.purlToReferenceReturnsOnCall = make(map[int]struct {
-			result1 name.Reference
+func (fake *FakeOciImplementation) PurlToReferencesReturnsOnCall(i int, result1 []name.Reference, result2 error) {
+	fake.purlToReferencesMutex.Lock()
+	defer fake.purlToReferencesMutex.Unlock()
+	fake.PurlToReferencesStub = nil
+	if fake.purlToReferencesReturnsOnCall == nil {
+		fake.purlToReferencesReturnsOnCall = make(map[int]struct {
+			result1 []name.Reference
 			result2 error
 		})
 	}
-	fake.purlToReferenceReturnsOnCall[i] = struct {
-		result1 name.Reference
+	fake.purlToReferencesReturnsOnCall[i] = struct {
+		result1 []name.Reference
 		result2 error
 	}{result1, result2}
 }
@@ -264,6 +439,71 @@ func (fake *FakeOciImplementation) ResolveImageReferenceReturnsOnCall(i int, res
 	}{result1, result2}
 }
 
+func (fake *FakeOciImplementation) ResolvePlatformImages(arg1 options.Options, arg2 ocia.SignedEntity) (map[string]ocia.SignedImage, error) {
+	fake.resolvePlatformImagesMutex.Lock()
+	ret, specificReturn := fake.resolvePlatformImagesReturnsOnCall[len(fake.resolvePlatformImagesArgsForCall)]
+	fake.resolvePlatformImagesArgsForCall = append(fake.resolvePlatformImagesArgsForCall, struct {
+		arg1 options.Options
+		arg2 ocia.SignedEntity
+	}{arg1, arg2})
+	stub := fake.ResolvePlatformImagesStub
+	fakeReturns := fake.resolvePlatformImagesReturns
+	fake.recordInvocation("ResolvePlatformImages", []interface{}{arg1, arg2})
+	fake.resolvePlatformImagesMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeOciImplementation) ResolvePlatformImagesCallCount() int {
+	fake.resolvePlatformImagesMutex.RLock()
+	defer fake.resolvePlatformImagesMutex.RUnlock()
+	return len(fake.resolvePlatformImagesArgsForCall)
+}
+
+func (fake *FakeOciImplementation) ResolvePlatformImagesCalls(stub func(options.Options, ocia.SignedEntity) (map[string]ocia.SignedImage, error)) {
+	fake.resolvePlatformImagesMutex.Lock()
+	defer fake.resolvePlatformImagesMutex.Unlock()
+	fake.ResolvePlatformImagesStub = stub
+}
+
+func (fake *FakeOciImplementation) ResolvePlatformImagesArgsForCall(i int) (options.Options, ocia.SignedEntity) {
+	fake.resolvePlatformImagesMutex.RLock()
+	defer fake.resolvePlatformImagesMutex.RUnlock()
+	argsForCall := fake.resolvePlatformImagesArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeOciImplementation) ResolvePlatformImagesReturns(result1 map[string]ocia.SignedImage, result2 error) {
+	fake.resolvePlatformImagesMutex.Lock()
+	defer fake.resolvePlatformImagesMutex.Unlock()
+	fake.ResolvePlatformImagesStub = nil
+	fake.resolvePlatformImagesReturns = struct {
+		result1 map[string]ocia.SignedImage
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOciImplementation) ResolvePlatformImagesReturnsOnCall(i int, result1 map[string]ocia.SignedImage, result2 error) {
+	fake.resolvePlatformImagesMutex.Lock()
+	defer fake.resolvePlatformImagesMutex.Unlock()
+	fake.ResolvePlatformImagesStub = nil
+	if fake.resolvePlatformImagesReturnsOnCall == nil {
+		fake.resolvePlatformImagesReturnsOnCall = make(map[int]struct {
+			result1 map[string]ocia.SignedImage
+			result2 error
+		})
+	}
+	fake.resolvePlatformImagesReturnsOnCall[i] = struct {
+		result1 map[string]ocia.SignedImage
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeOciImplementation) VerifyOptions(arg1 *options.Options) error {
 	fake.verifyOptionsMutex.Lock()
 	ret, specificReturn := fake.verifyOptionsReturnsOnCall[len(fake.verifyOptionsArgsForCall)]
@@ -328,12 +568,18 @@ func (fake *FakeOciImplementation) VerifyOptionsReturnsOnCall(i int, result1 err
 func (fake *FakeOciImplementation) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.attestationSourceMutex.RLock()
+	defer fake.attestationSourceMutex.RUnlock()
 	fake.downloadDocumentsMutex.RLock()
 	defer fake.downloadDocumentsMutex.RUnlock()
-	fake.purlToReferenceMutex.RLock()
-	defer fake.purlToReferenceMutex.RUnlock()
+	fake.downloadSBOMMutex.RLock()
+	defer fake.downloadSBOMMutex.RUnlock()
+	fake.purlToReferencesMutex.RLock()
+	defer fake.purlToReferencesMutex.RUnlock()
 	fake.resolveImageReferenceMutex.RLock()
 	defer fake.resolveImageReferenceMutex.RUnlock()
+	fake.resolvePlatformImagesMutex.RLock()
+	defer fake.resolvePlatformImagesMutex.RUnlock()
 	fake.verifyOptionsMutex.RLock()
 	defer fake.verifyOptionsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -0,0 +1,258 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	purl "github.com/package-url/packageurl-go"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/validation"
+	doci "github.com/openvex/discovery/pkg/oci"
+)
+
+// SubjectPolicy defines what the prober does with attestations whose in-toto
+// subjects don't match the digest of the probed image.
+type SubjectPolicy string
+
+const (
+	// SubjectReject rejects the documents of mismatched attestations
+	SubjectReject SubjectPolicy = "reject"
+
+	// SubjectWarn returns the documents with a warning
+	SubjectWarn SubjectPolicy = "warn"
+)
+
+// Options are the settings of the OCI prober. They are passed to the prober
+// in the discovery options, keyed by the oci purl type:
+//
+//	opts = opts.WithProberOptions(purl.TypeOCI, oci.Options{Platform: "linux/amd64"})
+//
+// The prober also accepts a pointer to Options.
+type Options struct {
+	// Platform selects the image to probe when the purl points to a
+	// multi-arch index, eg "linux/arm64"
+	Platform string
+
+	// AllPlatforms makes the prober look for documents attached to the
+	// index and to each of the images it fronts. Documents are tagged
+	// with the platform of the image they were attached to.
+	AllPlatforms bool
+
+	// TagPrefix is prepended to the attestation tags
+	TagPrefix string
+
+	// RepositoryOverride is a repository to read the attestations from
+	// instead of the image repository. When not set, the prober reads
+	// it from the COSIGN_REPOSITORY environment variable.
+	RepositoryOverride string
+
+	// Mirrors are ordered rewrite rules applied to the image reference and
+	// to the repository override. The prober tries the mirrors in order
+	// before the original location.
+	Mirrors []doci.Mirror
+
+	// Keychain resolves the credentials to access the registries. When
+	// not set, the prober uses the default docker keychain.
+	Keychain authn.Keychain
+
+	// RetryPolicy controls how rate limited and failed registry requests
+	// are retried. When nil, the prober uses oci.DefaultRetryPolicy.
+	RetryPolicy *doci.RetryPolicy
+
+	// Limits caps the size and shape of the downloaded documents. When
+	// nil, the prober uses validation.DefaultLimits.
+	Limits *validation.Limits
+
+	// SubjectPolicy sets what to do with attestations whose subjects
+	// don't match the probed image. Defaults to SubjectReject.
+	SubjectPolicy SubjectPolicy
+
+	// CacheDir is a directory where the prober caches the attestation
+	// payloads it downloads, keyed by their digest. When empty, payloads
+	// are not cached.
+	CacheDir string
+
+	// Verification has the cosign settings used to verify the attestation
+	// signatures: the trusted roots or public key, the transparency log
+	// settings and, optionally, the allowed identities. Signatures that pass
+	// are marked as verified so trust policy identity rules can match them.
+	// When nil, the signatures are not verified.
+	Verification *cosign.CheckOpts
+
+	// retryCounter counts the retries of a probe, it is set by the prober
+	retryCounter *doci.RetryCounter
+}
+
+// Option is a functional option that modifies the prober options
+type Option func(*Options)
+
+// WithPlatform sets the platform to probe in multi-arch images
+func WithPlatform(platform string) Option {
+	return func(o *Options) {
+		o.Platform = platform
+	}
+}
+
+// WithAllPlatforms makes the prober probe all the images of multi-arch indexes
+func WithAllPlatforms() Option {
+	return func(o *Options) {
+		o.AllPlatforms = true
+	}
+}
+
+// WithTagPrefix sets the prefix of the attestation tags
+func WithTagPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.TagPrefix = prefix
+	}
+}
+
+// WithRepositoryOverride sets the repository to read attestations from
+func WithRepositoryOverride(repo string) Option {
+	return func(o *Options) {
+		o.RepositoryOverride = repo
+	}
+}
+
+// WithMirrors sets the registry mirror rules
+func WithMirrors(mirrors ...doci.Mirror) Option {
+	return func(o *Options) {
+		o.Mirrors = mirrors
+	}
+}
+
+// WithKeychain sets the keychain used to authenticate to registries
+func WithKeychain(keychain authn.Keychain) Option {
+	return func(o *Options) {
+		o.Keychain = keychain
+	}
+}
+
+// WithRetryPolicy sets the policy to retry registry requests
+func WithRetryPolicy(policy doci.RetryPolicy) Option {
+	return func(o *Options) {
+		o.RetryPolicy = &policy
+	}
+}
+
+// WithLimits sets the limits on the size and shape of downloaded documents
+func WithLimits(limits validation.Limits) Option {
+	return func(o *Options) {
+		o.Limits = &limits
+	}
+}
+
+// WithSubjectPolicy sets what to do with attestations whose subjects don't
+// match the probed image
+func WithSubjectPolicy(policy SubjectPolicy) Option {
+	return func(o *Options) {
+		o.SubjectPolicy = policy
+	}
+}
+
+// WithCacheDir sets the directory to cache the attestation payloads
+func WithCacheDir(dir string) Option {
+	return func(o *Options) {
+		o.CacheDir = dir
+	}
+}
+
+// WithVerification sets the cosign settings to verify attestation signatures
+func WithVerification(co *cosign.CheckOpts) Option {
+	return func(o *Options) {
+		o.Verification = co
+	}
+}
+
+// NewOptions returns a set of prober options with the functional
+// options applied.
+func NewOptions(fns ...Option) Options {
+	o := Options{}
+	for _, fn := range fns {
+		fn(&o)
+	}
+	return o
+}
+
+// Validate checks the options values and returns an error describing
+// everything wrong with them.
+func (o *Options) Validate() error {
+	errs := []error{}
+	if o.Platform != "" {
+		if _, err := v1.ParsePlatform(o.Platform); err != nil {
+			errs = append(errs, fmt.Errorf("invalid platform %q: %w", o.Platform, err))
+		}
+	}
+	if o.Platform != "" && o.AllPlatforms {
+		errs = append(errs, errors.New("platform and all platforms are mutually exclusive"))
+	}
+	if o.RepositoryOverride != "" {
+		if _, err := name.NewRepository(o.RepositoryOverride); err != nil {
+			errs = append(errs, fmt.Errorf("invalid repository override %q: %w", o.RepositoryOverride, err))
+		}
+	}
+	if o.RetryPolicy != nil {
+		if err := o.RetryPolicy.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid retry policy: %w", err))
+		}
+	}
+	if o.Limits != nil {
+		if err := o.Limits.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid document limits: %w", err))
+		}
+	}
+	switch o.SubjectPolicy {
+	case "", SubjectReject, SubjectWarn:
+	default:
+		errs = append(errs, fmt.Errorf("invalid subject policy %q", o.SubjectPolicy))
+	}
+	for i := range o.Mirrors {
+		if err := o.Mirrors[i].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid mirror rule #%d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// retryPolicy returns the retry policy to use
+func (o *Options) retryPolicy() doci.RetryPolicy {
+	if o.RetryPolicy != nil {
+		return *o.RetryPolicy
+	}
+	return doci.DefaultRetryPolicy
+}
+
+// limits returns the document limits to use
+func (o *Options) limits() validation.Limits {
+	if o.Limits != nil {
+		return *o.Limits
+	}
+	return validation.DefaultLimits
+}
+
+// GetOptions reads the OCI prober options from the discovery options. If
+// no options are set for the prober, it returns the zero value. Options of
+// any other type than Options or *Options return an error.
+func GetOptions(opts options.Options) (Options, error) {
+	switch o := opts.ProberOptions[purl.TypeOCI].(type) {
+	case nil:
+		return Options{}, nil
+	case Options:
+		return o, nil
+	case *Options:
+		if o == nil {
+			return Options{}, nil
+		}
+		return *o, nil
+	default:
+		return Options{}, fmt.Errorf("invalid options type for the oci prober: %T", o)
+	}
+}
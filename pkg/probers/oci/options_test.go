@@ -0,0 +1,98 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"testing"
+
+	purl "github.com/package-url/packageurl-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/validation"
+)
+
+func TestGetOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		proberOptions interface{}
+		expected      Options
+		mustErr       bool
+	}{
+		{"not set", nil, Options{}, false},
+		{"value", Options{Platform: "linux/amd64"}, Options{Platform: "linux/amd64"}, false},
+		{"pointer", &Options{TagPrefix: "vex"}, Options{TagPrefix: "vex"}, false},
+		{"nil pointer", (*Options)(nil), Options{}, false},
+		{"wrong type", map[string]string{"Platform": "linux/amd64"}, Options{}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.New().WithProberOptions(purl.TypeOCI, tc.proberOptions)
+			res, err := GetOptions(opts)
+			if tc.mustErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, res)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opts    Options
+		mustErr bool
+	}{
+		{"empty", Options{}, false},
+		{"valid", NewOptions(WithPlatform("linux/arm64/v8"), WithTagPrefix("vex"), WithRepositoryOverride("example.com/attestations")), false},
+		{"invalid platform", NewOptions(WithPlatform("linux/arm64/v8/extra")), true},
+		{"invalid repository", NewOptions(WithRepositoryOverride("example.com/UPPERCASE")), true},
+		{"invalid subject policy", NewOptions(WithSubjectPolicy("ignore")), true},
+		{"invalid limits", NewOptions(WithLimits(validation.Limits{MaxStatements: -1})), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.opts.Validate()
+			if tc.mustErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestVerifyOptions(t *testing.T) {
+	impl := defaultImplementation{}
+	for _, tc := range []struct {
+		name          string
+		proberOptions interface{}
+		mustErr       bool
+	}{
+		{"default", nil, false},
+		{"pointer", &Options{Platform: "linux/amd64"}, false},
+		{"wrong type", "linux/amd64", true},
+		{"invalid", Options{Platform: "linux/amd64/v1/extra"}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.Options{}
+			if tc.proberOptions != nil {
+				opts = opts.WithProberOptions(purl.TypeOCI, tc.proberOptions)
+			}
+			original := opts
+			err := impl.VerifyOptions(&opts)
+			if tc.mustErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, opts.Logger)
+			require.NotNil(t, opts.Context)
+			require.IsType(t, Options{}, opts.ProberOptions[purl.TypeOCI])
+			if original.ProberOptions != nil {
+				require.Equal(t, tc.proberOptions, original.ProberOptions[purl.TypeOCI])
+			}
+		})
+	}
+}
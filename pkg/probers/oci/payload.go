@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sigstore/cosign/v2/pkg/oci"
+
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/validation"
+	doci "github.com/openvex/discovery/pkg/oci"
+)
+
+// attestationPayload returns the payload of an attestation. The size of the
+// attestation layer is checked against the limits before downloading it and
+// the download stops past the limit, so oversized payloads are never read in
+// full. If the payload is too large, it returns the problems found instead.
+//
+// When the options have a cache directory, payloads are read from it and
+// stored in it after being downloaded. Failing to write the cache is not an
+// error.
+func attestationPayload(opts options.Options, ociOpts Options, sig oci.Signature) ([]byte, []string, error) {
+	limits := ociOpts.limits()
+	size, err := sig.Size()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading attestation size: %w", doci.ClassifyError(err))
+	}
+	if problems := limits.CheckPayloadSize(int(size)); problems != nil {
+		return nil, problems, nil
+	}
+
+	var cache *payloadCache
+	var digest v1.Hash
+	if ociOpts.CacheDir != "" {
+		digest, err = sig.Digest()
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading attestation digest: %w", doci.ClassifyError(err))
+		}
+		cache = &payloadCache{dir: ociOpts.CacheDir}
+		if payload, ok := cache.get(digest); ok {
+			return payload, nil, nil
+		}
+	}
+
+	payload, err := readPayload(sig, limits)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The registry may send more data than the layer descriptor says
+	if problems := limits.CheckPayloadSize(len(payload)); problems != nil {
+		return nil, problems, nil
+	}
+
+	if cache != nil {
+		if err := cache.put(digest, payload); err != nil {
+			opts.Logger.DebugContext(opts.Context, "caching attestation payload", "error", err)
+		}
+	}
+	return payload, nil, nil
+}
+
+// readPayload downloads the raw bytes of an attestation layer. It reads at
+// most one byte past the size limit, enough to tell the payload is too large.
+func readPayload(sig oci.Signature, limits validation.Limits) ([]byte, error) {
+	rc, err := sig.Compressed()
+	if err != nil {
+		return nil, fmt.Errorf("fetching attestation payload: %w", doci.ClassifyError(err))
+	}
+	defer rc.Close()
+
+	var r io.Reader = rc
+	if limits.MaxPayloadSize > 0 {
+		r = io.LimitReader(rc, int64(limits.MaxPayloadSize)+1)
+	}
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading attestation payload: %w", doci.ClassifyError(err))
+	}
+	return payload, nil
+}
+
+// signature is an alias to embed oci.Signature, its Signature method
+// collides with the name of the embedded field.
+type signature = oci.Signature
+
+// payloadSignature is an attestation whose payload was already read, it
+// keeps cosign from downloading it again when verifying the signature.
+type payloadSignature struct {
+	signature
+	payload []byte
+}
+
+// Payload returns the payload read before
+func (ps *payloadSignature) Payload() ([]byte, error) {
+	return ps.payload, nil
+}
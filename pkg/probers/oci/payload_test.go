@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/validation"
+)
+
+// sizedSignature is an attestation with a fixed layer size and payload. It
+// records if the payload was downloaded.
+type sizedSignature struct {
+	signature
+	size       int64
+	payload    []byte
+	downloaded bool
+}
+
+func (s *sizedSignature) Size() (int64, error) {
+	return s.size, nil
+}
+
+func (s *sizedSignature) Compressed() (io.ReadCloser, error) {
+	s.downloaded = true
+	return io.NopCloser(bytes.NewReader(s.payload)), nil
+}
+
+func TestAttestationPayloadLimits(t *testing.T) {
+	ociOpts := NewOptions(WithLimits(validation.Limits{MaxPayloadSize: 10}))
+	for _, tc := range []struct {
+		name       string
+		sig        *sizedSignature
+		downloaded bool
+		rejected   bool
+	}{
+		{"within limits", &sizedSignature{size: 5, payload: []byte("small")}, true, false},
+		{"layer too large", &sizedSignature{size: 11, payload: []byte("not downloaded")}, false, true},
+		{"payload larger than layer", &sizedSignature{size: 5, payload: bytes.Repeat([]byte("x"), 100)}, true, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, problems, err := attestationPayload(options.New(), ociOpts, tc.sig)
+			require.NoError(t, err)
+			require.Equal(t, tc.downloaded, tc.sig.downloaded)
+			if tc.rejected {
+				require.Len(t, problems, 1)
+				require.Nil(t, payload)
+				return
+			}
+			require.Nil(t, problems)
+			require.Equal(t, tc.sig.payload, payload)
+		})
+	}
+}
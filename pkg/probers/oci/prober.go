@@ -6,9 +6,12 @@ package oci
 //go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -generate
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	purl "github.com/package-url/packageurl-go"
@@ -17,13 +20,22 @@ import (
 	"github.com/sigstore/cosign/v2/pkg/oci"
 	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
 	"github.com/sigstore/cosign/v2/pkg/types"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 
 	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/discovery/telemetry"
+	"github.com/openvex/discovery/pkg/discovery/validation"
+	"github.com/openvex/discovery/pkg/errdefs"
+	"github.com/openvex/discovery/pkg/normalize"
 	doci "github.com/openvex/discovery/pkg/oci"
-	"github.com/openvex/go-vex/pkg/attestation"
+	"github.com/openvex/discovery/pkg/sbom"
+	"github.com/openvex/discovery/pkg/vexref"
 	"github.com/openvex/go-vex/pkg/vex"
 )
 
@@ -35,29 +47,25 @@ type Prober struct {
 func New() *Prober {
 	p := &Prober{
 		impl:    &defaultImplementation{},
-		Options: options.Default,
+		Options: options.New(),
 	}
-	p.Options.ProberOptions[purl.TypeOCI] = localOptions{}
+	p.Options.ProberOptions[purl.TypeOCI] = Options{}
 	return p
 }
 
 //counterfeiter:generate . ociImplementation
 type ociImplementation interface {
 	VerifyOptions(*options.Options) error
-	PurlToReference(options.Options, purl.PackageURL) (name.Reference, error)
+	PurlToReferences(options.Options, purl.PackageURL) ([]name.Reference, error)
 	ResolveImageReference(options.Options, name.Reference) (oci.SignedEntity, error)
-	DownloadDocuments(options.Options, oci.SignedEntity) ([]*vex.VEX, error)
+	DownloadDocuments(options.Options, oci.SignedEntity) ([]*results.Document, error)
+	AttestationSource(options.Options, name.Reference, oci.SignedEntity) (string, error)
+	DownloadSBOM(options.Options, oci.SignedEntity) (*sbom.Contents, error)
+	ResolvePlatformImages(options.Options, oci.SignedEntity) (map[string]oci.SignedImage, error)
 }
 
 type defaultImplementation struct{}
 
-type localOptions struct {
-	Platform           string
-	TagPrefix          string // Attestation "image" prefix
-	Repository         string
-	RepositoryOverride string // COSIGN_REPOSITORY or other repo that overrides the purl repo
-}
-
 type platformList []struct {
 	hash     v1.Hash
 	platform *v1.Platform
@@ -74,45 +82,269 @@ func (pl *platformList) String() string {
 // FindDocumentsFromPurl implements the logic to search for OpenVEX documents
 // attached to a container image
 func (prober *Prober) FindDocumentsFromPurl(opts options.Options, p purl.PackageURL) ([]*vex.VEX, error) {
-	if err := prober.impl.VerifyOptions(&prober.Options); err != nil {
+	docs, err := prober.FindDocumentsWithProvenance(opts, p)
+	if err != nil {
+		return nil, err
+	}
+	return results.ToVEX(docs), nil
+}
+
+// FindDocumentsWithProvenance searches for OpenVEX documents attached to a
+// container image and returns them along with the signer data of the
+// attestations that wrap them. The prober uses the options passed in the
+// call, it does not modify its own state so it is safe for concurrent use.
+//
+// When mirrors are configured, the image and attestation locations they
+// produce are tried in order. The prober returns the results of the first
+// location that answers, even if it has no documents, and only tries the
+// next one when a location fails.
+func (prober *Prober) FindDocumentsWithProvenance(opts options.Options, p purl.PackageURL) ([]*results.Document, error) {
+	// Work on a copy of the options as VerifyOptions may complete them
+	popts := opts
+	if err := prober.impl.VerifyOptions(&popts); err != nil {
 		return nil, fmt.Errorf("verifying options: %w", err)
 	}
 
-	ref, err := prober.impl.PurlToReference(prober.Options, p)
+	sopts, span := telemetry.Start(popts, "oci.PurlToReference", telemetry.AttrPurl.String(p.String()))
+	refs, err := prober.impl.PurlToReferences(sopts, p)
+	telemetry.End(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("translating purl to image reference: %w", err)
 	}
 
+	if len(refs) == 0 {
+		return nil, errdefs.New(errdefs.ErrParse, "could not resolve image reference from %s", p)
+	}
+
+	// Count the retries of the probe to report them in the results
+	ociOpts, err := GetOptions(popts)
+	if err != nil {
+		return nil, err
+	}
+	counter := &doci.RetryCounter{}
+	ociOpts.retryCounter = counter
+	popts = popts.WithProberOptions(purl.TypeOCI, ociOpts)
+
+	candidates, err := repositoryCandidates(popts)
+	if err != nil {
+		return nil, fmt.Errorf("resolving attestation repositories: %w", err)
+	}
+
+	errs := []error{}
+	for _, ref := range refs {
+		for _, copts := range candidates {
+			docs, err := prober.findDocumentsAt(copts, ref, p)
+			if err != nil {
+				popts.Logger.DebugContext(
+					popts.Context, "probing image location failed", "reference", ref.String(), "error", err,
+				)
+				errs = append(errs, err)
+				continue
+			}
+			setRetryStats(docs, counter)
+			return docs, nil
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// setRetryStats records the retries of the probe in the documents found
+func setRetryStats(docs []*results.Document, counter *doci.RetryCounter) {
+	stats := counter.Stats()
+	for _, d := range docs {
+		d.Provenance.Retries = results.RetryStats{Retries: stats.Retries, RateLimited: stats.RateLimited}
+	}
+}
+
+// findDocumentsAt downloads the documents attached to the image at ref
+func (prober *Prober) findDocumentsAt(opts options.Options, ref name.Reference, p purl.PackageURL) ([]*results.Document, error) {
 	if ref == nil {
-		return nil, fmt.Errorf("could not resolve image reference from %s", p)
+		return nil, errdefs.New(errdefs.ErrParse, "could not resolve image reference from %s", p)
 	}
 
-	image, err := prober.impl.ResolveImageReference(prober.Options, ref)
+	sopts, span := telemetry.Start(opts, "oci.ResolveImageReference", telemetry.AttrReference.String(ref.String()))
+	image, err := prober.impl.ResolveImageReference(sopts, ref)
+	telemetry.End(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("resolving image reference: %w", err)
 	}
 
-	docs, err := prober.impl.DownloadDocuments(prober.Options, image)
+	sopts, span = telemetry.Start(opts, "oci.DownloadDocuments", telemetry.AttrReference.String(ref.String()))
+	docs, err := prober.impl.DownloadDocuments(sopts, image)
+	telemetry.End(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("downloading documents from registry: %w", err)
 	}
 
+	source, err := prober.impl.AttestationSource(opts, ref, image)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range docs {
+		d.Provenance.Prober = purl.TypeOCI
+		d.Provenance.Purl = p.String()
+		d.Provenance.Source = source
+	}
+
+	ociOpts, err := GetOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if !ociOpts.AllPlatforms {
+		return docs, nil
+	}
+
+	platformDocs, err := prober.findPlatformDocuments(opts, ref, image, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(docs, platformDocs...), nil
+}
+
+// repositoryCandidates returns a set of options for each repository where
+// the attestations may be stored. If there is a repository override and it
+// matches any mirror rules, the mirrors are returned first.
+func repositoryCandidates(opts options.Options) ([]options.Options, error) {
+	ociOpts, err := GetOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(ociOpts.Mirrors) == 0 {
+		return []options.Options{opts}, nil
+	}
+
+	override := ociOpts.RepositoryOverride
+	if override == "" {
+		repo, err := ociremote.GetEnvTargetRepository()
+		if err != nil {
+			return nil, fmt.Errorf("fetching repository from environment: %w", err)
+		}
+		if (repo != name.Repository{}) {
+			override = repo.Name()
+		}
+	}
+	if override == "" {
+		return []options.Options{opts}, nil
+	}
+
+	repos, err := doci.RewriteRepository(override, ociOpts.Mirrors)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]options.Options, 0, len(repos))
+	for _, repo := range repos {
+		o := ociOpts
+		o.RepositoryOverride = repo
+		ret = append(ret, opts.WithProberOptions(purl.TypeOCI, o))
+	}
+	return ret, nil
+}
+
+// findPlatformDocuments downloads the documents attached to each of the images
+// fronted by an index. The documents are tagged with the image platform.
+func (prober *Prober) findPlatformDocuments(
+	opts options.Options, ref name.Reference, image oci.SignedEntity, p purl.PackageURL,
+) ([]*results.Document, error) {
+	images, err := prober.impl.ResolvePlatformImages(opts, image)
+	if err != nil {
+		return nil, fmt.Errorf("resolving platform images: %w", err)
+	}
+
+	platforms, err := indexPlatforms(image)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort the images by platform to return the documents in a stable order.
+	// Images with the same platform string are sorted by digest.
+	digests := make([]string, 0, len(images))
+	for digest := range images {
+		digests = append(digests, digest)
+	}
+	sort.Slice(digests, func(i, j int) bool {
+		if platforms[digests[i]] != platforms[digests[j]] {
+			return platforms[digests[i]] < platforms[digests[j]]
+		}
+		return digests[i] < digests[j]
+	})
+
+	docs := []*results.Document{}
+	for _, digest := range digests {
+		platform := platforms[digest]
+		sopts, span := telemetry.Start(
+			opts, "oci.DownloadDocuments",
+			telemetry.AttrReference.String(ref.Context().Digest(digest).String()),
+		)
+		pdocs, err := prober.impl.DownloadDocuments(sopts, images[digest])
+		telemetry.End(span, err)
+		if err != nil {
+			return nil, fmt.Errorf("downloading documents of %s image: %w", platform, err)
+		}
+
+		source, err := prober.impl.AttestationSource(opts, ref, images[digest])
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range pdocs {
+			d.Provenance.Prober = purl.TypeOCI
+			d.Provenance.Purl = p.String()
+			d.Provenance.Source = source
+			d.Provenance.Platform = platform
+		}
+		docs = append(docs, pdocs...)
+	}
 	return docs, nil
 }
 
-// PurlToReference reads a purl and generates an image reference. It uses GGCR's
-// name package to parse it and returns the reference.
-func (di *defaultImplementation) PurlToReference(opts options.Options, p purl.PackageURL) (name.Reference, error) {
-	refString, err := doci.PurlToReferenceString(p.String())
+// PurlToReferences reads a purl and generates the image references to probe.
+// It returns a reference for each mirror rule matching the image followed by
+// the original reference. It uses GGCR's name package to parse them.
+func (di *defaultImplementation) PurlToReferences(opts options.Options, p purl.PackageURL) ([]name.Reference, error) {
+	ociOpts, err := GetOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Normalizing the purl cleans up its repository_url before converting it
+	np, err := normalize.PackageURL(p)
+	if err != nil {
+		return nil, err
+	}
+
+	refStrings, err := doci.PurlToReferenceStrings(np.String(), doci.WithMirrors(ociOpts.Mirrors))
 	if err != nil {
 		return nil, err
 	}
 
-	ref, err := name.ParseReference(refString)
+	refs := make([]name.Reference, 0, len(refStrings))
+	for _, refString := range refStrings {
+		ref, err := name.ParseReference(refString)
+		if err != nil {
+			return nil, errdefs.New(errdefs.ErrParse, "parsing reference %s: %w", refString, err)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// indexPlatforms returns the platform strings of the images fronted by an
+// index, keyed by their digest. It returns an empty map for single images.
+func indexPlatforms(se oci.SignedEntity) (map[string]string, error) {
+	platforms := map[string]string{}
+	idx, isIndex := se.(oci.SignedImageIndex)
+	if !isIndex {
+		return platforms, nil
+	}
+	list, err := getIndexPlatforms(idx)
 	if err != nil {
-		return nil, fmt.Errorf("parsing reference %s: %w", refString, err)
+		return nil, fmt.Errorf("getting available platforms: %w", doci.ClassifyError(err))
 	}
-	return ref, nil
+	for _, p := range list {
+		platforms[p.hash.String()] = p.platform.String()
+	}
+	return platforms, nil
 }
 
 // getIndexPlatforms returns the platforms of the single arch images fronted by
@@ -144,30 +376,36 @@ func (di *defaultImplementation) ResolveImageReference(opts options.Options, ref
 		return nil, fmt.Errorf("got nil value when trying to resolve OCI image reference")
 	}
 
-	ociremoteOpts := []ociremote.Option{}
+	ociOpts, err := GetOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Setting the remote options replaces the ociremote defaults, so
+	// the keychain needs to be set here too.
+	keychain := authn.DefaultKeychain
+	if ociOpts.Keychain != nil {
+		keychain = ociOpts.Keychain
+	}
+	remoteOpts := []remote.Option{remote.WithAuthFromKeychain(keychain)}
+	remoteOpts = append(remoteOpts, ociOpts.retryPolicy().RemoteOptions(ociOpts.retryCounter)...)
+
+	// Pass the context to the registry calls to support cancellation
+	if opts.Context != nil {
+		remoteOpts = append(remoteOpts, remote.WithContext(opts.Context))
+	}
 
-	// TODO(puerco): Support relevant registry options
-	// o := options.RegistryOptions{}
-	// ociremoteOpts := []ociremote.Option{ociremote.WithRemoteOptions(o.GetRegistryClientOpts(ctx)...)}
+	ociremoteOpts := []ociremote.Option{ociremote.WithRemoteOptions(remoteOpts...)}
 
 	// Support tag prefix
-	if opts.ProberOptions[purl.TypeOCI].(localOptions).TagPrefix != "" {
-		ociremoteOpts = append(ociremoteOpts, ociremote.WithPrefix(opts.ProberOptions[purl.TypeOCI].(localOptions).TagPrefix))
+	if ociOpts.TagPrefix != "" {
+		ociremoteOpts = append(ociremoteOpts, ociremote.WithPrefix(ociOpts.TagPrefix))
 	}
 
 	// Registry override. From options or env
-	var targetRepoOverride name.Repository
-	var err error
-	if opts.ProberOptions[purl.TypeOCI].(localOptions).RepositoryOverride != "" {
-		targetRepoOverride, err = name.NewRepository(opts.ProberOptions[purl.TypeOCI].(localOptions).RepositoryOverride)
-		if err != nil {
-			return nil, fmt.Errorf("parsing override repository option")
-		}
-	} else {
-		targetRepoOverride, err = ociremote.GetEnvTargetRepository()
-		if err != nil {
-			return nil, fmt.Errorf("fetching repository from environment")
-		}
+	targetRepoOverride, err := targetRepository(ociOpts)
+	if err != nil {
+		return nil, err
 	}
 	if (targetRepoOverride != name.Repository{}) {
 		ociremoteOpts = append(ociremoteOpts, ociremote.WithTargetRepository(targetRepoOverride))
@@ -175,25 +413,25 @@ func (di *defaultImplementation) ResolveImageReference(opts options.Options, ref
 
 	se, err := ociremote.SignedEntity(ref, ociremoteOpts...)
 	if err != nil {
-		return nil, err
+		return nil, doci.ClassifyError(err)
 	}
 
 	idx, isIndex := se.(oci.SignedImageIndex)
 
 	// We only allow --platform on multiarch indexes
-	if opts.ProberOptions[purl.TypeOCI].(localOptions).Platform != "" && !isIndex {
-		return nil, fmt.Errorf("specified reference is not a multiarch image")
+	if ociOpts.Platform != "" && !isIndex {
+		return nil, errdefs.New(errdefs.ErrInvalidArgument, "specified reference is not a multiarch image")
 	}
 
 	// If a platform was specified, then we return the corresponding
 	// single arch image if there is one
-	if opts.ProberOptions[purl.TypeOCI].(localOptions).Platform != "" && isIndex {
+	if ociOpts.Platform != "" && isIndex {
 		opts.Logger.DebugContext(
 			opts.Context, "Reference is an index and arch %s defined", "imageRef", ref.String(),
 		)
-		targetPlatform, err := v1.ParsePlatform(opts.ProberOptions[purl.TypeOCI].(localOptions).Platform)
+		targetPlatform, err := v1.ParsePlatform(ociOpts.Platform)
 		if err != nil {
-			return nil, fmt.Errorf("parsing platform: %w", err)
+			return nil, errdefs.New(errdefs.ErrParse, "parsing platform: %w", err)
 		}
 		platforms, err := getIndexPlatforms(idx)
 		if err != nil {
@@ -202,7 +440,7 @@ func (di *defaultImplementation) ResolveImageReference(opts options.Options, ref
 
 		platforms = matchPlatform(targetPlatform, platforms)
 		if len(platforms) == 0 {
-			return nil, fmt.Errorf("unable to find an attestation for %s", targetPlatform.String())
+			return nil, errdefs.New(errdefs.ErrNotFound, "unable to find an attestation for %s", targetPlatform.String())
 		}
 		if len(platforms) > 1 {
 			return nil, fmt.Errorf(
@@ -213,7 +451,7 @@ func (di *defaultImplementation) ResolveImageReference(opts options.Options, ref
 
 		nse, err := idx.SignedImage(platforms[0].hash)
 		if err != nil {
-			return nil, fmt.Errorf("searching for %s image: %w", platforms[0].hash.String(), err)
+			return nil, fmt.Errorf("searching for %s image: %w", platforms[0].hash.String(), doci.ClassifyError(err))
 		}
 		if nse == nil {
 			return nil, fmt.Errorf("unable to find image %s", platforms[0].hash.String())
@@ -224,6 +462,39 @@ func (di *defaultImplementation) ResolveImageReference(opts options.Options, ref
 	return se, nil
 }
 
+// ResolvePlatformImages returns the single arch images fronted by an index,
+// keyed by their manifest digest. Platforms are not unique keys, images may
+// only differ in their OS version or features. If the signed entity is not
+// an index, it returns an empty map.
+func (di *defaultImplementation) ResolvePlatformImages(opts options.Options, se oci.SignedEntity) (map[string]oci.SignedImage, error) {
+	images := map[string]oci.SignedImage{}
+	idx, isIndex := se.(oci.SignedImageIndex)
+	if !isIndex {
+		return images, nil
+	}
+
+	platforms, err := getIndexPlatforms(idx)
+	if err != nil {
+		return nil, fmt.Errorf("getting available platforms: %w", err)
+	}
+
+	for _, p := range platforms {
+		// Skip the attestation manifests added by buildkit
+		if p.platform.OS == "unknown" {
+			continue
+		}
+		img, err := idx.SignedImage(p.hash)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s image: %w", p.platform.String(), doci.ClassifyError(err))
+		}
+		if img == nil {
+			return nil, fmt.Errorf("unable to find image %s", p.hash.String())
+		}
+		images[p.hash.String()] = img
+	}
+	return images, nil
+}
+
 // matchPlatform filters a list of platforms returning only those matching
 // a base. "Based" on ko's internal equivalent while it moves to GGCR.
 // https://github.com/google/ko/blob/e6a7a37e26d82a8b2bb6df991c5a6cf6b2728794/pkg/build/gobuild.go#L1020
@@ -259,63 +530,347 @@ func matchPlatform(base *v1.Platform, list platformList) platformList {
 	return ret
 }
 
-// DownloadDocuments retrieves attested or attached document from the registry
-func (di *defaultImplementation) DownloadDocuments(opts options.Options, se oci.SignedEntity) ([]*vex.VEX, error) {
-	docs := []*vex.VEX{}
+// targetRepository returns the repository override where the attestations
+// are stored, read from the options or the environment. It returns an empty
+// repository when there is no override.
+func targetRepository(ociOpts Options) (name.Repository, error) {
+	if ociOpts.RepositoryOverride != "" {
+		repo, err := name.NewRepository(ociOpts.RepositoryOverride)
+		if err != nil {
+			return name.Repository{}, errdefs.New(errdefs.ErrParse, "parsing override repository option: %w", err)
+		}
+		return repo, nil
+	}
+	repo, err := ociremote.GetEnvTargetRepository()
+	if err != nil {
+		return name.Repository{}, fmt.Errorf("fetching repository from environment: %w", err)
+	}
+	return repo, nil
+}
 
-	attestations, err := cosign.FetchAttestations(se, vex.Context)
+// AttestationSource returns the location the attestations of an image are
+// read from: the attestation tag in the image repository or, when set, in the
+// override repository.
+func (di *defaultImplementation) AttestationSource(opts options.Options, ref name.Reference, se oci.SignedEntity) (string, error) {
+	ociOpts, err := GetOptions(opts)
 	if err != nil {
-		// If the image has no attestations attached, cosign returns  an
-		// error. Trap it here and handle properly
-		if err.Error() == "found no attestations" {
-			opts.Logger.DebugContext(opts.Context, "image has no attestations attached")
-			return docs, nil
-		}
+		return "", err
+	}
 
-		return nil, fmt.Errorf("fetching attestations: %w", err)
+	d, err := se.Digest()
+	if err != nil {
+		return "", fmt.Errorf("reading image digest: %w", doci.ClassifyError(err))
 	}
 
-	opts.Logger.DebugContext(
-		opts.Context, fmt.Sprintf("image has %d OpenVEX attestations", len(attestations)),
-	)
-	for i, att := range attestations {
-		if att.PayloadType != types.IntotoPayloadType {
-			continue
-		}
+	ociremoteOpts := []ociremote.Option{}
+	if ociOpts.TagPrefix != "" {
+		ociremoteOpts = append(ociremoteOpts, ociremote.WithPrefix(ociOpts.TagPrefix))
+	}
+	target, err := targetRepository(ociOpts)
+	if err != nil {
+		return "", err
+	}
+	if (target != name.Repository{}) {
+		ociremoteOpts = append(ociremoteOpts, ociremote.WithTargetRepository(target))
+	}
 
-		pload, err := base64.StdEncoding.DecodeString(att.PayLoad)
+	// The reference has the digest so computing the tag needs no registry calls
+	tag, err := ociremote.AttestationTag(ref.Context().Digest(d.String()), ociremoteOpts...)
+	if err != nil {
+		return "", fmt.Errorf("computing attestation tag: %w", err)
+	}
+	return tag.String(), nil
+}
+
+// DownloadDocuments retrieves attested or attached document from the registry.
+// The returned documents record the signer data read from the attestation
+// certificates. The signatures are only marked as verified when the options
+// have verification settings and the attestation signature checks out.
+func (di *defaultImplementation) DownloadDocuments(opts options.Options, se oci.SignedEntity) ([]*results.Document, error) {
+	docs := []*results.Document{}
+
+	atts, err := se.Attestations()
+	if err != nil {
+		return nil, fmt.Errorf("fetching attestations: %w", doci.ClassifyError(err))
+	}
+
+	sigs, err := atts.Get()
+	if err != nil {
+		return nil, fmt.Errorf("fetching attestations: %w", doci.ClassifyError(err))
+	}
+
+	// If the image has no attestations attached, there is nothing to do
+	if len(sigs) == 0 {
+		opts.Logger.DebugContext(opts.Context, "image has no attestations attached")
+		return docs, nil
+	}
+
+	ociOpts, err := GetOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	digests, err := entityDigests(se)
+	if err != nil {
+		return nil, fmt.Errorf("reading image digests: %w", doci.ClassifyError(err))
+	}
+	check := attestationCheck{limits: ociOpts.limits(), digests: digests, subjectPolicy: ociOpts.SubjectPolicy}
+
+	for i, sig := range sigs {
+		rawPayload, problems, err := attestationPayload(opts, ociOpts, sig)
 		if err != nil {
-			opts.Logger.WarnContext(
-				opts.Context, fmt.Sprintf("error decoding openvex attestation %d from base64, ignoring", i),
+			return nil, err
+		}
+		if problems != nil {
+			opts.Logger.DebugContext(
+				opts.Context, fmt.Sprintf("rejecting attestation #%d", i), "problems", problems,
 			)
+			docs = append(docs, &results.Document{Problems: problems})
 			continue
 		}
 
-		statement := attestation.Attestation{}
-
-		// If the attestation could not be parsed, then we ignore it and
-		// issue a warning
-		if err := json.Unmarshal(pload, &statement); err != nil {
+		doc, envelope, subjects, ok := check.read(rawPayload)
+		if !ok {
+			continue
+		}
+		if doc.Rejected() {
+			opts.Logger.DebugContext(
+				opts.Context, fmt.Sprintf("rejecting openvex attestation #%d", i), "problems", doc.Problems,
+			)
+		}
+		if len(doc.Warnings) > 0 {
 			opts.Logger.WarnContext(
-				opts.Context, fmt.Sprintf("error parsing openvex attestation #%d, ignoring", i),
+				opts.Context, fmt.Sprintf("openvex attestation #%d has problems", i), "warnings", doc.Warnings,
 			)
-			continue
 		}
 
-		docs = append(docs, &statement.Predicate)
+		doc.Provenance.Signatures = attestationSigners(sig, envelope)
+		if ociOpts.Verification != nil {
+			verifyAttestation(
+				opts.Context, &payloadSignature{signature: sig, payload: rawPayload}, subjects, ociOpts.Verification, doc,
+			)
+		}
+		docs = append(docs, doc)
 	}
 
+	opts.Logger.DebugContext(
+		opts.Context, fmt.Sprintf("image has %d OpenVEX attestations", len(docs)),
+	)
+
 	return docs, nil
 }
 
-// VerifyOptions checks the options and returns an error if there is something wrong
+// intotoStatement is an in-toto statement with its predicate left unparsed
+// until its type is known.
+type intotoStatement struct {
+	Subject []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// entityDigests returns the digests the subject of an attestation attached to
+// a signed entity may have: the digest of the entity and, for an index, the
+// digests of the images it fronts.
+func entityDigests(se oci.SignedEntity) ([]v1.Hash, error) {
+	d, err := se.Digest()
+	if err != nil {
+		return nil, err
+	}
+	digests := []v1.Hash{d}
+
+	idx, isIndex := se.(oci.SignedImageIndex)
+	if !isIndex {
+		return digests, nil
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	for i := range im.Manifests {
+		digests = append(digests, im.Manifests[i].Digest)
+	}
+	return digests, nil
+}
+
+// attestationCheck holds the data to validate the attestations of an image
+type attestationCheck struct {
+	limits        validation.Limits
+	digests       []v1.Hash
+	subjectPolicy SubjectPolicy
+}
+
+// matchedDigests returns the image digests found in the statement subjects
+func (check *attestationCheck) matchedDigests(statement *intotoStatement) []v1.Hash {
+	matched := []v1.Hash{}
+	for _, d := range check.digests {
+		for _, s := range statement.Subject {
+			if s.Digest[d.Algorithm] == d.Hex {
+				matched = append(matched, d)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// read parses an attestation payload and validates the OpenVEX document in
+// it. Along with the document, it returns the image digests matched by the
+// attestation subjects. It returns false if the attestation is not an OpenVEX
+// attestation. Attestations that can't be read or fail validation are
+// returned as rejected documents with the problems found.
+func (check *attestationCheck) read(rawPayload []byte) (*results.Document, cosign.AttestationPayload, []v1.Hash, bool) {
+	envelope := cosign.AttestationPayload{}
+	rejected := func(format string, args ...any) (*results.Document, cosign.AttestationPayload, []v1.Hash, bool) {
+		return &results.Document{Problems: []string{fmt.Sprintf(format, args...)}}, envelope, nil, true
+	}
+
+	if problems := check.limits.CheckPayloadSize(len(rawPayload)); problems != nil {
+		return &results.Document{Problems: problems}, envelope, nil, true
+	}
+
+	envelope, statement, err := decodeStatement(rawPayload)
+	if err != nil {
+		return rejected("%s", err)
+	}
+	if statement == nil {
+		return nil, envelope, nil, false
+	}
+
+	// Skip attestations of other predicate types
+	if !validation.IsVEXPredicateType(statement.PredicateType) {
+		return nil, envelope, nil, false
+	}
+
+	doc := &results.Document{VEX: &vex.VEX{}}
+	if err := json.Unmarshal(statement.Predicate, doc.VEX); err != nil {
+		return rejected("parsing openvex document: %s", err)
+	}
+
+	if problems := validation.Document(doc.VEX, check.limits); len(problems) > 0 {
+		doc.Problems = problems
+	}
+
+	// The predicate parsed as a document, so its references can be read
+	doc.References, _ = vexref.FromDocument(statement.Predicate)
+
+	subjects := check.matchedDigests(statement)
+	if len(subjects) == 0 {
+		msg := "attestation subjects don't match the image digest"
+		if check.subjectPolicy == SubjectWarn {
+			doc.Warnings = append(doc.Warnings, msg)
+		} else {
+			doc.Problems = append(doc.Problems, msg)
+		}
+	}
+	return doc, envelope, subjects, true
+}
+
+// decodeStatement parses an attestation envelope and the in-toto statement
+// in it. The returned statement is nil if the payload is not in-toto.
+func decodeStatement(rawPayload []byte) (cosign.AttestationPayload, *intotoStatement, error) {
+	envelope := cosign.AttestationPayload{}
+	if err := json.Unmarshal(rawPayload, &envelope); err != nil {
+		return envelope, nil, fmt.Errorf("parsing attestation envelope: %w", err)
+	}
+
+	if envelope.PayloadType != types.IntotoPayloadType {
+		return envelope, nil, nil
+	}
+
+	pload, err := base64.StdEncoding.DecodeString(envelope.PayLoad)
+	if err != nil {
+		return envelope, nil, fmt.Errorf("decoding attestation payload: %w", err)
+	}
+
+	statement := &intotoStatement{}
+	if err := json.Unmarshal(pload, statement); err != nil {
+		return envelope, nil, fmt.Errorf("parsing in-toto statement: %w", err)
+	}
+	return envelope, statement, nil
+}
+
+// attestationSigners returns the signer data of an attestation. The identity
+// and issuer are read from the signing certificate when there is one.
+func attestationSigners(sig oci.Signature, envelope cosign.AttestationPayload) []results.Signature {
+	signer := results.Signature{}
+	if len(envelope.Signatures) > 0 {
+		signer.KeyID = envelope.Signatures[0].KeyID
+	}
+
+	cert, err := sig.Cert()
+	if err == nil && cert != nil {
+		if sans := cryptoutils.GetSubjectAlternateNames(cert); len(sans) > 0 {
+			signer.Identity = sans[0]
+		}
+		ce := cosign.CertExtensions{Cert: cert}
+		signer.Issuer = ce.GetIssuer()
+	}
+
+	if signer == (results.Signature{}) {
+		return nil
+	}
+	return []results.Signature{signer}
+}
+
+// verifyAttestation checks the attestation signature with cosign for each of
+// the image digests its subjects matched, and marks the document signatures
+// as verified if all checks pass. Failures are recorded as warnings, deciding
+// if unverified documents are trusted is left to the trust policy.
+func verifyAttestation(ctx context.Context, sig oci.Signature, digests []v1.Hash, co *cosign.CheckOpts, doc *results.Document) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if len(digests) == 0 {
+		doc.Warnings = append(doc.Warnings, "attestation signature not verified: no subject matches the image")
+		return
+	}
+
+	for _, digest := range digests {
+		// cosign modifies the check options when the signature has a chain
+		checkOpts := *co
+		if checkOpts.ClaimVerifier == nil {
+			checkOpts.ClaimVerifier = cosign.IntotoSubjectClaimVerifier
+		}
+		if _, err := cosign.VerifyBlobAttestation(ctx, sig, digest, &checkOpts); err != nil {
+			doc.Warnings = append(doc.Warnings, fmt.Sprintf("attestation signature could not be verified: %v", err))
+			return
+		}
+	}
+
+	// Signatures made with a key may have no signer data to record
+	if len(doc.Provenance.Signatures) == 0 {
+		doc.Provenance.Signatures = []results.Signature{{}}
+	}
+	for i := range doc.Provenance.Signatures {
+		doc.Provenance.Signatures[i].Verified = true
+	}
+}
+
+// VerifyOptions checks the options and returns an error if there is something
+// wrong. Missing options are completed with their defaults.
 func (di *defaultImplementation) VerifyOptions(opts *options.Options) error {
-	if opts.ProberOptions == nil {
-		opts.ProberOptions = map[string]interface{}{}
+	if opts.Logger == nil {
+		opts.Logger = options.Default.Logger
 	}
-	if _, ok := opts.ProberOptions[purl.TypeOCI]; !ok {
-		opts.ProberOptions[purl.TypeOCI] = localOptions{}
+	if opts.Context == nil {
+		opts.Context = context.Background()
 	}
+
+	ociOpts, err := GetOptions(*opts)
+	if err != nil {
+		return err
+	}
+	if err := ociOpts.Validate(); err != nil {
+		return fmt.Errorf("invalid oci prober options: %w", err)
+	}
+
+	// Store the options as a value, the map is copied as it
+	// may be shared with other probers
+	*opts = opts.WithProberOptions(purl.TypeOCI, ociOpts)
 	return nil
 }
 
@@ -4,19 +4,38 @@
 package oci
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/openvex/discovery/internal/testregistry"
 	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/discovery/validation"
+	"github.com/openvex/discovery/pkg/errdefs"
+	doci "github.com/openvex/discovery/pkg/oci"
 	"github.com/openvex/discovery/pkg/probers/oci/ocifakes"
 	"github.com/openvex/go-vex/pkg/vex"
 	purl "github.com/package-url/packageurl-go"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
 	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestDownloadDocuments(t *testing.T) {
+	reg := testregistry.New(t)
 	impl := defaultImplementation{}
 	for _, tc := range []struct {
 		name      string
@@ -28,14 +47,14 @@ func TestDownloadDocuments(t *testing.T) {
 		{
 			name:      "image with openvex",
 			options:   options.Default,
-			reference: "localhost:5000/wolfi-base:latest",
+			reference: reg.Ref("alpine-cves:latest"),
 			numDocs:   1,
 			mustErr:   false,
 		},
 		{
 			name:      "no attestations",
 			options:   options.Default,
-			reference: "localhost:5000/notsigned:latest",
+			reference: reg.Ref("notsigned:latest"),
 			mustErr:   false,
 			numDocs:   0,
 		},
@@ -53,10 +72,225 @@ func TestDownloadDocuments(t *testing.T) {
 			}
 			require.NoError(t, err)
 			require.Len(t, docs, tc.numDocs)
+			require.Equal(t, tc.numDocs, results.CountAccepted(docs))
 		})
 	}
 }
 
+func TestDownloadDocumentsValidation(t *testing.T) {
+	reg := testregistry.New(t)
+
+	valid := vex.New()
+	valid.ID = "valid"
+	valid.Statements = []vex.Statement{
+		{Vulnerability: vex.Vulnerability{Name: "CVE-2023-1234"}, Status: vex.StatusFixed},
+		{Vulnerability: vex.Vulnerability{Name: "CVE-2023-5678"}, Status: vex.StatusFixed},
+	}
+	invalid := vex.New()
+	invalid.ID = "invalid"
+	invalid.Statements = []vex.Statement{{Vulnerability: vex.Vulnerability{Name: "CVE-2023-1234"}, Status: vex.StatusNotAffected}}
+
+	require.NoError(t, reg.Attest("notsigned:latest", &valid))
+	require.NoError(t, reg.Attest("notsigned:latest", &invalid))
+
+	ref, err := name.ParseReference(reg.Ref("notsigned:latest"))
+	require.NoError(t, err)
+	se, err := ociremote.SignedEntity(ref)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name     string
+		limits   *validation.Limits
+		rejected map[string]bool
+	}{
+		{"default limits", nil, map[string]bool{"valid": false, "invalid": true}},
+		{"statement limit", &validation.Limits{MaxStatements: 1}, map[string]bool{"valid": true, "invalid": true}},
+		{"size limit", &validation.Limits{MaxPayloadSize: 10}, map[string]bool{"": true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.New().WithProberOptions(purl.TypeOCI, Options{Limits: tc.limits})
+			docs, err := (&defaultImplementation{}).DownloadDocuments(opts, se)
+			require.NoError(t, err)
+			require.Len(t, docs, 2)
+
+			rejected := map[string]bool{}
+			for _, d := range docs {
+				id := ""
+				if d.VEX != nil {
+					id = d.VEX.ID
+				}
+				rejected[id] = d.Rejected()
+				if d.Rejected() {
+					require.NotEmpty(t, d.Problems)
+				}
+			}
+			require.Equal(t, tc.rejected, rejected)
+			require.Len(t, results.ToVEX(docs), results.CountAccepted(docs))
+		})
+	}
+}
+
+func TestDownloadDocumentsSubjects(t *testing.T) {
+	reg := testregistry.New(t)
+	doc := vex.New()
+	doc.ID = "per-arch"
+	require.NoError(t, reg.AttestSubject("alpine-cves:latest", testregistry.AlpineAmd64Digest, &doc))
+	doc.ID = "copied"
+	require.NoError(t, reg.AttestSubject("alpine-cves:latest", "sha256:"+strings.Repeat("0", 64), &doc))
+
+	ref, err := name.ParseReference(reg.Ref("alpine-cves:latest"))
+	require.NoError(t, err)
+	se, err := ociremote.SignedEntity(ref)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name     string
+		policy   SubjectPolicy
+		rejected map[string]bool
+		warnings int
+	}{
+		{"reject by default", "", map[string]bool{"per-arch": false, "copied": true}, 0},
+		{"warn", SubjectWarn, map[string]bool{"per-arch": false, "copied": false}, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.New().WithProberOptions(purl.TypeOCI, NewOptions(WithSubjectPolicy(tc.policy)))
+			docs, err := (&defaultImplementation{}).DownloadDocuments(opts, se)
+			require.NoError(t, err)
+
+			rejected := map[string]bool{}
+			warnings := 0
+			for _, d := range docs {
+				warnings += len(d.Warnings)
+				if d.VEX.ID == "per-arch" || d.VEX.ID == "copied" {
+					rejected[d.VEX.ID] = d.Rejected()
+				}
+			}
+			require.Equal(t, tc.rejected, rejected)
+			require.Equal(t, tc.warnings, warnings)
+		})
+	}
+}
+
+func TestDownloadDocumentsReferences(t *testing.T) {
+	reg := testregistry.New(t)
+	doc := vex.New()
+	doc.ID = "linked"
+	doc.Author = "OpenVEX"
+	doc.Timestamp = &time.Time{}
+	data, err := json.Marshal(&doc)
+	require.NoError(t, err)
+	predicate := map[string]any{}
+	require.NoError(t, json.Unmarshal(data, &predicate))
+	predicate["references"] = []any{
+		map[string]any{"type": "vex", "url": "https://example.com/base.vex.json"},
+		map[string]any{"type": "vex", "url": "pkg:oci/base?repository_url=example.com"},
+	}
+	require.NoError(t, reg.AttestPredicate("notsigned:latest", "", vex.TypeURI, predicate))
+
+	ref, err := name.ParseReference(reg.Ref("notsigned:latest"))
+	require.NoError(t, err)
+	se, err := ociremote.SignedEntity(ref)
+	require.NoError(t, err)
+
+	docs, err := (&defaultImplementation{}).DownloadDocuments(options.New(), se)
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	require.False(t, docs[0].Rejected(), docs[0].Problems)
+	require.Equal(t, []string{"https://example.com/base.vex.json", "pkg:oci/base?repository_url=example.com"}, docs[0].References)
+}
+
+func TestDownloadDocumentsVerification(t *testing.T) {
+	reg := testregistry.New(t)
+	identity := "https://github.com/openvex/discovery/.github/workflows/release.yaml@refs/heads/main"
+	issuer := "https://token.actions.githubusercontent.com"
+	signer, err := testregistry.NewSigner(identity, issuer)
+	require.NoError(t, err)
+	other, err := testregistry.NewSigner(identity, issuer)
+	require.NoError(t, err)
+
+	doc := vex.New()
+	doc.ID = "signed"
+	doc.Author = "OpenVEX"
+	doc.Timestamp = &time.Time{}
+	require.NoError(t, reg.AttestSigned("notsigned:latest", &doc, signer))
+
+	ref, err := name.ParseReference(reg.Ref("notsigned:latest"))
+	require.NoError(t, err)
+	se, err := ociremote.SignedEntity(ref)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name         string
+		verification *cosign.CheckOpts
+		verified     bool
+	}{
+		{"not verified", nil, false},
+		{"trusted root", &cosign.CheckOpts{RootCerts: signer.Roots, IgnoreTlog: true, IgnoreSCT: true}, true},
+		{"untrusted root", &cosign.CheckOpts{RootCerts: other.Roots, IgnoreTlog: true, IgnoreSCT: true}, false},
+		{
+			name: "identity mismatch",
+			verification: &cosign.CheckOpts{
+				RootCerts: signer.Roots, IgnoreTlog: true, IgnoreSCT: true,
+				Identities: []cosign.Identity{{Issuer: issuer, Subject: "https://example.com/mallory"}},
+			},
+			verified: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.New().WithProberOptions(purl.TypeOCI, NewOptions(WithVerification(tc.verification)))
+			docs, err := (&defaultImplementation{}).DownloadDocuments(opts, se)
+			require.NoError(t, err)
+			require.Len(t, docs, 1)
+			require.False(t, docs[0].Rejected(), docs[0].Problems)
+			require.Equal(t, []results.Signature{
+				{Identity: identity, Issuer: issuer, Verified: tc.verified},
+			}, docs[0].Provenance.Signatures)
+			if tc.verification != nil && !tc.verified {
+				require.Len(t, docs[0].Warnings, 1)
+			}
+		})
+	}
+}
+
+func TestDownloadDocumentsVerificationSubjects(t *testing.T) {
+	reg := testregistry.New(t)
+	identity := "https://github.com/openvex/discovery/.github/workflows/release.yaml@refs/heads/main"
+	issuer := "https://token.actions.githubusercontent.com"
+	signer, err := testregistry.NewSigner(identity, issuer)
+	require.NoError(t, err)
+
+	// Attestations attached to an index may be for the index or for one of
+	// the images it fronts
+	doc := vex.New()
+	doc.Author = "OpenVEX"
+	doc.Timestamp = &time.Time{}
+	doc.ID = "index"
+	require.NoError(t, reg.AttestSignedSubject("notsigned:latest", "", &doc, signer))
+	doc.ID = "per-arch"
+	require.NoError(t, reg.AttestSignedSubject("notsigned:latest", testregistry.AlpineAmd64Digest, &doc, signer))
+	doc.ID = "copied"
+	require.NoError(t, reg.AttestSignedSubject("notsigned:latest", "sha256:"+strings.Repeat("0", 64), &doc, signer))
+
+	ref, err := name.ParseReference(reg.Ref("notsigned:latest"))
+	require.NoError(t, err)
+	se, err := ociremote.SignedEntity(ref)
+	require.NoError(t, err)
+
+	opts := options.New().WithProberOptions(purl.TypeOCI, NewOptions(
+		WithSubjectPolicy(SubjectWarn),
+		WithVerification(&cosign.CheckOpts{RootCerts: signer.Roots, IgnoreTlog: true, IgnoreSCT: true}),
+	))
+	docs, err := (&defaultImplementation{}).DownloadDocuments(opts, se)
+	require.NoError(t, err)
+
+	verified := map[string]bool{}
+	for _, d := range docs {
+		require.Len(t, d.Provenance.Signatures, 1)
+		verified[d.VEX.ID] = d.Provenance.Signatures[0].Verified
+	}
+	require.Equal(t, map[string]bool{"index": true, "per-arch": true, "copied": false}, verified)
+}
+
 func TestFindDocumentsFromPurl(t *testing.T) {
 	prober := New()
 	p, err := purl.FromString("pkg:oci/scratch@sha256%3A0000000000000000000000000000000000000000000000000000000000000000")
@@ -64,7 +298,6 @@ func TestFindDocumentsFromPurl(t *testing.T) {
 	syntErr := fmt.Errorf("synthetic error")
 	for _, tc := range []struct {
 		name    string
-		options options.Options
 		prepare func(*Prober)
 		mustErr bool
 	}{
@@ -72,16 +305,35 @@ func TestFindDocumentsFromPurl(t *testing.T) {
 			name: "success",
 			prepare: func(p *Prober) {
 				impl := &ocifakes.FakeOciImplementation{}
-				impl.PurlToReferenceReturns(name.MustParseReference("scratch"), nil)
-				impl.DownloadDocumentsReturns([]*vex.VEX{{}}, nil)
+				impl.PurlToReferencesReturns([]name.Reference{name.MustParseReference("scratch")}, nil)
+				impl.DownloadDocumentsReturns([]*results.Document{{VEX: &vex.VEX{}}}, nil)
 				p.impl = impl
 			},
 		},
+		{
+			name: "first mirror fails",
+			prepare: func(p *Prober) {
+				impl := &ocifakes.FakeOciImplementation{}
+				impl.PurlToReferencesReturns([]name.Reference{
+					name.MustParseReference("mirror.example.com/scratch"), name.MustParseReference("scratch"),
+				}, nil)
+				impl.ResolveImageReferenceReturnsOnCall(0, nil, syntErr)
+				impl.DownloadDocumentsReturns([]*results.Document{{VEX: &vex.VEX{}}}, nil)
+				p.impl = impl
+			},
+		},
+		{
+			name: "no references",
+			prepare: func(p *Prober) {
+				p.impl = &ocifakes.FakeOciImplementation{}
+			},
+			mustErr: true,
+		},
 		{
 			name: "purltoreference fails",
 			prepare: func(p *Prober) {
 				impl := &ocifakes.FakeOciImplementation{}
-				impl.PurlToReferenceReturns(nil, syntErr)
+				impl.PurlToReferencesReturns(nil, syntErr)
 				p.impl = impl
 			},
 			mustErr: true,
@@ -90,6 +342,7 @@ func TestFindDocumentsFromPurl(t *testing.T) {
 			name: "resolveimagereference fails",
 			prepare: func(p *Prober) {
 				impl := &ocifakes.FakeOciImplementation{}
+				impl.PurlToReferencesReturns([]name.Reference{name.MustParseReference("scratch")}, nil)
 				impl.ResolveImageReferenceReturns(nil, syntErr)
 				p.impl = impl
 			},
@@ -99,6 +352,7 @@ func TestFindDocumentsFromPurl(t *testing.T) {
 			name: "dowenloaddocuments fails",
 			prepare: func(p *Prober) {
 				impl := &ocifakes.FakeOciImplementation{}
+				impl.PurlToReferencesReturns([]name.Reference{name.MustParseReference("scratch")}, nil)
 				impl.DownloadDocumentsReturns(nil, syntErr)
 				p.impl = impl
 			},
@@ -107,7 +361,7 @@ func TestFindDocumentsFromPurl(t *testing.T) {
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.prepare(prober)
-			docs, err := prober.FindDocumentsFromPurl(tc.options, p)
+			docs, err := prober.FindDocumentsFromPurl(options.New(), p)
 			if tc.mustErr {
 				require.Error(t, err)
 				return
@@ -117,3 +371,246 @@ func TestFindDocumentsFromPurl(t *testing.T) {
 		})
 	}
 }
+
+func TestPurlToReferences(t *testing.T) {
+	impl := &defaultImplementation{}
+	for _, tc := range []struct {
+		name     string
+		purl     string
+		expected string
+	}{
+		{"repository", "pkg:oci/curl?repository_url=cgr.dev%2Fchainguard&tag=latest", "cgr.dev/chainguard/curl:latest"},
+		{"trailing slash", "pkg:oci/curl?repository_url=cgr.dev%2Fchainguard%2F&tag=latest", "cgr.dev/chainguard/curl:latest"},
+		{"scheme", "pkg:oci/curl?repository_url=https%3A%2F%2Fcgr.dev%2Fchainguard&tag=latest", "cgr.dev/chainguard/curl:latest"},
+		{"docker hub", "pkg:oci/alpine?repository_url=docker.io&tag=3.18", "index.docker.io/library/alpine:3.18"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := purl.FromString(tc.purl)
+			require.NoError(t, err)
+			refs, err := impl.PurlToReferences(options.New(), p)
+			require.NoError(t, err)
+			require.Len(t, refs, 1)
+			require.Equal(t, tc.expected, refs[0].Name())
+		})
+	}
+}
+
+func TestFindDocumentsAllPlatforms(t *testing.T) {
+	reg := testregistry.New(t)
+	doc := vex.New()
+	doc.ID = "amd64-doc"
+	require.NoError(t, reg.Attest("alpine-cves@"+testregistry.AlpineAmd64Digest, &doc))
+
+	p, err := purl.FromString("pkg:oci/alpine-cves?repository_url=" + url.QueryEscape(reg.Host) + "&tag=latest")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name       string
+		ociOptions Options
+		platforms  []string
+	}{
+		{"index only", Options{}, []string{""}},
+		{"all platforms", NewOptions(WithAllPlatforms()), []string{"", "linux/amd64"}},
+		{"single platform", NewOptions(WithPlatform("linux/amd64")), []string{""}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := options.New().WithProberOptions(purl.TypeOCI, tc.ociOptions)
+			docs, err := New().FindDocumentsWithProvenance(opts, p)
+			require.NoError(t, err)
+
+			platforms := []string{}
+			for _, d := range docs {
+				require.False(t, d.Rejected(), d.Problems)
+				platforms = append(platforms, d.Provenance.Platform)
+				if d.Provenance.Platform != "" {
+					require.Equal(t, "amd64-doc", d.VEX.ID)
+					require.Equal(t, reg.Ref("alpine-cves:"+attestationTag(testregistry.AlpineAmd64Digest)), d.Provenance.Source)
+				}
+			}
+			require.Equal(t, tc.platforms, platforms)
+		})
+	}
+}
+
+func TestResolvePlatformImages(t *testing.T) {
+	reg := testregistry.New(t)
+
+	// The images only differ in their features, their platform strings are
+	// the same. The buildkit attestation manifest is skipped.
+	idx := v1.ImageIndex(empty.Index)
+	digests := []string{}
+	for _, platform := range []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "amd64", Features: []string{"sse4"}},
+		{OS: "unknown", Architecture: "unknown"},
+	} {
+		img, err := random.Image(64, 1)
+		require.NoError(t, err)
+		d, err := img.Digest()
+		require.NoError(t, err)
+		if platform.OS != "unknown" {
+			digests = append(digests, d.String())
+		}
+		p := platform
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: &p}})
+	}
+	ref, err := name.ParseReference(reg.Ref("features:latest"))
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, idx))
+
+	se, err := ociremote.SignedEntity(ref)
+	require.NoError(t, err)
+	images, err := (&defaultImplementation{}).ResolvePlatformImages(options.New(), se)
+	require.NoError(t, err)
+	require.Len(t, images, 2)
+	for _, d := range digests {
+		require.Contains(t, images, d)
+	}
+}
+
+func TestFindDocumentsMirrors(t *testing.T) {
+	reg := testregistry.New(t)
+	// A mirror with the image but without its attestations
+	require.NoError(t, reg.Load(filepath.Join(testregistry.TestDataPath(), "alpine-cves"), "empty/alpine-cves:latest", ""))
+
+	for _, tc := range []struct {
+		name       string
+		repository string
+		mirrors    []doci.Mirror
+		source     string
+		numDocs    int
+		mustErr    bool
+	}{
+		{
+			name:       "upstream served by mirror",
+			repository: "upstream.example.com/images",
+			mirrors:    []doci.Mirror{{Prefix: "upstream.example.com/images", Mirror: reg.Host}},
+			source:     reg.Ref("alpine-cves:" + attestationTag(testregistry.AlpineIndexDigest)),
+			numDocs:    1,
+		},
+		{
+			name:       "mirror fails, fallback to upstream",
+			repository: reg.Host,
+			mirrors:    []doci.Mirror{{Prefix: reg.Host, Mirror: "127.0.0.1:1/images"}},
+			source:     reg.Ref("alpine-cves:" + attestationTag(testregistry.AlpineIndexDigest)),
+			numDocs:    1,
+		},
+		{
+			name:       "mirror without documents, no fallback",
+			repository: reg.Host,
+			mirrors:    []doci.Mirror{{Prefix: reg.Host, Mirror: reg.Ref("empty")}},
+			numDocs:    0,
+		},
+		{
+			name:       "all locations fail",
+			repository: "127.0.0.1:1",
+			mirrors:    []doci.Mirror{{Prefix: "127.0.0.1:1", Mirror: "127.0.0.1:2"}},
+			mustErr:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := purl.FromString("pkg:oci/alpine-cves?repository_url=" + url.QueryEscape(tc.repository) + "&tag=latest")
+			require.NoError(t, err)
+
+			opts := options.New().WithProberOptions(purl.TypeOCI, NewOptions(WithMirrors(tc.mirrors...)))
+			docs, err := New().FindDocumentsWithProvenance(opts, p)
+			if tc.mustErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, docs, tc.numDocs)
+			for _, d := range docs {
+				require.Equal(t, tc.source, d.Provenance.Source)
+			}
+		})
+	}
+}
+
+// attestationTag returns the tag of the attestations attached to a digest
+func attestationTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".att"
+}
+
+func TestAttestationSource(t *testing.T) {
+	reg := testregistry.New(t)
+	ref, err := name.ParseReference(reg.Ref("alpine-cves:latest"))
+	require.NoError(t, err)
+	se, err := ociremote.SignedEntity(ref)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name       string
+		ociOptions Options
+		env        string
+		expected   string
+	}{
+		{"image repository", Options{}, "", reg.Ref("alpine-cves:" + attestationTag(testregistry.AlpineIndexDigest))},
+		{
+			"override", Options{RepositoryOverride: "example.com/attestations"}, "",
+			"example.com/attestations:" + attestationTag(testregistry.AlpineIndexDigest),
+		},
+		{"environment", Options{}, "example.com/env", "example.com/env:" + attestationTag(testregistry.AlpineIndexDigest)},
+		{
+			"tag prefix", Options{TagPrefix: "vex-"}, "",
+			reg.Ref("alpine-cves:vex-" + attestationTag(testregistry.AlpineIndexDigest)),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("COSIGN_REPOSITORY", tc.env)
+			opts := options.New().WithProberOptions(purl.TypeOCI, tc.ociOptions)
+			source, err := (&defaultImplementation{}).AttestationSource(opts, ref, se)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, source)
+		})
+	}
+}
+
+func TestFindDocumentsErrorKinds(t *testing.T) {
+	reg := testregistry.New(t)
+	for _, tc := range []struct {
+		name       string
+		image      string
+		repository string
+		platform   string
+		kind       error
+	}{
+		{"missing image", "missing", reg.Host, "", errdefs.ErrNotFound},
+		{"unreachable registry", "missing", "127.0.0.1:1", "", errdefs.ErrNetwork},
+		{"platform of single arch image", "alpine-cves-amd64", reg.Host, "linux/amd64", errdefs.ErrInvalidArgument},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := purl.FromString("pkg:oci/" + tc.image + "?repository_url=" + url.QueryEscape(tc.repository) + "&tag=latest")
+			require.NoError(t, err)
+
+			opts := options.New().WithProberOptions(
+				purl.TypeOCI, NewOptions(WithRetryPolicy(doci.RetryPolicy{}), WithPlatform(tc.platform)),
+			)
+			_, err = New().FindDocumentsWithProvenance(opts, p)
+			require.ErrorIs(t, err, tc.kind)
+		})
+	}
+}
+
+func TestFindDocumentsTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	opts := options.New()
+	opts.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	impl := &ocifakes.FakeOciImplementation{}
+	impl.PurlToReferencesReturns([]name.Reference{name.MustParseReference("scratch")}, nil)
+	impl.DownloadDocumentsReturns([]*results.Document{{VEX: &vex.VEX{}}}, nil)
+	prober := New()
+	prober.impl = impl
+
+	p, err := purl.FromString("pkg:oci/scratch")
+	require.NoError(t, err)
+	_, err = prober.FindDocumentsWithProvenance(opts, p)
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, s := range recorder.Ended() {
+		names = append(names, s.Name())
+	}
+	require.Equal(t, []string{"oci.PurlToReference", "oci.ResolveImageReference", "oci.DownloadDocuments"}, names)
+}
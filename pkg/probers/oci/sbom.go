@@ -0,0 +1,202 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	purl "github.com/package-url/packageurl-go"
+	"github.com/sigstore/cosign/v2/pkg/oci"
+
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/telemetry"
+	"github.com/openvex/discovery/pkg/errdefs"
+	doci "github.com/openvex/discovery/pkg/oci"
+	"github.com/openvex/discovery/pkg/sbom"
+)
+
+// FindSBOM reads the SBOM attestations of a container image and returns the
+// purls of the components listed in them and the VEX documents they link.
+// The purls of the image itself are left out. Just as
+// FindDocumentsWithProvenance, it tries the locations produced by the mirror
+// rules in order and stops at the first one with an SBOM.
+func (prober *Prober) FindSBOM(opts options.Options, p purl.PackageURL) (*sbom.Contents, error) {
+	popts := opts
+	if err := prober.impl.VerifyOptions(&popts); err != nil {
+		return nil, fmt.Errorf("verifying options: %w", err)
+	}
+
+	sopts, span := telemetry.Start(popts, "oci.PurlToReference", telemetry.AttrPurl.String(p.String()))
+	refs, err := prober.impl.PurlToReferences(sopts, p)
+	telemetry.End(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("translating purl to image reference: %w", err)
+	}
+
+	if len(refs) == 0 {
+		return nil, errdefs.New(errdefs.ErrParse, "could not resolve image reference from %s", p)
+	}
+
+	candidates, err := repositoryCandidates(popts)
+	if err != nil {
+		return nil, fmt.Errorf("resolving attestation repositories: %w", err)
+	}
+
+	var contents *sbom.Contents
+	errs := []error{}
+	for _, ref := range refs {
+		for _, copts := range candidates {
+			found, err := prober.findSBOMAt(copts, ref)
+			if err != nil {
+				popts.Logger.DebugContext(
+					popts.Context, "reading image sbom failed", "reference", ref.String(), "error", err,
+				)
+				errs = append(errs, err)
+				continue
+			}
+			if len(found.Purls) > 0 || len(found.VEXReferences) > 0 {
+				return found, nil
+			}
+			contents = found
+		}
+	}
+
+	// If any location answered, the image has no SBOM
+	if contents != nil {
+		return contents, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// findSBOMAt reads the SBOMs attached to the image at ref
+func (prober *Prober) findSBOMAt(opts options.Options, ref name.Reference) (*sbom.Contents, error) {
+	sopts, span := telemetry.Start(opts, "oci.ResolveImageReference", telemetry.AttrReference.String(ref.String()))
+	image, err := prober.impl.ResolveImageReference(sopts, ref)
+	telemetry.End(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("resolving image reference: %w", err)
+	}
+
+	sopts, span = telemetry.Start(opts, "oci.DownloadSBOM", telemetry.AttrReference.String(ref.String()))
+	contents, err := prober.impl.DownloadSBOM(sopts, image)
+	telemetry.End(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("downloading sbom from registry: %w", err)
+	}
+	return contents, nil
+}
+
+// DownloadSBOM reads the SBOM attestations of a signed entity and returns
+// the purls of the components they list and the VEX documents they link.
+// Attestations that can't be read, exceed the size limit or, unless the
+// subject policy is warn, don't have the entity as their subject are skipped.
+func (di *defaultImplementation) DownloadSBOM(opts options.Options, se oci.SignedEntity) (*sbom.Contents, error) {
+	contents := &sbom.Contents{Purls: []string{}, VEXReferences: []string{}}
+
+	atts, err := se.Attestations()
+	if err != nil {
+		return nil, fmt.Errorf("fetching attestations: %w", doci.ClassifyError(err))
+	}
+
+	sigs, err := atts.Get()
+	if err != nil {
+		return nil, fmt.Errorf("fetching attestations: %w", doci.ClassifyError(err))
+	}
+
+	if len(sigs) == 0 {
+		return contents, nil
+	}
+
+	ociOpts, err := GetOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	digests, err := entityDigests(se)
+	if err != nil {
+		return nil, fmt.Errorf("reading image digests: %w", doci.ClassifyError(err))
+	}
+	check := attestationCheck{limits: ociOpts.limits(), digests: digests, subjectPolicy: ociOpts.SubjectPolicy}
+
+	seen, seenRefs := map[string]struct{}{}, map[string]struct{}{}
+	for i, sig := range sigs {
+		rawPayload, problems, err := attestationPayload(opts, ociOpts, sig)
+		if err != nil {
+			return nil, err
+		}
+		if problems != nil {
+			opts.Logger.DebugContext(opts.Context, fmt.Sprintf("skipping attestation #%d", i), "problems", problems)
+			continue
+		}
+
+		_, statement, err := decodeStatement(rawPayload)
+		if err != nil {
+			opts.Logger.DebugContext(opts.Context, fmt.Sprintf("skipping attestation #%d", i), "error", err)
+			continue
+		}
+		if statement == nil || !sbom.IsSBOMPredicateType(statement.PredicateType) {
+			continue
+		}
+
+		if len(check.matchedDigests(statement)) == 0 {
+			if check.subjectPolicy != SubjectWarn {
+				opts.Logger.DebugContext(
+					opts.Context, fmt.Sprintf("skipping sbom attestation #%d: subjects don't match the image digest", i),
+				)
+				continue
+			}
+			opts.Logger.WarnContext(
+				opts.Context, fmt.Sprintf("sbom attestation #%d subjects don't match the image digest", i),
+			)
+		}
+
+		found, err := sbom.Read(statement.PredicateType, statement.Predicate)
+		if err != nil {
+			opts.Logger.DebugContext(opts.Context, fmt.Sprintf("skipping sbom attestation #%d", i), "error", err)
+			continue
+		}
+
+		for _, p := range found.Purls {
+			if _, ok := seen[p]; ok || isEntityPurl(p, digests) {
+				continue
+			}
+			seen[p] = struct{}{}
+			contents.Purls = append(contents.Purls, p)
+		}
+		for _, r := range found.VEXReferences {
+			if _, ok := seenRefs[r]; ok {
+				continue
+			}
+			seenRefs[r] = struct{}{}
+			contents.VEXReferences = append(contents.VEXReferences, r)
+		}
+	}
+
+	opts.Logger.DebugContext(
+		opts.Context, fmt.Sprintf(
+			"image sboms list %d component purls and %d vex references",
+			len(contents.Purls), len(contents.VEXReferences),
+		),
+	)
+	return contents, nil
+}
+
+// isEntityPurl returns true if a purl is an oci purl of one of the digests
+// of an entity. SBOMs of images usually list the image itself.
+func isEntityPurl(purlString string, digests []v1.Hash) bool {
+	p, err := purl.FromString(purlString)
+	if err != nil || p.Type != purl.TypeOCI {
+		return false
+	}
+	for _, d := range digests {
+		if strings.EqualFold(p.Version, d.String()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/openvex/discovery/internal/testregistry"
+	"github.com/openvex/discovery/pkg/discovery/options"
+	purl "github.com/package-url/packageurl-go"
+	"github.com/stretchr/testify/require"
+)
+
+// spdxPredicate returns an SPDX document listing packages with the purls
+func spdxPredicate(purls ...string) map[string]any {
+	packages := []any{}
+	for _, p := range purls {
+		packages = append(packages, map[string]any{
+			"name": p,
+			"externalRefs": []any{map[string]any{
+				"referenceCategory": "PACKAGE-MANAGER",
+				"referenceType":     "purl",
+				"referenceLocator":  p,
+			}},
+		})
+	}
+	return map[string]any{"spdxVersion": "SPDX-2.3", "packages": packages}
+}
+
+func TestFindSBOM(t *testing.T) {
+	reg := testregistry.New(t)
+	repoURL := url.QueryEscape(reg.Host)
+	indexHash := strings.TrimPrefix(testregistry.AlpineIndexDigest, "sha256:")
+	self := "pkg:oci/notsigned@sha256%3A" + indexHash + "?repository_url=" + repoURL
+	apk := "pkg:apk/alpine/busybox@1.36.1-r5?arch=x86_64"
+	golang := "pkg:golang/github.com/openvex/go-vex@v0.2.5"
+
+	require.NoError(t, reg.AttestPredicate("notsigned:latest", "", "https://spdx.dev/Document", spdxPredicate(self, apk)))
+	require.NoError(t, reg.AttestPredicate(
+		"notsigned:latest", "", "https://cyclonedx.org/bom",
+		map[string]any{
+			"externalReferences": []any{map[string]any{"type": "vex", "url": "https://example.com/notsigned.vex.json"}},
+			"components":         []any{map[string]any{"purl": golang}, map[string]any{"purl": apk}},
+		},
+	))
+	require.NoError(t, reg.AttestPredicate(
+		"notsigned:latest", "sha256:"+strings.Repeat("0", 64), "https://spdx.dev/Document",
+		spdxPredicate("pkg:apk/alpine/copied@1.0"),
+	))
+
+	for _, tc := range []struct {
+		name     string
+		purl     string
+		policy   SubjectPolicy
+		expected []string
+	}{
+		{"image with sboms", "pkg:oci/notsigned?tag=latest&repository_url=" + repoURL, "", []string{apk, golang}},
+		{"warn on subjects", "pkg:oci/notsigned?tag=latest&repository_url=" + repoURL, SubjectWarn, []string{apk, "pkg:apk/alpine/copied@1.0", golang}},
+		{"image without sbom", "pkg:oci/alpine-cves?tag=latest&repository_url=" + repoURL, "", []string{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := purl.FromString(tc.purl)
+			require.NoError(t, err)
+			opts := options.New().WithProberOptions(purl.TypeOCI, NewOptions(WithSubjectPolicy(tc.policy)))
+			contents, err := New().FindSBOM(opts, p)
+			require.NoError(t, err)
+			require.ElementsMatch(t, tc.expected, contents.Purls)
+		})
+	}
+
+	p, err := purl.FromString("pkg:oci/notsigned?tag=latest&repository_url=" + repoURL)
+	require.NoError(t, err)
+	contents, err := New().FindSBOM(options.New(), p)
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://example.com/notsigned.vex.json"}, contents.VEXReferences)
+
+	p, err = purl.FromString("pkg:oci/missing?repository_url=" + repoURL)
+	require.NoError(t, err)
+	_, err = New().FindSBOM(options.New(), p)
+	require.Error(t, err)
+}
@@ -0,0 +1,24 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugin_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/openvex/discovery/pkg/probers/conformance"
+	"github.com/openvex/discovery/pkg/probers/plugin"
+)
+
+func TestConformance(t *testing.T) {
+	// Run the test binary as a plugin, see TestHelperPlugin
+	t.Setenv("DISCOVERY_TEST_PLUGIN_MODE", "serve")
+	conformance.Test(t, plugin.New("generic", os.Args[0], "-test.run=^TestHelperPlugin$"), conformance.Config{
+		Type:    "generic",
+		Found:   "pkg:generic/found@1.0.0",
+		Missing: "pkg:generic/missing@1.0.0",
+		Timeout: 10 * time.Second,
+	})
+}
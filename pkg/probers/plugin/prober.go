@@ -0,0 +1,300 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package plugin implements a VexProbe that delegates probing to an external
+// executable. This allows teams to ship private probers without forking the
+// discovery module.
+//
+// For each purl to probe, the prober runs the executable and writes a JSON
+// encoded Request to its standard input. The plugin must write a JSON encoded
+// Response to its standard output and exit with status zero. If the plugin
+// exits with an error, anything it wrote to standard error is returned in the
+// error message. See Serve() for a helper to write plugins in Go.
+//
+// Plugins can be discovered from a directory: executables named
+// vex-probe-<purl type> (for example vex-probe-npm) are registered as the
+// prober for their purl type.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/openvex/go-vex/pkg/vex"
+	purl "github.com/package-url/packageurl-go"
+
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/discovery/validation"
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+// Prefix is the file name prefix of plugin executables
+const Prefix = "vex-probe-"
+
+// DefaultMaxResponseSize is the default limit in bytes of the response a
+// plugin can write to its standard output.
+const DefaultMaxResponseSize = 64 * 1024 * 1024
+
+// Options are the prober options passed to plugins. They are free form
+// settings serialized as JSON in the request:
+//
+//	opts = opts.WithProberOptions("npm", plugin.Options{"registry": "https://npm.example.com"})
+//
+// Prober options of any other type set for the plugin's purl type, for
+// example the options of a built-in prober of the same type, are not sent.
+type Options map[string]interface{}
+
+// Prober runs an external executable to find VEX documents
+type Prober struct {
+	Options options.Options
+
+	// Type is the purl type handled by the plugin. The prober options set for
+	// this type are passed to the plugin in the request.
+	Type string
+
+	// Path is the location of the plugin executable
+	Path string
+
+	// Args are additional arguments passed to the plugin executable
+	Args []string
+
+	// Limits caps the size and shape of the documents returned by the
+	// plugin. New sets it to validation.DefaultLimits.
+	Limits validation.Limits
+
+	// MaxResponseSize is the maximum size in bytes of the plugin response.
+	// New sets it to DefaultMaxResponseSize, zero means no limit.
+	MaxResponseSize int
+}
+
+// New returns a new prober that runs the executable at path to probe
+// purls of the specified type.
+func New(purlType, path string, args ...string) *Prober {
+	return &Prober{
+		Options: options.New(),
+		Type:    purlType,
+		Path:    path,
+		Args:    args,
+		Limits:  validation.DefaultLimits,
+
+		MaxResponseSize: DefaultMaxResponseSize,
+	}
+}
+
+// Discover looks for plugin executables in a directory and returns a prober
+// for each of them, keyed by the purl type they handle. It returns an error
+// if more than one executable handles the same purl type.
+func Discover(dir string) (map[string]*Prober, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading plugins directory: %w", err)
+	}
+
+	probers := map[string]*Prober{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), Prefix) {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			return nil, fmt.Errorf("reading plugin file info: %w", err)
+		}
+
+		// Skip files that are not executable
+		if info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+
+		purlType := strings.TrimSuffix(strings.TrimPrefix(e.Name(), Prefix), filepath.Ext(e.Name()))
+		if purlType == "" {
+			continue
+		}
+		if p, ok := probers[purlType]; ok {
+			return nil, errdefs.New(
+				errdefs.ErrInvalidArgument, "plugins %s and %s both handle purls of type %s",
+				filepath.Base(p.Path), e.Name(), purlType,
+			)
+		}
+		probers[purlType] = New(purlType, filepath.Join(dir, e.Name()))
+	}
+	return probers, nil
+}
+
+// SetOptions sets the probe's options
+func (prober *Prober) SetOptions(opts options.Options) {
+	prober.Options = opts
+}
+
+// FindDocumentsFromPurl runs the plugin to find the documents of a purl
+func (prober *Prober) FindDocumentsFromPurl(opts options.Options, p purl.PackageURL) ([]*vex.VEX, error) {
+	docs, err := prober.FindDocumentsWithProvenance(opts, p)
+	if err != nil {
+		return nil, err
+	}
+	return results.ToVEX(docs), nil
+}
+
+// FindDocumentsWithProvenance runs the plugin and returns the documents it
+// found along with the provenance data it reported.
+func (prober *Prober) FindDocumentsWithProvenance(opts options.Options, p purl.PackageURL) ([]*results.Document, error) {
+	if p.Type != prober.Type {
+		return nil, errdefs.New(errdefs.ErrUnsupportedPurlType, "plugin %s does not handle purls of type %s", prober.Path, p.Type)
+	}
+
+	req := &Request{
+		Version: ProtocolVersion,
+		Purl:    p.String(),
+	}
+
+	if o, ok := opts.ProberOptions[prober.Type].(Options); ok && o != nil {
+		data, err := json.Marshal(o)
+		if err != nil {
+			return nil, fmt.Errorf("serializing plugin options: %w", err)
+		}
+		req.Options = data
+	}
+
+	resp, err := prober.run(runContext(opts), req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, errdefs.FromKindName(resp.ErrorKind, fmt.Sprintf("plugin %s: %s", prober.Path, resp.Error))
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = options.Default.Logger
+	}
+	for _, e := range resp.Errors {
+		logger.WarnContext(runContext(opts), fmt.Sprintf("plugin %s: %s", prober.Path, e))
+	}
+
+	docs := []*results.Document{}
+	for i, d := range resp.Documents {
+		if d.Document == nil {
+			logger.WarnContext(runContext(opts), fmt.Sprintf("plugin %s returned empty document #%d, ignoring", prober.Path, i))
+			continue
+		}
+		doc := &results.Document{
+			VEX: d.Document,
+			Provenance: results.Provenance{
+				Prober:     prober.Type,
+				Purl:       p.String(),
+				Source:     d.Source,
+				Signatures: unverifiedSignatures(d.Signatures),
+			},
+		}
+		if problems := prober.validate(d.Document); len(problems) > 0 {
+			logger.DebugContext(
+				runContext(opts), fmt.Sprintf("plugin %s returned invalid document #%d", prober.Path, i), "problems", problems,
+			)
+			doc.Problems = problems
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// validate checks a document returned by the plugin against the OpenVEX spec
+// and the prober limits, the same checks applied to downloaded documents.
+func (prober *Prober) validate(doc *vex.VEX) []string {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return []string{fmt.Sprintf("serializing document: %s", err)}
+	}
+	if problems := prober.Limits.CheckPayloadSize(len(data)); problems != nil {
+		return problems
+	}
+	return validation.Document(doc, prober.Limits)
+}
+
+// unverifiedSignatures copies the signatures reported by a plugin clearing
+// their verified flag. Plugins run outside of the agent, their claims about
+// signature verification are not trusted.
+func unverifiedSignatures(sigs []results.Signature) []results.Signature {
+	if sigs == nil {
+		return nil
+	}
+	ret := make([]results.Signature, len(sigs))
+	for i := range sigs {
+		ret[i] = sigs[i]
+		ret[i].Verified = false
+	}
+	return ret
+}
+
+// runContext returns the context to use when running the plugin
+func runContext(opts options.Options) context.Context {
+	if opts.Context == nil {
+		return context.Background()
+	}
+	return opts.Context
+}
+
+// run executes the plugin, sends it the request and parses its response
+func (prober *Prober) run(ctx context.Context, req *Request) (*Response, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("serializing plugin request: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	stdout := &limitedBuffer{limit: prober.MaxResponseSize}
+	cmd := exec.CommandContext(ctx, prober.Path, prober.Args...) //nolint: gosec
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if stdout.exceeded {
+		return nil, errdefs.New(
+			errdefs.ErrParse, "response from plugin %s exceeds the limit of %d bytes", prober.Path, prober.MaxResponseSize,
+		)
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("running plugin %s: %w", prober.Path, ctxErr)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf(
+				"plugin %s exited with status %d: %s",
+				prober.Path, exitErr.ExitCode(), strings.TrimSpace(stderr.String()),
+			)
+		}
+		return nil, fmt.Errorf("running plugin %s: %w", prober.Path, err)
+	}
+
+	resp := &Response{}
+	if err := json.Unmarshal(stdout.Bytes(), resp); err != nil {
+		return nil, errdefs.New(errdefs.ErrParse, "parsing response from plugin %s: %w", prober.Path, err)
+	}
+	return resp, nil
+}
+
+// limitedBuffer is a buffer that fails writes once its contents would grow
+// over the limit. A zero limit means no limit.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit    int
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && b.Len()+len(p) > b.limit {
+		b.exceeded = true
+		return 0, errors.New("response size limit exceeded")
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,222 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openvex/go-vex/pkg/vex"
+	purl "github.com/package-url/packageurl-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/pkg/discovery/options"
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+const helperEnv = "DISCOVERY_TEST_PLUGIN_MODE"
+
+// TestHelperPlugin is not a real test. It makes the test binary behave as a
+// plugin when run by the tests below.
+func TestHelperPlugin(t *testing.T) {
+	mode := os.Getenv(helperEnv)
+	if mode == "" {
+		return
+	}
+
+	switch mode {
+	case "crash":
+		fmt.Fprint(os.Stderr, "synthetic crash")
+		os.Exit(3)
+	case "garbage":
+		fmt.Fprint(os.Stdout, "this is not json")
+		os.Exit(0)
+	case "large":
+		fmt.Fprint(os.Stdout, strings.Repeat(" ", 1024*1024))
+		os.Exit(0)
+	}
+
+	err := Serve(func(req *Request) (*Response, error) {
+		p, err := purl.FromString(req.Purl)
+		if err != nil {
+			return nil, err
+		}
+		switch mode {
+		case "fail":
+			return nil, fmt.Errorf("synthetic error")
+		case "notfound":
+			return nil, errdefs.New(errdefs.ErrNotFound, "package %s not found", p.Name)
+		}
+		resp := &Response{Documents: []Document{}}
+		if p.Name != "found" && p.Name != "invalid" {
+			return resp, nil
+		}
+		opts := map[string]string{"author": "OpenVEX"}
+		if len(req.Options) > 0 {
+			if err := json.Unmarshal(req.Options, &opts); err != nil {
+				return nil, err
+			}
+		}
+		doc := vex.New()
+		doc.ID = "plugin-doc"
+		doc.Author = opts["author"]
+		if p.Name == "invalid" {
+			doc.Timestamp = nil
+		}
+		resp.Documents = append(resp.Documents, Document{
+			Document: &doc,
+			Source:   "https://vex.example.com/" + p.Name,
+			Signatures: []results.Signature{
+				{Identity: "vex@example.com", Issuer: "https://accounts.example.com", Verified: true},
+			},
+		})
+		return resp, nil
+	})
+	if err != nil {
+		fmt.Fprint(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func newTestPlugin(t *testing.T, mode string) *Prober {
+	t.Setenv(helperEnv, mode)
+	return New("generic", os.Args[0], "-test.run=^TestHelperPlugin$")
+}
+
+func TestFindDocumentsWithProvenance(t *testing.T) {
+	found, err := purl.FromString("pkg:generic/found@1.0.0")
+	require.NoError(t, err)
+	missing, err := purl.FromString("pkg:generic/missing@1.0.0")
+	require.NoError(t, err)
+	npm, err := purl.FromString("pkg:npm/found@1.0.0")
+	require.NoError(t, err)
+
+	invalid, err := purl.FromString("pkg:generic/invalid@1.0.0")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name     string
+		mode     string
+		purl     purl.PackageURL
+		numDocs  int
+		rejected bool
+		mustErr  bool
+		errKind  error
+	}{
+		{"found", "serve", found, 1, false, false, nil},
+		{"invalid document", "serve", invalid, 1, true, false, nil},
+		{"missing", "serve", missing, 0, false, false, nil},
+		{"wrong purl type", "serve", npm, 0, false, true, errdefs.ErrUnsupportedPurlType},
+		{"plugin returns error", "fail", found, 0, false, true, nil},
+		{"plugin returns error kind", "notfound", found, 0, false, true, errdefs.ErrNotFound},
+		{"plugin crashes", "crash", found, 0, false, true, nil},
+		{"plugin writes garbage", "garbage", found, 0, false, true, errdefs.ErrParse},
+		{"response too large", "large", found, 0, false, true, errdefs.ErrParse},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			prober := newTestPlugin(t, tc.mode)
+			prober.MaxResponseSize = 1024
+			opts := options.Options{
+				Context:       context.Background(),
+				ProberOptions: map[string]interface{}{"generic": Options{"author": "Example Inc"}},
+			}
+			prober.SetOptions(opts)
+			docs, err := prober.FindDocumentsWithProvenance(opts, tc.purl)
+			if tc.mustErr {
+				require.Error(t, err)
+				if tc.errKind != nil {
+					require.ErrorIs(t, err, tc.errKind)
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, docs, tc.numDocs)
+			for _, d := range docs {
+				require.Equal(t, tc.rejected, d.Rejected(), d.Problems)
+				require.Equal(t, "Example Inc", d.VEX.Author)
+				require.Equal(t, "generic", d.Provenance.Prober)
+				require.Equal(t, tc.purl.String(), d.Provenance.Purl)
+				require.NotEmpty(t, d.Provenance.Source)
+				require.Len(t, d.Provenance.Signatures, 1)
+				require.False(t, d.Provenance.Signatures[0].Verified)
+			}
+		})
+	}
+}
+
+func TestProberOptions(t *testing.T) {
+	p, err := purl.FromString("pkg:generic/found@1.0.0")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name   string
+		opts   interface{}
+		author string
+	}{
+		{"plugin options", Options{"author": "Example Inc"}, "Example Inc"},
+		{"no options", nil, "OpenVEX"},
+		// Options of other probers are not sent, even when they can't be
+		// serialized.
+		{"other prober options", struct{ C chan int }{make(chan int)}, "OpenVEX"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			prober := newTestPlugin(t, "serve")
+			opts := options.New().WithProberOptions("generic", tc.opts)
+			docs, err := prober.FindDocumentsWithProvenance(opts, p)
+			require.NoError(t, err)
+			require.Len(t, docs, 1)
+			require.Equal(t, tc.author, docs[0].VEX.Author)
+		})
+	}
+}
+
+func TestCancellation(t *testing.T) {
+	prober := newTestPlugin(t, "serve")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	prober.SetOptions(options.Options{Context: ctx})
+
+	p, err := purl.FromString("pkg:generic/found@1.0.0")
+	require.NoError(t, err)
+	_, err = prober.FindDocumentsFromPurl(prober.Options, p)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	for name, mode := range map[string]os.FileMode{
+		"vex-probe-npm":        0o755,
+		"vex-probe-pypi.sh":    0o755,
+		"vex-probe-noexec":     0o644,
+		"another-binary":       0o755,
+		"vex-probe-":           0o755,
+		"vex-probe-cargo.conf": 0o644,
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), mode))
+	}
+
+	probers, err := Discover(dir)
+	require.NoError(t, err)
+	require.Len(t, probers, 2)
+	require.Contains(t, probers, "npm")
+	require.Contains(t, probers, "pypi")
+	require.Equal(t, filepath.Join(dir, "vex-probe-pypi.sh"), probers["pypi"].Path)
+
+	_, err = Discover(filepath.Join(dir, "does-not-exist"))
+	require.Error(t, err)
+
+	// Two executables for the same purl type
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "vex-probe-npm.sh"), []byte("#!/bin/sh\n"), 0o755))
+	_, err = Discover(dir)
+	require.ErrorIs(t, err, errdefs.ErrInvalidArgument)
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/openvex/go-vex/pkg/vex"
+
+	"github.com/openvex/discovery/pkg/discovery/results"
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+// ProtocolVersion is the version of the plugin protocol implemented here
+const ProtocolVersion = "v1"
+
+// Request is the message the agent writes to the plugin's standard input
+type Request struct {
+	// Version is the protocol version spoken by the agent
+	Version string `json:"version"`
+
+	// Purl is the package URL to probe
+	Purl string `json:"purl"`
+
+	// Options holds the plugin Options set for the plugin's purl type,
+	// serialized as JSON. It is omitted when no options are set.
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+// Response is the message the plugin writes to its standard output
+type Response struct {
+	// Documents are the VEX documents found by the plugin
+	Documents []Document `json:"documents"`
+
+	// Errors lists non fatal problems the plugin found while probing
+	Errors []string `json:"errors,omitempty"`
+
+	// Error is set when probing failed. When set, documents are ignored.
+	Error string `json:"error,omitempty"`
+
+	// ErrorKind classifies the error using the names of the errdefs
+	// package, eg "not_found". It is optional.
+	ErrorKind string `json:"errorKind,omitempty"`
+}
+
+// Document is a VEX document returned by a plugin with optional data about
+// where it was found and who signed it. The agent ignores the verified flag
+// of the signatures, signatures reported by plugins are always unverified.
+type Document struct {
+	Document   *vex.VEX            `json:"document"`
+	Source     string              `json:"source,omitempty"`
+	Signatures []results.Signature `json:"signatures,omitempty"`
+}
+
+// Handler is a function that implements the probing logic of a plugin
+type Handler func(*Request) (*Response, error)
+
+// Serve implements the plugin side of the protocol. It reads a request from
+// standard input, passes it to the handler and writes the response to the
+// standard output. Plugins written in Go can simply call it from main().
+func Serve(handler Handler) error {
+	return serve(os.Stdin, os.Stdout, handler)
+}
+
+func serve(r io.Reader, w io.Writer, handler Handler) error {
+	req := &Request{}
+	if err := json.NewDecoder(r).Decode(req); err != nil {
+		return fmt.Errorf("decoding request: %w", err)
+	}
+
+	resp := &Response{Documents: []Document{}}
+	if req.Version != ProtocolVersion {
+		resp.Error = fmt.Sprintf("unsupported protocol version %q", req.Version)
+	} else {
+		var err error
+		resp, err = handler(req)
+		if err != nil {
+			resp = &Response{Documents: []Document{}, Error: err.Error(), ErrorKind: errdefs.KindName(err)}
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		return fmt.Errorf("encoding response: %w", err)
+	}
+	return nil
+}
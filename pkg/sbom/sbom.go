@@ -0,0 +1,221 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sbom reads the package URLs of the components listed in SPDX and
+// CycloneDX JSON documents, as found in the predicates of SBOM attestations,
+// and the links to VEX documents in their external references.
+package sbom
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+// In-toto predicate types of SBOM attestations
+const (
+	SPDXPredicateType      = "https://spdx.dev/Document"
+	CycloneDXPredicateType = "https://cyclonedx.org/bom"
+)
+
+// IsSPDXPredicateType returns true if an in-toto predicate type is SPDX,
+// either unversioned or of a specific version.
+func IsSPDXPredicateType(predicateType string) bool {
+	return predicateType == SPDXPredicateType || strings.HasPrefix(predicateType, SPDXPredicateType+"/")
+}
+
+// IsCycloneDXPredicateType returns true if an in-toto predicate type is
+// CycloneDX, either unversioned or of a specific version.
+func IsCycloneDXPredicateType(predicateType string) bool {
+	return predicateType == CycloneDXPredicateType || strings.HasPrefix(predicateType, CycloneDXPredicateType+"/")
+}
+
+// IsSBOMPredicateType returns true if an in-toto predicate type is one of
+// the supported SBOM formats.
+func IsSBOMPredicateType(predicateType string) bool {
+	return IsSPDXPredicateType(predicateType) || IsCycloneDXPredicateType(predicateType)
+}
+
+// VEX reference types in SPDX external refs and CycloneDX external references
+var (
+	spdxVEXReferenceTypes      = []string{"vex", "openvex"}
+	cyclonedxVEXReferenceTypes = []string{"vex", "exploitability-statement"}
+)
+
+// Contents are the data read from an SBOM
+type Contents struct {
+	// Purls are the purls of the components listed in the SBOM
+	Purls []string
+
+	// VEXReferences are the locations of the VEX documents linked from the
+	// SBOM, as URLs or purls
+	VEXReferences []string
+}
+
+// spdxDocument is the part of an SPDX document listing package references
+type spdxDocument struct {
+	Packages []struct {
+		ExternalRefs []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+	} `json:"packages"`
+}
+
+// cyclonedxReference is a CycloneDX external reference
+type cyclonedxReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// cyclonedxComponent is the part of a CycloneDX component with its purl and
+// external references
+type cyclonedxComponent struct {
+	Purl               string               `json:"purl"`
+	ExternalReferences []cyclonedxReference `json:"externalReferences"`
+	Components         []cyclonedxComponent `json:"components"`
+}
+
+// cyclonedxDocument is the part of a CycloneDX document listing components
+// and external references
+type cyclonedxDocument struct {
+	Metadata struct {
+		Component *cyclonedxComponent `json:"component"`
+	} `json:"metadata"`
+	ExternalReferences []cyclonedxReference `json:"externalReferences"`
+	Components         []cyclonedxComponent `json:"components"`
+}
+
+// Purls returns the purls of the components listed in an SBOM attestation
+// predicate, in document order and without duplicates. Some tools write the
+// SBOM as a JSON encoded string, those predicates are decoded first.
+func Purls(predicateType string, predicate []byte) ([]string, error) {
+	contents, err := Read(predicateType, predicate)
+	if err != nil {
+		return nil, err
+	}
+	return contents.Purls, nil
+}
+
+// VEXReferences returns the locations of the VEX documents linked from an
+// SBOM attestation predicate. SPDX packages link them with external refs of
+// type vex or openvex, CycloneDX documents and components with external
+// references of type vex or exploitability-statement.
+func VEXReferences(predicateType string, predicate []byte) ([]string, error) {
+	contents, err := Read(predicateType, predicate)
+	if err != nil {
+		return nil, err
+	}
+	return contents.VEXReferences, nil
+}
+
+// Read returns the component purls and the VEX references of an SBOM
+// attestation predicate, see Purls and VEXReferences.
+func Read(predicateType string, predicate []byte) (*Contents, error) {
+	var encoded string
+	if err := json.Unmarshal(predicate, &encoded); err == nil {
+		predicate = []byte(encoded)
+	}
+
+	switch {
+	case IsSPDXPredicateType(predicateType):
+		return readSPDX(predicate)
+	case IsCycloneDXPredicateType(predicateType):
+		return readCycloneDX(predicate)
+	default:
+		return nil, errdefs.New(errdefs.ErrParse, "predicate type %q is not a supported SBOM format", predicateType)
+	}
+}
+
+// readSPDX reads the purls and VEX links in the external references of
+// SPDX packages
+func readSPDX(data []byte) (*Contents, error) {
+	doc := spdxDocument{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errdefs.New(errdefs.ErrParse, "parsing SPDX document: %w", err)
+	}
+
+	purls, refs := newPurlSet(), newPurlSet()
+	for i := range doc.Packages {
+		for _, ref := range doc.Packages[i].ExternalRefs {
+			switch {
+			case strings.EqualFold(ref.ReferenceType, "purl"):
+				purls.add(ref.ReferenceLocator)
+			case isOneOf(ref.ReferenceType, spdxVEXReferenceTypes):
+				refs.add(ref.ReferenceLocator)
+			}
+		}
+	}
+	return &Contents{Purls: purls.list, VEXReferences: refs.list}, nil
+}
+
+// readCycloneDX reads the purls of the described component and all the
+// components of a CycloneDX document, including nested ones, and the VEX
+// links in the external references of the document and its components.
+func readCycloneDX(data []byte) (*Contents, error) {
+	doc := cyclonedxDocument{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errdefs.New(errdefs.ErrParse, "parsing CycloneDX document: %w", err)
+	}
+
+	purls, refs := newPurlSet(), newPurlSet()
+	refs.addReferences(doc.ExternalReferences)
+	if doc.Metadata.Component != nil {
+		purls.addComponents([]cyclonedxComponent{*doc.Metadata.Component}, refs)
+	}
+	purls.addComponents(doc.Components, refs)
+	return &Contents{Purls: purls.list, VEXReferences: refs.list}, nil
+}
+
+// isOneOf returns true if a reference type is one of a list, ignoring case
+func isOneOf(referenceType string, types []string) bool {
+	for _, t := range types {
+		if strings.EqualFold(referenceType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// purlSet is a list of purls or locations without duplicates
+type purlSet struct {
+	list []string
+	seen map[string]struct{}
+}
+
+func newPurlSet() *purlSet {
+	return &purlSet{list: []string{}, seen: map[string]struct{}{}}
+}
+
+// add adds a purl to the list if it is not empty or already in it
+func (s *purlSet) add(p string) {
+	if p == "" {
+		return
+	}
+	if _, ok := s.seen[p]; ok {
+		return
+	}
+	s.seen[p] = struct{}{}
+	s.list = append(s.list, p)
+}
+
+// addComponents adds the purls of CycloneDX components and their nested
+// components. Their VEX references are added to refs.
+func (s *purlSet) addComponents(components []cyclonedxComponent, refs *purlSet) {
+	for i := range components {
+		s.add(components[i].Purl)
+		refs.addReferences(components[i].ExternalReferences)
+		s.addComponents(components[i].Components, refs)
+	}
+}
+
+// addReferences adds the urls of the CycloneDX external references to VEX
+// documents
+func (s *purlSet) addReferences(references []cyclonedxReference) {
+	for _, ref := range references {
+		if isOneOf(ref.Type, cyclonedxVEXReferenceTypes) {
+			s.add(ref.URL)
+		}
+	}
+}
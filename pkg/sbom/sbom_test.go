@@ -0,0 +1,177 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package sbom
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/internal/testregistry"
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+// wolfiSBOM reads the SPDX predicate of the wolfi-base SBOM attestation
+func wolfiSBOM(t *testing.T) (string, []byte) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(
+		testregistry.TestDataPath(), "wolfi-base-att", "blobs", "sha256",
+		"c49f18d923f61fefba17b48f7a8f6e9b6938d4978892c678dbf96c0dbd69bcba",
+	))
+	require.NoError(t, err)
+
+	envelope := struct {
+		Payload string `json:"payload"`
+	}{}
+	require.NoError(t, json.Unmarshal(data, &envelope))
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	require.NoError(t, err)
+
+	statement := struct {
+		PredicateType string          `json:"predicateType"`
+		Predicate     json.RawMessage `json:"predicate"`
+	}{}
+	require.NoError(t, json.Unmarshal(payload, &statement))
+	return statement.PredicateType, statement.Predicate
+}
+
+func TestPurls(t *testing.T) {
+	wolfiType, wolfiPredicate := wolfiSBOM(t)
+	for _, tc := range []struct {
+		name          string
+		predicateType string
+		predicate     string
+		expected      []string
+		mustErr       bool
+	}{
+		{
+			name:          "spdx",
+			predicateType: SPDXPredicateType,
+			predicate: `{"packages": [
+				{"name": "a", "externalRefs": [
+					{"referenceType": "cpe23Type", "referenceLocator": "cpe:2.3:a:example:a:1.0"},
+					{"referenceType": "purl", "referenceLocator": "pkg:apk/wolfi/a@1.0"}
+				]},
+				{"name": "b", "externalRefs": [{"referenceType": "purl", "referenceLocator": "pkg:apk/wolfi/b@2.0"}]},
+				{"name": "a-again", "externalRefs": [{"referenceType": "purl", "referenceLocator": "pkg:apk/wolfi/a@1.0"}]},
+				{"name": "no refs"}
+			]}`,
+			expected: []string{"pkg:apk/wolfi/a@1.0", "pkg:apk/wolfi/b@2.0"},
+		},
+		{
+			name:          "spdx encoded as string",
+			predicateType: SPDXPredicateType + "/v2.3",
+			predicate:     `"{\"packages\": [{\"externalRefs\": [{\"referenceType\": \"purl\", \"referenceLocator\": \"pkg:apk/wolfi/a@1.0\"}]}]}"`,
+			expected:      []string{"pkg:apk/wolfi/a@1.0"},
+		},
+		{
+			name:          "cyclonedx",
+			predicateType: CycloneDXPredicateType,
+			predicate: `{
+				"metadata": {"component": {"purl": "pkg:oci/app@sha256%3Aabc"}},
+				"components": [
+					{"purl": "pkg:golang/example.com/a@v1.0.0", "components": [{"purl": "pkg:golang/example.com/a/sub@v1.0.0"}]},
+					{"name": "no purl"},
+					{"purl": "pkg:npm/b@2.0.0"}
+				]
+			}`,
+			expected: []string{
+				"pkg:oci/app@sha256%3Aabc", "pkg:golang/example.com/a@v1.0.0",
+				"pkg:golang/example.com/a/sub@v1.0.0", "pkg:npm/b@2.0.0",
+			},
+		},
+		{
+			name:          "invalid document",
+			predicateType: SPDXPredicateType,
+			predicate:     `{"packages": "invalid"}`,
+			mustErr:       true,
+		},
+		{
+			name:          "not an sbom",
+			predicateType: "https://openvex.dev/ns",
+			predicate:     `{}`,
+			mustErr:       true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			purls, err := Purls(tc.predicateType, []byte(tc.predicate))
+			if tc.mustErr {
+				require.ErrorIs(t, err, errdefs.ErrParse)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, purls)
+		})
+	}
+
+	t.Run("wolfi-base attestation", func(t *testing.T) {
+		require.True(t, IsSBOMPredicateType(wolfiType))
+		purls, err := Purls(wolfiType, wolfiPredicate)
+		require.NoError(t, err)
+		require.Contains(t, purls, "pkg:apk/wolfi/wolfi-base@1-r3?arch=x86_64")
+		require.Contains(t, purls, "pkg:apk/wolfi/wolfi-keys@1-r5?arch=x86_64")
+	})
+}
+
+func TestVEXReferences(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		predicateType string
+		predicate     string
+		expected      []string
+	}{
+		{
+			name:          "spdx",
+			predicateType: SPDXPredicateType,
+			predicate: `{"packages": [
+				{"name": "a", "externalRefs": [
+					{"referenceType": "purl", "referenceLocator": "pkg:apk/wolfi/a@1.0"},
+					{"referenceCategory": "SECURITY", "referenceType": "vex", "referenceLocator": "https://example.com/a.vex.json"}
+				]},
+				{"name": "b", "externalRefs": [
+					{"referenceCategory": "OTHER", "referenceType": "OpenVEX", "referenceLocator": "pkg:oci/b-vex?repository_url=example.com"},
+					{"referenceCategory": "SECURITY", "referenceType": "advisory", "referenceLocator": "https://example.com/advisory"}
+				]}
+			]}`,
+			expected: []string{"https://example.com/a.vex.json", "pkg:oci/b-vex?repository_url=example.com"},
+		},
+		{
+			name:          "cyclonedx",
+			predicateType: CycloneDXPredicateType,
+			predicate: `{
+				"externalReferences": [
+					{"type": "vex", "url": "https://example.com/app.vex.json"},
+					{"type": "website", "url": "https://example.com"}
+				],
+				"metadata": {"component": {"externalReferences": [{"type": "exploitability-statement", "url": "https://example.com/meta.vex.json"}]}},
+				"components": [
+					{"purl": "pkg:npm/a@1.0.0", "components": [
+						{"externalReferences": [{"type": "vex", "url": "https://example.com/app.vex.json"}]},
+						{"externalReferences": [{"type": "vex", "url": "https://example.com/sub.vex.json"}]}
+					]}
+				]
+			}`,
+			expected: []string{
+				"https://example.com/app.vex.json", "https://example.com/meta.vex.json",
+				"https://example.com/sub.vex.json",
+			},
+		},
+		{
+			name:          "no references",
+			predicateType: CycloneDXPredicateType,
+			predicate:     `{"components": [{"purl": "pkg:npm/a@1.0.0"}]}`,
+			expected:      []string{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			refs, err := VEXReferences(tc.predicateType, []byte(tc.predicate))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, refs)
+		})
+	}
+}
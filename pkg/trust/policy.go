@@ -0,0 +1,382 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package trust
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	purl "github.com/package-url/packageurl-go"
+	"gopkg.in/yaml.v3"
+
+	"github.com/openvex/discovery/pkg/discovery/results"
+)
+
+const (
+	// ActionAccept trusts documents not covered by any rule
+	ActionAccept = "accept"
+
+	// ActionReject distrusts documents not covered by any rule
+	ActionReject = "reject"
+
+	// ModeEnforce makes the agent drop untrusted documents from its results
+	ModeEnforce = "enforce"
+
+	// ModeWarn keeps untrusted documents in the results, marked as untrusted
+	ModeWarn = "warn"
+)
+
+// Policy is a declarative trust policy that decides which VEX documents
+// the discovery agent accepts for a component. Rules are evaluated in order,
+// the first rule whose purl pattern matches the probed package URL decides if
+// a document is trusted. When no rule matches, the default action applies.
+type Policy struct {
+	// Default is the action taken when no rule matches the purl. It can be
+	// "accept" or "reject", defaults to reject.
+	Default string `yaml:"default" json:"default"`
+
+	// Mode controls what the agent does with untrusted documents: "enforce"
+	// drops them from the results, "warn" only marks them. Defaults to enforce.
+	Mode string `yaml:"mode" json:"mode"`
+
+	Rules []Rule `yaml:"rules" json:"rules"`
+
+	// The rule patterns are compiled once, the first time the policy is
+	// validated or evaluated. The policy must not be modified after that.
+	compileOnce sync.Once
+	compiled    []*compiledRule
+	compileErr  error
+}
+
+// compiledRule holds the parsed patterns of a rule
+type compiledRule struct {
+	rule       *Rule
+	typ        *pattern
+	namespace  *pattern
+	name       *pattern
+	version    *pattern
+	qualifiers map[string]*pattern
+	authors    []*pattern
+	identities []*pattern
+	issuers    []*pattern
+	sources    []*pattern
+}
+
+// pattern is a string pattern where * matches any string
+type pattern struct {
+	literal string
+	re      *regexp.Regexp
+}
+
+// Rule maps a package URL pattern to the authors, signers and sources
+// allowed to publish VEX data about it. All patterns support the * wildcard
+// which matches any string. Empty lists are not enforced, all non-empty lists
+// must match for a document to be trusted.
+type Rule struct {
+	Name string `yaml:"name" json:"name"`
+
+	// Purl is a package URL pattern. Type, namespace, name and version are
+	// matched against the probed purl. Any qualifiers in the pattern must be
+	// present in the probed purl and match their values.
+	Purl string `yaml:"purl" json:"purl"`
+
+	// Authors is a list of patterns matched against the document author.
+	Authors []string `yaml:"authors" json:"authors"`
+
+	// Identities is a list of patterns matched against the signer identities.
+	Identities []string `yaml:"identities" json:"identities"`
+
+	// Issuers is a list of patterns matched against the OIDC issuers of the
+	// signer identities. It is checked on the same signature as Identities.
+	Issuers []string `yaml:"issuers" json:"issuers"`
+
+	// Sources is a list of patterns matched against the location where the
+	// document was found.
+	Sources []string `yaml:"sources" json:"sources"`
+
+	// AllowUnverified makes the rule match identities from signatures that
+	// were not cryptographically verified. This is unsafe, anyone can attach
+	// a certificate with any identity to a document.
+	AllowUnverified bool `yaml:"allowUnverified" json:"allowUnverified"`
+}
+
+// Load reads a trust policy from a YAML (or JSON) file.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading policy file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse reads a trust policy from YAML (or JSON) data and validates it.
+func Parse(data []byte) (*Policy, error) {
+	p := &Policy{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("parsing policy: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("validating policy: %w", err)
+	}
+	return p, nil
+}
+
+// Validate checks the policy and returns an error if it is not well formed.
+func (p *Policy) Validate() error {
+	errs := []error{}
+	switch p.Default {
+	case "", ActionAccept, ActionReject:
+	default:
+		errs = append(errs, fmt.Errorf("invalid default action %q", p.Default))
+	}
+
+	switch p.Mode {
+	case "", ModeEnforce, ModeWarn:
+	default:
+		errs = append(errs, fmt.Errorf("invalid mode %q", p.Mode))
+	}
+
+	if _, err := p.compile(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// compile parses the rule patterns the first time it is called and returns
+// the compiled rules.
+func (p *Policy) compile() ([]*compiledRule, error) {
+	p.compileOnce.Do(func() {
+		errs := []error{}
+		p.compiled = make([]*compiledRule, 0, len(p.Rules))
+		for i := range p.Rules {
+			cr, err := compileRule(&p.Rules[i])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("rule #%d: %w", i, err))
+				continue
+			}
+			p.compiled = append(p.compiled, cr)
+		}
+		p.compileErr = errors.Join(errs...)
+	})
+	return p.compiled, p.compileErr
+}
+
+// compileRule parses the purl pattern of a rule and compiles its pattern lists
+func compileRule(r *Rule) (*compiledRule, error) {
+	if r.Purl == "" {
+		return nil, errors.New("rule has no purl pattern")
+	}
+	pp, err := purl.FromString(r.Purl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid purl pattern: %w", err)
+	}
+
+	cr := &compiledRule{rule: r, qualifiers: map[string]*pattern{}}
+	errs := []error{}
+	for _, field := range []struct {
+		name  string
+		value string
+		dest  **pattern
+	}{
+		{"type", pp.Type, &cr.typ},
+		{"namespace", pp.Namespace, &cr.namespace},
+		{"name", pp.Name, &cr.name},
+		{"version", pp.Version, &cr.version},
+	} {
+		// An empty version in the pattern matches any version
+		if field.name == "version" && field.value == "" {
+			continue
+		}
+		pt, err := compilePattern(field.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid purl %s pattern: %w", field.name, err))
+			continue
+		}
+		*field.dest = pt
+	}
+	for k, v := range pp.Qualifiers.Map() {
+		pt, err := compilePattern(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid purl qualifier %s pattern: %w", k, err))
+			continue
+		}
+		cr.qualifiers[k] = pt
+	}
+
+	for _, list := range []struct {
+		name     string
+		patterns []string
+		dest     *[]*pattern
+	}{
+		{"author", r.Authors, &cr.authors},
+		{"identity", r.Identities, &cr.identities},
+		{"issuer", r.Issuers, &cr.issuers},
+		{"source", r.Sources, &cr.sources},
+	} {
+		for _, s := range list.patterns {
+			if s == "" {
+				errs = append(errs, fmt.Errorf("empty %s pattern", list.name))
+				continue
+			}
+			pt, err := compilePattern(s)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s pattern %q: %w", list.name, s, err))
+				continue
+			}
+			*list.dest = append(*list.dest, pt)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// compilePattern compiles a pattern where * matches any string. Patterns
+// without wildcards are compared literally.
+func compilePattern(s string) (*pattern, error) {
+	if !strings.Contains(s, "*") {
+		return &pattern{literal: s}, nil
+	}
+	parts := strings.Split(s, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	re, err := regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+	if err != nil {
+		return nil, err
+	}
+	return &pattern{re: re}, nil
+}
+
+// match checks a string against the pattern
+func (pt *pattern) match(value string) bool {
+	if pt.re == nil {
+		return pt.literal == value
+	}
+	return pt.re.MatchString(value)
+}
+
+// Enforced returns true if untrusted documents should be dropped
+func (p *Policy) Enforced() bool {
+	return p.Mode != ModeWarn
+}
+
+// Evaluate decides if a document found when probing a package URL is trusted
+func (p *Policy) Evaluate(purlString string, doc *results.Document) results.TrustDecision {
+	rules, err := p.compile()
+	if err != nil {
+		return results.TrustDecision{
+			Trusted: false,
+			Reason:  fmt.Sprintf("invalid trust policy: %v", err),
+		}
+	}
+
+	if probed, err := purl.FromString(purlString); err == nil {
+		for _, r := range rules {
+			if !r.matchPurl(probed) {
+				continue
+			}
+			return r.evaluate(doc)
+		}
+	}
+
+	if p.Default == ActionAccept {
+		return results.TrustDecision{
+			Trusted: true,
+			Reason:  "no policy rule matches the purl, accepted by default",
+		}
+	}
+	return results.TrustDecision{
+		Trusted: false,
+		Reason:  "no policy rule matches the purl, rejected by default",
+	}
+}
+
+// evaluate checks the document against the rule constraints
+func (r *compiledRule) evaluate(doc *results.Document) results.TrustDecision {
+	decision := results.TrustDecision{Rule: r.rule.Name}
+	if decision.Rule == "" {
+		decision.Rule = r.rule.Purl
+	}
+
+	if len(r.authors) > 0 {
+		author := ""
+		if doc.VEX != nil {
+			author = doc.VEX.Author
+		}
+		if !matchAny(r.authors, author) {
+			decision.Reason = fmt.Sprintf("author %q is not allowed", author)
+			return decision
+		}
+	}
+
+	if len(r.sources) > 0 && !matchAny(r.sources, doc.Provenance.Source) {
+		decision.Reason = fmt.Sprintf("source %q is not allowed", doc.Provenance.Source)
+		return decision
+	}
+
+	if len(r.identities) > 0 || len(r.issuers) > 0 {
+		if !r.matchSignatures(doc.Provenance.Signatures) {
+			decision.Reason = "document has no signature from an allowed identity"
+			return decision
+		}
+	}
+
+	decision.Trusted = true
+	decision.Reason = "document complies with the policy rule"
+	return decision
+}
+
+// matchSignatures returns true if one of the signatures matches both the
+// identity and issuer lists of the rule.
+func (r *compiledRule) matchSignatures(sigs []results.Signature) bool {
+	for _, s := range sigs {
+		if !s.Verified && !r.rule.AllowUnverified {
+			continue
+		}
+		if len(r.identities) > 0 && !matchAny(r.identities, s.Identity) {
+			continue
+		}
+		if len(r.issuers) > 0 && !matchAny(r.issuers, s.Issuer) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// matchPurl checks if a purl matches the rule purl pattern
+func (r *compiledRule) matchPurl(p purl.PackageURL) bool {
+	if !r.typ.match(p.Type) || !r.namespace.match(p.Namespace) || !r.name.match(p.Name) {
+		return false
+	}
+
+	if r.version != nil && !r.version.match(p.Version) {
+		return false
+	}
+
+	qualifiers := p.Qualifiers.Map()
+	for k, pt := range r.qualifiers {
+		pv, ok := qualifiers[k]
+		if !ok || !pt.match(pv) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny returns true if the value matches any of the patterns
+func matchAny(patterns []*pattern, value string) bool {
+	for _, p := range patterns {
+		if p.match(value) {
+			return true
+		}
+	}
+	return false
+}
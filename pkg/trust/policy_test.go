@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package trust
+
+import (
+	"testing"
+
+	"github.com/openvex/go-vex/pkg/vex"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/pkg/discovery/results"
+)
+
+func TestLoad(t *testing.T) {
+	p, err := Load("testdata/policy.yaml")
+	require.NoError(t, err)
+	require.Len(t, p.Rules, 2)
+	require.Equal(t, ActionReject, p.Default)
+	require.True(t, p.Enforced())
+
+	_, err = Load("testdata/nonexistent.yaml")
+	require.Error(t, err)
+
+	for _, data := range []string{
+		"default: maybe",
+		"mode: audit",
+		"rules:\n  - name: no purl",
+		"rules:\n  - purl: not-a-purl",
+		"rules:\n  - purl: pkg:oci/curl\n    identities: ['']",
+	} {
+		_, err := Parse([]byte(data))
+		require.Error(t, err, data)
+	}
+}
+
+func TestEvaluateInvalidPolicy(t *testing.T) {
+	// Policies built in code are compiled on first use
+	policy := &Policy{Default: ActionAccept, Rules: []Rule{{Purl: "pkg:oci/curl", Authors: []string{""}}}}
+	decision := policy.Evaluate("pkg:oci/curl", &results.Document{})
+	require.False(t, decision.Trusted)
+	require.Contains(t, decision.Reason, "invalid trust policy")
+	require.Error(t, policy.Validate())
+}
+
+func TestEvaluate(t *testing.T) {
+	policy, err := Load("testdata/policy.yaml")
+	require.NoError(t, err)
+
+	cgrPurl := "pkg:oci/curl@sha256%3A3b987bc327e8aa8e7db26822e0552d927d25392ccb4d3b9d30b5390b485520d8?repository_url=cgr.dev/chainguard"
+	cgrSig := results.Signature{
+		Identity: "https://github.com/chainguard-images/images/.github/workflows/release.yaml@refs/heads/main",
+		Issuer:   "https://token.actions.githubusercontent.com",
+		Verified: true,
+	}
+
+	for _, tc := range []struct {
+		name     string
+		policy   *Policy
+		purl     string
+		doc      *results.Document
+		trusted  bool
+		ruleName string
+	}{
+		{
+			name:   "trusted author and signer",
+			policy: policy,
+			purl:   cgrPurl,
+			doc: &results.Document{
+				VEX:        &vex.VEX{Metadata: vex.Metadata{Author: "Chainguard Inc"}},
+				Provenance: results.Provenance{Signatures: []results.Signature{cgrSig}},
+			},
+			trusted:  true,
+			ruleName: "chainguard images",
+		},
+		{
+			name:   "untrusted author",
+			policy: policy,
+			purl:   cgrPurl,
+			doc: &results.Document{
+				VEX:        &vex.VEX{Metadata: vex.Metadata{Author: "Mallory"}},
+				Provenance: results.Provenance{Signatures: []results.Signature{cgrSig}},
+			},
+			trusted:  false,
+			ruleName: "chainguard images",
+		},
+		{
+			name:   "unverified signature",
+			policy: policy,
+			purl:   cgrPurl,
+			doc: &results.Document{
+				VEX: &vex.VEX{Metadata: vex.Metadata{Author: "Chainguard Inc"}},
+				Provenance: results.Provenance{Signatures: []results.Signature{
+					{Identity: cgrSig.Identity, Issuer: cgrSig.Issuer},
+				}},
+			},
+			trusted:  false,
+			ruleName: "chainguard images",
+		},
+		{
+			name:   "wrong issuer",
+			policy: policy,
+			purl:   cgrPurl,
+			doc: &results.Document{
+				VEX: &vex.VEX{Metadata: vex.Metadata{Author: "Chainguard Inc"}},
+				Provenance: results.Provenance{Signatures: []results.Signature{
+					{Identity: cgrSig.Identity, Issuer: "https://accounts.google.com", Verified: true},
+				}},
+			},
+			trusted:  false,
+			ruleName: "chainguard images",
+		},
+		{
+			name:   "trusted source",
+			policy: policy,
+			purl:   "pkg:oci/alpine-cves?repository_url=localhost:5000&tag=latest",
+			doc: &results.Document{
+				VEX:        &vex.VEX{},
+				Provenance: results.Provenance{Source: "localhost:5000/alpine-cves:latest"},
+			},
+			trusted:  true,
+			ruleName: "local registry",
+		},
+		{
+			name:   "untrusted source",
+			policy: policy,
+			purl:   "pkg:oci/alpine-cves?repository_url=localhost:5000",
+			doc: &results.Document{
+				VEX:        &vex.VEX{},
+				Provenance: results.Provenance{Source: "ghcr.io/mallory/alpine-cves:latest"},
+			},
+			trusted:  false,
+			ruleName: "local registry",
+		},
+		{
+			name:    "no rule rejected by default",
+			policy:  policy,
+			purl:    "pkg:oci/alpine",
+			doc:     &results.Document{VEX: &vex.VEX{}},
+			trusted: false,
+		},
+		{
+			name:    "no rule accepted by default",
+			policy:  &Policy{Default: ActionAccept},
+			purl:    "pkg:oci/alpine",
+			doc:     &results.Document{VEX: &vex.VEX{}},
+			trusted: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			decision := tc.policy.Evaluate(tc.purl, tc.doc)
+			require.Equal(t, tc.trusted, decision.Trusted, decision.Reason)
+			require.Equal(t, tc.ruleName, decision.Rule)
+			require.NotEmpty(t, decision.Reason)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package vexref reads the links to other VEX documents written in OpenVEX
+// documents. The OpenVEX spec has no field for them, so the links are read
+// from a top level references array that is an extension of this module and
+// not part of the spec. Other OpenVEX tools ignore it and the agent only
+// follows it when its FollowReferencesExtension option is set. The entries are shaped
+// as the CycloneDX external references:
+//
+//	"references": [
+//	  {"type": "vex", "url": "https://example.com/base-image.vex.json"},
+//	  {"type": "vex", "url": "pkg:oci/base?repository_url=example.com"}
+//	]
+//
+// The url of a reference is an HTTP(S) URL or a purl to probe.
+package vexref
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+// TypeVEX is the type of the references to VEX documents
+const TypeVEX = "vex"
+
+// Reference is a link from a VEX document to another location
+type Reference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// FromDocument returns the locations of the VEX documents referenced by a
+// raw OpenVEX document, in document order and without duplicates. Untyped
+// references are taken as VEX references.
+func FromDocument(data []byte) ([]string, error) {
+	doc := struct {
+		References []Reference `json:"references"`
+	}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errdefs.New(errdefs.ErrParse, "parsing openvex document: %w", err)
+	}
+
+	locations := []string{}
+	seen := map[string]struct{}{}
+	for _, ref := range doc.References {
+		if ref.URL == "" || (ref.Type != "" && !strings.EqualFold(ref.Type, TypeVEX)) {
+			continue
+		}
+		if _, ok := seen[ref.URL]; ok {
+			continue
+		}
+		seen[ref.URL] = struct{}{}
+		locations = append(locations, ref.URL)
+	}
+	return locations, nil
+}
+
+// IsURL returns true if a reference location is an HTTP(S) URL
+func IsURL(location string) bool {
+	lower := strings.ToLower(location)
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://")
+}
+
+// IsHTTPS returns true if a reference location is an HTTPS URL
+func IsHTTPS(location string) bool {
+	return strings.HasPrefix(strings.ToLower(location), "https://")
+}
+
+// IsPurl returns true if a reference location is a package URL
+func IsPurl(location string) bool {
+	return strings.HasPrefix(location, "pkg:")
+}
@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: Copyright 2023 The OpenVEX Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package vexref
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openvex/discovery/pkg/errdefs"
+)
+
+func TestFromDocument(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		document string
+		expected []string
+		mustErr  bool
+	}{
+		{
+			name: "references",
+			document: `{"@context": "https://openvex.dev/ns/v0.2.0", "references": [
+				{"type": "vex", "url": "https://example.com/base.vex.json"},
+				{"url": "pkg:oci/base?repository_url=example.com"},
+				{"type": "website", "url": "https://example.com"},
+				{"type": "VEX", "url": "https://example.com/base.vex.json"},
+				{"type": "vex"}
+			]}`,
+			expected: []string{"https://example.com/base.vex.json", "pkg:oci/base?repository_url=example.com"},
+		},
+		{
+			name:     "no references",
+			document: `{"@context": "https://openvex.dev/ns/v0.2.0", "statements": []}`,
+			expected: []string{},
+		},
+		{
+			name:     "invalid references",
+			document: `{"references": "https://example.com"}`,
+			mustErr:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			refs, err := FromDocument([]byte(tc.document))
+			if tc.mustErr {
+				require.ErrorIs(t, err, errdefs.ErrParse)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, refs)
+		})
+	}
+}
+
+func TestLocationKinds(t *testing.T) {
+	require.True(t, IsURL("https://example.com/doc.json"))
+	require.True(t, IsURL("HTTP://example.com/doc.json"))
+	require.False(t, IsURL("pkg:oci/alpine"))
+	require.True(t, IsHTTPS("HTTPS://example.com/doc.json"))
+	require.False(t, IsHTTPS("http://example.com/doc.json"))
+	require.True(t, IsPurl("pkg:oci/alpine"))
+	require.False(t, IsPurl("s3://bucket/doc.json"))
+}